@@ -44,3 +44,8 @@ func InitDB() {
 func GetDB() *sql.DB {
 	return db
 }
+
+// SetDB replaces the connection pool, e.g. with a stub in tests
+func SetDB(conn *sql.DB) {
+	db = conn
+}
@@ -0,0 +1,207 @@
+// Package dbtest stands in for Postgres in tests. It installs a database/sql
+// driver that answers queries from a script and records every statement, so
+// code going through db.GetDB can be tested without a database.
+package dbtest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/wesuuu/helpnow/backend/db"
+)
+
+// Result answers the statements containing Match: queries with Rows, execs with
+// Affected. A Once result answers a single statement, so several can script one
+// that is run repeatedly.
+type Result struct {
+	Match    string
+	Columns  []string // Defaults to one unnamed column per value of the first row
+	Rows     [][]driver.Value
+	Affected int64
+	Once     bool
+	used     bool
+}
+
+// Statement is a query or exec the code under test ran
+type Statement struct {
+	Query string
+	Args  []driver.Value
+}
+
+// DB is the scripted database behind db.GetDB during a test
+type DB struct {
+	mu         sync.Mutex
+	results    []*Result
+	Statements []Statement
+	Committed  bool
+}
+
+var (
+	registerOnce sync.Once
+	mu           sync.Mutex
+	databases    = map[string]*DB{}
+)
+
+// Use points db.GetDB at a scripted database until the test ends. Queries no
+// result matches return no rows; such execs succeed without affecting any.
+func Use(t testing.TB, results ...Result) *DB {
+	t.Helper()
+	registerOnce.Do(func() { sql.Register("dbtest", fakeDriver{}) })
+
+	fake := &DB{}
+	for i := range results {
+		r := results[i]
+		fake.results = append(fake.results, &r)
+	}
+	mu.Lock()
+	name := fmt.Sprintf("db-%d", len(databases))
+	databases[name] = fake
+	mu.Unlock()
+
+	conn, err := sql.Open("dbtest", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetDB(conn)
+	t.Cleanup(func() {
+		conn.Close()
+		db.SetDB(nil)
+	})
+	return fake
+}
+
+// Index returns the position of the first statement from start containing
+// needle, or -1
+func (d *DB) Index(needle string, start int) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := start; i < len(d.Statements); i++ {
+		if strings.Contains(d.Statements[i].Query, needle) {
+			return i
+		}
+	}
+	return -1
+}
+
+// AssertOrder checks that statements containing each needle ran in the given
+// order, and returns them. Consecutive needles may match the same statement.
+func (d *DB) AssertOrder(t testing.TB, needles ...string) []Statement {
+	t.Helper()
+	found := []Statement{}
+	at := 0
+	for _, needle := range needles {
+		i := d.Index(needle, at)
+		if i < 0 {
+			t.Fatalf("Expected a statement containing %q from statement %d, ran:\n%s", needle, at, d)
+		}
+		found = append(found, d.Statements[i])
+		at = i
+	}
+	return found
+}
+
+// String lists the statements run, one per line
+func (d *DB) String() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lines := make([]string, len(d.Statements))
+	for i, s := range d.Statements {
+		lines[i] = strings.Join(strings.Fields(s.Query), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (d *DB) record(query string, args []driver.Value) *Result {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Statements = append(d.Statements, Statement{query, args})
+	for _, r := range d.results {
+		if r.used || !strings.Contains(query, r.Match) {
+			continue
+		}
+		if r.Once {
+			r.used = true
+		}
+		return r
+	}
+	return nil
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	fake, ok := databases[name]
+	if !ok {
+		return nil, fmt.Errorf("dbtest: unknown database %s", name)
+	}
+	return &conn{db: fake}, nil
+}
+
+type conn struct{ db *DB }
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{db: c.db, query: query}, nil
+}
+func (c *conn) Close() error              { return nil }
+func (c *conn) Begin() (driver.Tx, error) { return &tx{db: c.db}, nil }
+
+type tx struct{ db *DB }
+
+func (t *tx) Commit() error {
+	t.db.mu.Lock()
+	t.db.Committed = true
+	t.db.mu.Unlock()
+	return nil
+}
+func (t *tx) Rollback() error { return nil }
+
+type stmt struct {
+	db    *DB
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	if r := s.db.record(s.query, args); r != nil {
+		return driver.RowsAffected(r.Affected), nil
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	r := s.db.record(s.query, args)
+	if r == nil {
+		return &rows{}, nil
+	}
+	columns := r.Columns
+	if columns == nil && len(r.Rows) > 0 {
+		columns = make([]string, len(r.Rows[0]))
+	}
+	return &rows{columns: columns, rows: r.Rows}, nil
+}
+
+type rows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/scheduler"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+const approvalColumns = `a.id, a.execution_id, a.workflow_id, w.name, a.node_id, a.assignee_user_id, a.assignee_team_id, COALESCE(a.instructions, ''), a.allow_context_edits, a.status, a.comment, a.decided_by, a.decided_at, a.due_at, we.context, a.created_at`
+
+func scanApproval(row rowScanner) (workflows.WorkflowApproval, error) {
+	var a workflows.WorkflowApproval
+	var contextStr sql.NullString
+	err := row.Scan(&a.ID, &a.ExecutionID, &a.WorkflowID, &a.WorkflowName, &a.NodeID, &a.AssigneeUserID, &a.AssigneeTeamID, &a.Instructions, &a.AllowContextEdits, &a.Status, &a.Comment, &a.DecidedBy, &a.DecidedAt, &a.DueAt, &contextStr, &a.CreatedAt)
+	if err != nil {
+		return a, err
+	}
+	if contextStr.Valid && contextStr.String != "" {
+		json.Unmarshal([]byte(contextStr.String), &a.Context)
+	}
+	return a, nil
+}
+
+// ListApprovals returns review tasks, pending ones by default, optionally
+// filtered by assignee user_id, team_id or workflow_id.
+func ListApprovals(c echo.Context) error {
+	status := c.QueryParam("status")
+	if status == "" {
+		status = "PENDING"
+	}
+
+	query := `SELECT ` + approvalColumns + `
+		FROM workflow_approvals a
+		JOIN workflows w ON a.workflow_id = w.id
+		JOIN workflow_executions we ON a.execution_id = we.id
+		WHERE a.status = $1`
+	args := []interface{}{status}
+
+	if userID := c.QueryParam("user_id"); userID != "" {
+		args = append(args, userID)
+		query += ` AND a.assignee_user_id = $` + strconv.Itoa(len(args))
+	}
+	if teamID := c.QueryParam("team_id"); teamID != "" {
+		args = append(args, teamID)
+		query += ` AND a.assignee_team_id = $` + strconv.Itoa(len(args))
+	}
+	if workflowID := c.QueryParam("workflow_id"); workflowID != "" {
+		args = append(args, workflowID)
+		query += ` AND a.workflow_id = $` + strconv.Itoa(len(args))
+	}
+	query += ` ORDER BY a.created_at ASC`
+
+	rows, err := db.GetDB().Query(query, args...)
+	if err != nil {
+		c.Logger().Error("Failed to list approvals: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list approvals"})
+	}
+	defer rows.Close()
+
+	approvals := []workflows.WorkflowApproval{}
+	for rows.Next() {
+		a, err := scanApproval(rows)
+		if err != nil {
+			c.Logger().Error("Scan error: ", err)
+			continue
+		}
+		approvals = append(approvals, a)
+	}
+	return c.JSON(http.StatusOK, approvals)
+}
+
+func GetApproval(c echo.Context) error {
+	id := c.Param("id")
+
+	row := db.GetDB().QueryRow(`SELECT `+approvalColumns+`
+		FROM workflow_approvals a
+		JOIN workflows w ON a.workflow_id = w.id
+		JOIN workflow_executions we ON a.execution_id = we.id
+		WHERE a.id = $1`, id)
+	a, err := scanApproval(row)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Approval not found"})
+	} else if err != nil {
+		c.Logger().Error("Failed to get approval: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get approval"})
+	}
+	return c.JSON(http.StatusOK, a)
+}
+
+// ApprovalDecision is the body of the approve and reject endpoints
+type ApprovalDecision struct {
+	DecidedBy *int                   `json:"decided_by"`
+	Comment   string                 `json:"comment"`
+	Context   map[string]interface{} `json:"context,omitempty"` // Context edits, if the node allows them
+}
+
+func ApproveApproval(c echo.Context) error {
+	return decideApproval(c, "APPROVED", workflows.HandleApproved)
+}
+
+func RejectApproval(c echo.Context) error {
+	return decideApproval(c, "REJECTED", workflows.HandleRejected)
+}
+
+// decideApproval records the decision and resumes the execution down the matching handle
+func decideApproval(c echo.Context, status string, handle string) error {
+	id := c.Param("id")
+	var req ApprovalDecision
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	defer tx.Rollback()
+
+	var executionID int
+	var nodeID string
+	var allowEdits bool
+	err = tx.QueryRow(`
+		UPDATE workflow_approvals
+		SET status = $2, comment = NULLIF($3, ''), decided_by = $4, decided_at = NOW()
+		WHERE id = $1 AND status = 'PENDING'
+		RETURNING execution_id, node_id, allow_context_edits
+	`, id, status, req.Comment, req.DecidedBy).Scan(&executionID, &nodeID, &allowEdits)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Approval not found or already decided"})
+	} else if err != nil {
+		c.Logger().Error("Failed to record approval decision: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record decision"})
+	}
+
+	if len(req.Context) > 0 && !allowEdits {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "This approval does not allow context edits"})
+	}
+
+	if err := scheduler.ResumeExecution(tx, executionID, nodeID, handle, req.Context); err != nil {
+		if err == scheduler.ErrNotWaiting {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		c.Logger().Error("Failed to resume execution: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to resume execution"})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record decision"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"status": status, "execution_id": executionID})
+}
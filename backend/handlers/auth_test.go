@@ -17,6 +17,7 @@ func TestRegister(t *testing.T) {
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
+	_ = c
 
 	// Note: This test will fail without a real DB connection mocked in handlers.
 	// In a real app, we'd mock the DB interface.
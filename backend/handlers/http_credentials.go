@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/outbound"
+	"github.com/wesuuu/helpnow/backend/secrets"
+)
+
+// HTTPCredential is the API view of a stored credential. Secret and Username are
+// write-only and never returned.
+type HTTPCredential struct {
+	ID             int       `json:"id"`
+	OrganizationID int       `json:"organization_id"`
+	Name           string    `json:"name"`
+	AuthType       string    `json:"auth_type"` // bearer, basic or header
+	HeaderName     string    `json:"header_name,omitempty"`
+	Username       string    `json:"username,omitempty"`
+	Secret         string    `json:"secret,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func CreateHTTPCredential(c echo.Context) error {
+	var cred HTTPCredential
+	if err := c.Bind(&cred); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+	}
+
+	// Hardcoded org for now, or get from context
+	cred.OrganizationID = 1
+
+	if cred.Name == "" || cred.Secret == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name and secret are required"})
+	}
+	switch cred.AuthType {
+	case outbound.AuthBearer:
+	case outbound.AuthBasic:
+		if cred.Username == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "username is required for basic auth"})
+		}
+	case outbound.AuthHeader:
+		if cred.HeaderName == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "header_name is required for header auth"})
+		}
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "auth_type must be one of: bearer basic header"})
+	}
+
+	if secrets.GlobalSecretStore == nil {
+		log.Println("Error: Secret Store not configured")
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal resource not available"})
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO http_credentials (organization_id, name, auth_type, header_name) VALUES ($1, $2, $3, NULLIF($4, '')) RETURNING id, created_at
+	`, cred.OrganizationID, cred.Name, cred.AuthType, cred.HeaderName).Scan(&cred.ID, &cred.CreatedAt)
+	if err != nil {
+		log.Println("Error inserting HTTP credential:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create credential"})
+	}
+
+	secretData := map[string]interface{}{
+		"secret":   cred.Secret,
+		"username": cred.Username,
+	}
+	if err := secrets.GlobalSecretStore.Write(c.Request().Context(), outbound.CredentialSecretPath(cred.ID), secretData); err != nil {
+		log.Println("Error writing credential secret:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to secure credential"})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Transaction failed"})
+	}
+
+	// Mask secret in response
+	cred.Secret = ""
+	cred.Username = ""
+	return c.JSON(http.StatusCreated, cred)
+}
+
+func ListHTTPCredentials(c echo.Context) error {
+	orgID := 1 // Hardcoded
+	rows, err := db.GetDB().Query(`
+		SELECT id, organization_id, name, auth_type, COALESCE(header_name, ''), created_at FROM http_credentials WHERE organization_id = $1 ORDER BY name
+	`, orgID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list credentials"})
+	}
+	defer rows.Close()
+
+	creds := []HTTPCredential{}
+	for rows.Next() {
+		var cred HTTPCredential
+		if err := rows.Scan(&cred.ID, &cred.OrganizationID, &cred.Name, &cred.AuthType, &cred.HeaderName, &cred.CreatedAt); err == nil {
+			creds = append(creds, cred)
+		}
+	}
+	return c.JSON(http.StatusOK, creds)
+}
+
+func DeleteHTTPCredential(c echo.Context) error {
+	id := c.Param("id")
+	orgID := 1 // Hardcoded
+
+	var credID int
+	err := db.GetDB().QueryRow(`DELETE FROM http_credentials WHERE id = $1 AND organization_id = $2 RETURNING id`, id, orgID).Scan(&credID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Credential not found"})
+	}
+
+	if secrets.GlobalSecretStore != nil {
+		if err := secrets.GlobalSecretStore.Delete(c.Request().Context(), outbound.CredentialSecretPath(credID)); err != nil {
+			log.Println("Error deleting credential secret:", err)
+		}
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetHTTPAllowlist returns the internal hosts and ranges the organization's HTTP
+// Request actions may reach
+func GetHTTPAllowlist(c echo.Context) error {
+	id := c.Param("id")
+
+	var allowlist []string
+	err := db.GetDB().QueryRow(`SELECT COALESCE(http_allowlist, '{}') FROM organizations WHERE id = $1`, id).Scan(pq.Array(&allowlist))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Organization not found"})
+	}
+	return c.JSON(http.StatusOK, map[string][]string{"http_allowlist": allowlist})
+}
+
+func UpdateHTTPAllowlist(c echo.Context) error {
+	id := c.Param("id")
+	var req struct {
+		HTTPAllowlist []string `json:"http_allowlist"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+	}
+	for _, entry := range req.HTTPAllowlist {
+		if err := outbound.ValidateAllowlistEntry(entry); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+	}
+	if req.HTTPAllowlist == nil {
+		req.HTTPAllowlist = []string{}
+	}
+
+	res, err := db.GetDB().Exec(`UPDATE organizations SET http_allowlist = $1 WHERE id = $2`, pq.Array(req.HTTPAllowlist), id)
+	if err != nil {
+		c.Logger().Error("Failed to update allowlist: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update allowlist"})
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Organization not found"})
+	}
+	return c.JSON(http.StatusOK, map[string][]string{"http_allowlist": req.HTTPAllowlist})
+}
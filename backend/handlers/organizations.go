@@ -7,6 +7,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/wesuuu/helpnow/backend/db"
 	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/workflows"
 )
 
 func GetOrganization(c echo.Context) error {
@@ -47,3 +48,76 @@ func UpdateOrganization(c echo.Context) error {
 	org.SystemPrompt = systemPrompt.String
 	return c.JSON(http.StatusOK, org)
 }
+
+// GetMessageCap returns the most messages one person may receive from the
+// organization's workflows in 24 hours, 0 meaning no cap
+func GetMessageCap(c echo.Context) error {
+	id := c.Param("id")
+
+	var dailyCap int
+	err := db.GetDB().QueryRow(`SELECT COALESCE(daily_message_cap, 0) FROM organizations WHERE id = $1`, id).Scan(&dailyCap)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Organization not found"})
+	}
+	return c.JSON(http.StatusOK, map[string]int{"daily_message_cap": dailyCap})
+}
+
+func UpdateMessageCap(c echo.Context) error {
+	id := c.Param("id")
+	var req struct {
+		DailyMessageCap int `json:"daily_message_cap"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+	}
+	if req.DailyMessageCap < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "daily_message_cap must be 0 or more"})
+	}
+
+	res, err := db.GetDB().Exec(`UPDATE organizations SET daily_message_cap = $1 WHERE id = $2`, req.DailyMessageCap, id)
+	if err != nil {
+		c.Logger().Error("Failed to update message cap: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update message cap"})
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Organization not found"})
+	}
+	return c.JSON(http.StatusOK, map[string]int{"daily_message_cap": req.DailyMessageCap})
+}
+
+// GetSendWindow returns the organization's send window, which workflows without
+// their own keep messages to; null when messages may go out at any hour
+func GetSendWindow(c echo.Context) error {
+	id := c.Param("id")
+
+	var window []byte
+	err := db.GetDB().QueryRow(`SELECT send_window FROM organizations WHERE id = $1`, id).Scan(&window)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Organization not found"})
+	}
+	return c.JSON(http.StatusOK, map[string]*workflows.SendWindow{"send_window": parseSendWindow(window)})
+}
+
+func UpdateSendWindow(c echo.Context) error {
+	id := c.Param("id")
+	var req struct {
+		SendWindow *workflows.SendWindow `json:"send_window"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+	}
+	window, err := sendWindowJSON(req.SendWindow)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	res, err := db.GetDB().Exec(`UPDATE organizations SET send_window = $1 WHERE id = $2`, window, id)
+	if err != nil {
+		c.Logger().Error("Failed to update send window: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update send window"})
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Organization not found"})
+	}
+	return c.JSON(http.StatusOK, map[string]*workflows.SendWindow{"send_window": req.SendWindow})
+}
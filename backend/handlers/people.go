@@ -3,13 +3,14 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
-
+	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/lib/pq"
 	"github.com/wesuuu/helpnow/backend/db"
 	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/scheduler"
 )
 
 func getIP(c echo.Context) string {
@@ -76,6 +77,11 @@ func CreatePerson(c echo.Context) error {
 	if err := c.Bind(&person); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
 	}
+	if person.TimeZone != "" {
+		if _, err := time.LoadLocation(person.TimeZone); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Unknown timezone " + person.TimeZone})
+		}
+	}
 
 	// Initialize Meta
 	person.Meta = models.PersonMeta{
@@ -88,13 +94,13 @@ func CreatePerson(c echo.Context) error {
 	metaJSON, _ := json.Marshal(person.Meta)
 
 	query := `
-		INSERT INTO people (organization_id, first_name, last_name, email, age, ethnicity, gender, location, last_interaction_at, score, interests, meta)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		INSERT INTO people (organization_id, first_name, last_name, email, age, ethnicity, gender, location, last_interaction_at, score, interests, meta, timezone)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, ''))
 		RETURNING id, created_at`
 
 	err := db.GetDB().QueryRow(query,
 		person.OrganizationID, person.FirstName, person.LastName, person.Email,
-		person.Age, person.Ethnicity, person.Gender, person.Location, person.LastInteractionAt, person.Score, pq.Array(person.Interests), metaJSON,
+		person.Age, person.Ethnicity, person.Gender, person.Location, person.LastInteractionAt, person.Score, pq.Array(person.Interests), metaJSON, person.TimeZone,
 	).Scan(&person.ID, &person.CreatedAt)
 
 	if err != nil {
@@ -114,11 +120,11 @@ func ListPeople(c echo.Context) error {
 	// Optional filter by audience
 	audienceID := c.QueryParam("audience_id")
 
-	query := `SELECT id, organization_id, first_name, last_name, email, age, ethnicity, gender, location, last_interaction_at, score, interests, COALESCE(meta::text, '{}'), created_at FROM people WHERE organization_id = $1`
+	query := `SELECT id, organization_id, first_name, last_name, email, age, ethnicity, gender, location, last_interaction_at, score, interests, COALESCE(meta::text, '{}'), COALESCE(timezone, ''), created_at FROM people WHERE organization_id = $1`
 	args := []interface{}{orgID}
 
 	if audienceID != "" {
-		query = `SELECT p.id, p.organization_id, p.first_name, p.last_name, p.email, p.age, p.ethnicity, p.gender, p.location, p.last_interaction_at, p.score, p.interests, COALESCE(p.meta::text, '{}'), p.created_at 
+		query = `SELECT p.id, p.organization_id, p.first_name, p.last_name, p.email, p.age, p.ethnicity, p.gender, p.location, p.last_interaction_at, p.score, p.interests, COALESCE(p.meta::text, '{}'), COALESCE(p.timezone, ''), p.created_at 
 				 FROM people p
 				 JOIN audience_memberships am ON p.id = am.person_id
 				 WHERE p.organization_id = $1 AND am.audience_id = $2`
@@ -136,7 +142,7 @@ func ListPeople(c echo.Context) error {
 	for rows.Next() {
 		var p models.Person
 		var metaString string
-		if err := rows.Scan(&p.ID, &p.OrganizationID, &p.FirstName, &p.LastName, &p.Email, &p.Age, &p.Ethnicity, &p.Gender, &p.Location, &p.LastInteractionAt, &p.Score, pq.Array(&p.Interests), &metaString, &p.CreatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.OrganizationID, &p.FirstName, &p.LastName, &p.Email, &p.Age, &p.Ethnicity, &p.Gender, &p.Location, &p.LastInteractionAt, &p.Score, pq.Array(&p.Interests), &metaString, &p.TimeZone, &p.CreatedAt); err != nil {
 			continue
 		}
 		if metaString != "" {
@@ -156,9 +162,9 @@ func GetPerson(c echo.Context) error {
 
 	var p models.Person
 	var metaString string
-	query := `SELECT id, organization_id, first_name, last_name, email, age, ethnicity, gender, location, last_interaction_at, score, interests, COALESCE(meta::text, '{}'), created_at FROM people WHERE id = $1`
+	query := `SELECT id, organization_id, first_name, last_name, email, age, ethnicity, gender, location, last_interaction_at, score, interests, COALESCE(meta::text, '{}'), COALESCE(timezone, ''), created_at FROM people WHERE id = $1`
 
-	err := db.GetDB().QueryRow(query, id).Scan(&p.ID, &p.OrganizationID, &p.FirstName, &p.LastName, &p.Email, &p.Age, &p.Ethnicity, &p.Gender, &p.Location, &p.LastInteractionAt, &p.Score, pq.Array(&p.Interests), &metaString, &p.CreatedAt)
+	err := db.GetDB().QueryRow(query, id).Scan(&p.ID, &p.OrganizationID, &p.FirstName, &p.LastName, &p.Email, &p.Age, &p.Ethnicity, &p.Gender, &p.Location, &p.LastInteractionAt, &p.Score, pq.Array(&p.Interests), &metaString, &p.TimeZone, &p.CreatedAt)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Person not found"})
 	}
@@ -200,6 +206,13 @@ func AddPersonToAudience(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to add person to audience"})
 	}
 
+	// Joining the audience may be a workflow's goal
+	if id, err := strconv.Atoi(audienceID); err == nil {
+		if _, err := scheduler.ReachGoalAudience(req.PersonID, id); err != nil {
+			c.Logger().Error("Failed to exit executions that reached their goal: ", err)
+		}
+	}
+
 	return c.JSON(http.StatusOK, map[string]string{"status": "added"})
 }
 
@@ -234,5 +247,31 @@ func AppendPersonEvent(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update history"})
 	}
 
+	// Resume executions waiting for this person to do this, and exit the ones it is
+	// the goal of
+	if event, ok := req.Event.(map[string]interface{}); ok {
+		if name := personEventName(event); name != "" {
+			if personID, err := strconv.Atoi(id); err == nil {
+				if _, err := scheduler.DeliverEvent(personID, name, event); err != nil {
+					c.Logger().Error("Failed to deliver event to waiting executions: ", err)
+				}
+				if _, err := scheduler.ReachGoalEvent(personID, name, event); err != nil {
+					c.Logger().Error("Failed to exit executions that reached their goal: ", err)
+				}
+			}
+		}
+	}
+
 	return c.JSON(http.StatusOK, map[string]string{"status": "updated"})
 }
+
+// personEventName returns the name of an appended event, which clients send as
+// "name", "event_type" or "type"
+func personEventName(event map[string]interface{}) string {
+	for _, key := range []string{"name", "event_type", "type"} {
+		if name, ok := event[key].(string); ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/wesuuu/helpnow/backend/db"
 	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/scheduler"
 )
 
 func generateToken() string {
@@ -108,6 +109,8 @@ func CaptureLead(c echo.Context) error {
 	_, err = db.GetDB().Exec("INSERT INTO audience_memberships (audience_id, person_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", audienceID, personID)
 	if err != nil {
 		c.Logger().Error("Failed to add lead to audience: ", err)
+	} else if _, err := scheduler.ReachGoalAudience(personID, audienceID); err != nil {
+		c.Logger().Error("Failed to exit executions that reached their goal: ", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"status": "success", "message": "Lead captured"})
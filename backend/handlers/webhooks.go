@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+)
+
+const (
+	// Largest webhook body accepted
+	maxWebhookBodySize = 1024 * 1024
+	// How far a delivery's timestamp may be from the server clock. Signatures seen
+	// within this window are remembered so a captured request cannot be replayed.
+	webhookTolerance = 5 * time.Minute
+
+	webhookTimestampHeader = "X-Helpnow-Timestamp"
+	webhookSignatureHeader = "X-Helpnow-Signature"
+)
+
+var (
+	errWebhookMissingHeaders = errors.New("missing timestamp or signature header")
+	errWebhookStale          = errors.New("timestamp is outside the allowed window")
+	errWebhookBadSignature   = errors.New("signature does not match")
+)
+
+// WebhookEndpoint is the public URL issued to a WEBHOOK trigger node
+type WebhookEndpoint struct {
+	NodeID string `json:"node_id"`
+	Token  string `json:"token"`
+	URL    string `json:"url"`
+}
+
+func webhookPath(token string) string {
+	return "/public/hooks/" + token
+}
+
+// signWebhook returns the signature header value for a delivery:
+// sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyWebhookSignature checks the timestamp is fresh and the signature was made
+// with secret over this timestamp and body
+func verifyWebhookSignature(secret, timestamp, signature string, body []byte, now time.Time) error {
+	if timestamp == "" || signature == "" {
+		return errWebhookMissingHeaders
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errWebhookStale
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > webhookTolerance || age < -webhookTolerance {
+		return errWebhookStale
+	}
+	expected := signWebhook(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return errWebhookBadSignature
+	}
+	return nil
+}
+
+// syncWebhookTokens issues a token to each WEBHOOK trigger that lacks one and drops
+// tokens of nodes no longer in the graph. Existing tokens are kept so a node's URL
+// survives edits to the workflow.
+func syncWebhookTokens(tx *sql.Tx, workflowID int, triggers []triggerRow) error {
+	nodeIDs := []string{}
+	for _, t := range triggers {
+		if t.Type != string(models.TriggerTypeWebhook) {
+			continue
+		}
+		nodeIDs = append(nodeIDs, t.NodeID)
+		_, err := tx.Exec(`
+			INSERT INTO webhook_tokens (token, workflow_id, node_id) VALUES ($1, $2, $3)
+			ON CONFLICT (workflow_id, node_id) DO NOTHING
+		`, generateToken(), workflowID, t.NodeID)
+		if err != nil {
+			return fmt.Errorf("failed to issue webhook token for %s: %w", t.NodeID, err)
+		}
+	}
+
+	_, err := tx.Exec(`DELETE FROM webhook_tokens WHERE workflow_id = $1 AND NOT (node_id = ANY($2))`, workflowID, pq.Array(nodeIDs))
+	if err != nil {
+		return fmt.Errorf("failed to remove stale webhook tokens: %w", err)
+	}
+	return nil
+}
+
+// ListWorkflowWebhooks returns the public URLs of a workflow's webhook triggers
+func ListWorkflowWebhooks(c echo.Context) error {
+	workflowID := c.Param("id")
+
+	rows, err := db.GetDB().Query(`SELECT node_id, token FROM webhook_tokens WHERE workflow_id = $1 ORDER BY node_id`, workflowID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list webhooks"})
+	}
+	defer rows.Close()
+
+	endpoints := []WebhookEndpoint{}
+	for rows.Next() {
+		var ep WebhookEndpoint
+		if err := rows.Scan(&ep.NodeID, &ep.Token); err == nil {
+			ep.URL = webhookPath(ep.Token)
+			endpoints = append(endpoints, ep)
+		}
+	}
+	return c.JSON(http.StatusOK, endpoints)
+}
+
+// ReceiveWebhook verifies a signed delivery to a webhook trigger and starts an
+// execution at the trigger node with the JSON body as its context
+func ReceiveWebhook(c echo.Context) error {
+	token := c.Param("trigger_token")
+
+	var workflowID int
+	var nodeID, configStr, status string
+	err := db.GetDB().QueryRow(`
+		SELECT wt.workflow_id, wt.node_id, COALESCE(wt.config, '{}'), COALESCE(w.status, 'ACTIVE')
+		FROM webhook_tokens t
+		JOIN workflow_triggers wt ON wt.workflow_id = t.workflow_id AND wt.node_id = t.node_id AND wt.type = $2
+		JOIN workflows w ON w.id = t.workflow_id
+		WHERE t.token = $1
+	`, token, string(models.TriggerTypeWebhook)).Scan(&workflowID, &nodeID, &configStr, &status)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Unknown webhook"})
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWebhookBodySize+1))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to read body"})
+	}
+	if len(body) > maxWebhookBodySize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("Body exceeds %d bytes", maxWebhookBodySize)})
+	}
+
+	var config struct {
+		Secret string `json:"secret"`
+	}
+	if err := json.Unmarshal([]byte(configStr), &config); err != nil || config.Secret == "" {
+		c.Logger().Error("Webhook trigger has no secret: workflow ", workflowID, " node ", nodeID)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Webhook is not configured for signed requests"})
+	}
+
+	signature := c.Request().Header.Get(webhookSignatureHeader)
+	err = verifyWebhookSignature(config.Secret, c.Request().Header.Get(webhookTimestampHeader), signature, body, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid webhook: " + err.Error()})
+	}
+
+	if status != "ACTIVE" {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Workflow is not active"})
+	}
+
+	contextData := map[string]interface{}{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &contextData); err != nil || contextData == nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Body must be a JSON object"})
+		}
+	}
+	contextJSON, _ := json.Marshal(contextData)
+
+	// Signatures older than the replay window can no longer pass the timestamp check
+	if _, err := db.GetDB().Exec(`DELETE FROM webhook_deliveries WHERE received_at < NOW() - $1::interval`, fmt.Sprintf("%d seconds", int(2*webhookTolerance/time.Second))); err != nil {
+		c.Logger().Error("Failed to prune webhook deliveries:", err)
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	defer tx.Rollback()
+
+	// Remember the signature so a second delivery of the same signed request is rejected
+	var inserted string
+	err = tx.QueryRow(`
+		INSERT INTO webhook_deliveries (token, signature) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING RETURNING signature
+	`, token, strings.ToLower(signature)).Scan(&inserted)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Duplicate delivery"})
+	}
+	if err != nil {
+		c.Logger().Error("Failed to record webhook delivery:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+
+	var executionID int
+	err = tx.QueryRow(`
+		INSERT INTO workflow_executions (workflow_id, version_id, current_node_id, status, context, next_run_at, priority)
+		SELECT id, published_version_id, $2, 'PENDING', $3, NOW(), COALESCE(priority, 0) FROM workflows WHERE id = $1
+		RETURNING id
+	`, workflowID, nodeID, string(contextJSON)).Scan(&executionID)
+	if err != nil {
+		c.Logger().Error("Failed to start webhook execution:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start workflow"})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Transaction failed"})
+	}
+	return c.JSON(http.StatusAccepted, map[string]int{"execution_id": executionID})
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	secret := "s3cret-key"
+	body := []byte(`{"email":"a@example.com"}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	valid := signWebhook(secret, ts, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		want      error
+	}{
+		{"valid", secret, ts, valid, body, nil},
+		{"uppercase hex", secret, ts, "sha256=" + strings.ToUpper(strings.TrimPrefix(valid, "sha256=")), body, nil},
+		{"missing signature", secret, ts, "", body, errWebhookMissingHeaders},
+		{"missing timestamp", secret, "", valid, body, errWebhookMissingHeaders},
+		{"wrong secret", "other-secret", ts, valid, body, errWebhookBadSignature},
+		{"tampered body", secret, ts, valid, []byte(`{"email":"b@example.com"}`), errWebhookBadSignature},
+		{"stale", secret, strconv.FormatInt(now.Add(-6*time.Minute).Unix(), 10), valid, body, errWebhookStale},
+		{"future", secret, strconv.FormatInt(now.Add(6*time.Minute).Unix(), 10), valid, body, errWebhookStale},
+		{"not a number", secret, "yesterday", valid, body, errWebhookStale},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyWebhookSignature(tt.secret, tt.timestamp, tt.signature, tt.body, now); got != tt.want {
+				t.Errorf("verifyWebhookSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
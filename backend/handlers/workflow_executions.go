@@ -0,0 +1,466 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/scheduler"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// executionColumns is the column list scanned by scanExecution
+const executionColumns = `we.id, we.workflow_id, (SELECT version FROM workflow_versions WHERE id = we.version_id), we.fork_id, we.branch, we.parent_execution_id, we.parent_node_id, COALESCE(we.depth, 0), we.subject_id, we.current_node_id, we.status, COALESCE(we.attempt, 0), we.result, we.next_run_at, CASE WHEN we.status = 'PENDING' AND we.next_run_at > NOW() THEN we.next_run_at END, we.context, we.step_results, we.created_at, we.finished_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanExecution(row rowScanner) (workflows.WorkflowExecution, error) {
+	var e workflows.WorkflowExecution
+	var contextStr, resultsStr sql.NullString
+	err := row.Scan(&e.ID, &e.WorkflowID, &e.Version, &e.ForkID, &e.Branch, &e.ParentID, &e.ParentNodeID, &e.Depth, &e.SubjectID, &e.CurrentNodeID, &e.Status, &e.Attempt, &e.Result, &e.NextRunAt, &e.WakeAt, &contextStr, &resultsStr, &e.CreatedAt, &e.FinishedAt)
+	if err != nil {
+		return e, err
+	}
+
+	e.Context = map[string]interface{}{}
+	if contextStr.Valid && contextStr.String != "" {
+		json.Unmarshal([]byte(contextStr.String), &e.Context)
+	}
+	e.StepResults = []workflows.StepResult{}
+	if resultsStr.Valid && resultsStr.String != "" {
+		json.Unmarshal([]byte(resultsStr.String), &e.StepResults)
+	}
+	return e, nil
+}
+
+// ListDeadLetterExecutions returns executions whose retry policy gave up
+func ListDeadLetterExecutions(c echo.Context) error {
+	query := `SELECT ` + executionColumns + ` FROM workflow_executions we WHERE we.status = $1`
+	args := []interface{}{string(models.StatusDeadLetter)}
+
+	if workflowID := c.QueryParam("workflow_id"); workflowID != "" {
+		query += ` AND we.workflow_id = $2`
+		args = append(args, workflowID)
+	}
+	query += ` ORDER BY we.finished_at DESC LIMIT 200`
+
+	rows, err := db.GetDB().Query(query, args...)
+	if err != nil {
+		c.Logger().Error("Failed to list dead-letter executions: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list executions"})
+	}
+	defer rows.Close()
+
+	executions := []workflows.WorkflowExecution{}
+	for rows.Next() {
+		e, err := scanExecution(rows)
+		if err != nil {
+			c.Logger().Error("Scan error: ", err)
+			continue
+		}
+		executions = append(executions, e)
+	}
+	return c.JSON(http.StatusOK, executions)
+}
+
+// stuckWorkflow is one row of the stuck-executions report
+type stuckWorkflow struct {
+	WorkflowID       int            `json:"workflow_id"`
+	WorkflowName     string         `json:"workflow_name"`
+	MaxDurationHours int            `json:"max_duration_hours"` // Limit the reaper applies; 0 for none
+	Stuck            int            `json:"stuck"`
+	ByStatus         map[string]int `json:"by_status"`
+	Overdue          int            `json:"overdue"`    // PENDING and due over an hour ago, yet not picked up
+	OverLimit        int            `json:"over_limit"` // Past the max duration, to be timed out on the reaper's next pass
+	OldestStartedAt  time.Time      `json:"oldest_started_at"`
+}
+
+// ListStuckExecutions reports, per workflow, the unfinished executions that
+// started over older_than_hours ago (default 24) or are overdue to run, so
+// operators can spot workflows that pile up. Parallel branches are left out.
+func ListStuckExecutions(c echo.Context) error {
+	olderThan := 24
+	if raw := c.QueryParam("older_than_hours"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "older_than_hours must be 0 or more"})
+		}
+		olderThan = n
+	}
+
+	rows, err := db.GetDB().Query(`
+		SELECT w.id, w.name, COALESCE(w.max_duration_hours, $3), we.status, COUNT(*),
+			COUNT(*) FILTER (WHERE we.status = 'PENDING' AND we.next_run_at < NOW() - INTERVAL '1 hour'),
+			COUNT(*) FILTER (WHERE COALESCE(w.max_duration_hours, $3) > 0
+				AND we.created_at < NOW() - make_interval(hours => COALESCE(w.max_duration_hours, $3))),
+			MIN(we.created_at)
+		FROM workflow_executions we
+		JOIN workflows w ON w.id = we.workflow_id
+		WHERE we.status = ANY($1) AND we.fork_id IS NULL
+		AND (we.created_at < NOW() - make_interval(hours => $2)
+			OR (we.status = 'PENDING' AND we.next_run_at < NOW() - INTERVAL '1 hour'))
+		GROUP BY w.id, w.name, w.max_duration_hours, we.status
+		ORDER BY w.id
+	`, pq.Array(models.ActiveStatuses), olderThan, scheduler.DefaultMaxDurationHours)
+	if err != nil {
+		c.Logger().Error("Failed to report stuck executions: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to report stuck executions"})
+	}
+	defer rows.Close()
+
+	report := []*stuckWorkflow{}
+	for rows.Next() {
+		var row stuckWorkflow
+		var status string
+		var count, overdue, overLimit int
+		if err := rows.Scan(&row.WorkflowID, &row.WorkflowName, &row.MaxDurationHours, &status, &count, &overdue, &overLimit, &row.OldestStartedAt); err != nil {
+			c.Logger().Error("Scan error: ", err)
+			continue
+		}
+		if n := len(report); n == 0 || report[n-1].WorkflowID != row.WorkflowID {
+			row.ByStatus = map[string]int{}
+			report = append(report, &row)
+		}
+		wf := report[len(report)-1]
+		wf.Stuck += count
+		wf.ByStatus[status] = count
+		wf.Overdue += overdue
+		wf.OverLimit += overLimit
+		if row.OldestStartedAt.Before(wf.OldestStartedAt) {
+			wf.OldestStartedAt = row.OldestStartedAt
+		}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"older_than_hours": olderThan, "workflows": report})
+}
+
+// parseTimeParam accepts an RFC 3339 timestamp or a YYYY-MM-DD date
+func parseTimeParam(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
+
+// ListExecutions returns executions, newest first. Filters: workflow_id, status
+// (comma-separated), subject_id, parent_execution_id, from and to (created_at, RFC 3339 or YYYY-MM-DD),
+// plus limit (default 50, max 500) and offset. Parallel branches are left out
+// unless fork_id asks for the branches of one execution.
+func ListExecutions(c echo.Context) error {
+	query, args, err := executionsQuery(c.QueryParams())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	rows, err := db.GetDB().Query(query, args...)
+	if err != nil {
+		c.Logger().Error("Failed to list executions: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list executions"})
+	}
+	defer rows.Close()
+
+	executions := []workflows.WorkflowExecution{}
+	for rows.Next() {
+		e, err := scanExecution(rows)
+		if err != nil {
+			c.Logger().Error("Scan error: ", err)
+			continue
+		}
+		executions = append(executions, e)
+	}
+	return c.JSON(http.StatusOK, executions)
+}
+
+// executionsQuery builds the ListExecutions query from its filters
+func executionsQuery(params url.Values) (string, []interface{}, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	for _, param := range []string{"workflow_id", "subject_id", "parent_execution_id"} {
+		if raw := params.Get(param); raw != "" {
+			id, err := strconv.Atoi(raw)
+			if err != nil {
+				return "", nil, fmt.Errorf("%s must be a number", param)
+			}
+			addCondition("we."+param+" = $%d", id)
+		}
+	}
+	// Parallel branches are listed under the execution that forked them
+	if raw := params.Get("fork_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			return "", nil, errors.New("fork_id must be a number")
+		}
+		addCondition("we.fork_id = $%d", id)
+	} else {
+		conditions = append(conditions, "we.fork_id IS NULL")
+	}
+	if raw := params.Get("status"); raw != "" {
+		statuses := strings.Split(strings.ToUpper(raw), ",")
+		for i := range statuses {
+			statuses[i] = strings.TrimSpace(statuses[i])
+			if !slices.Contains(models.ExecutionStatuses, statuses[i]) {
+				return "", nil, fmt.Errorf("unknown status %q", statuses[i])
+			}
+		}
+		addCondition("we.status = ANY($%d)", pq.Array(statuses))
+	}
+	if raw := params.Get("from"); raw != "" {
+		from, err := parseTimeParam(raw)
+		if err != nil {
+			return "", nil, errors.New("from must be RFC 3339 or YYYY-MM-DD")
+		}
+		addCondition("we.created_at >= $%d", from)
+	}
+	if raw := params.Get("to"); raw != "" {
+		to, err := parseTimeParam(raw)
+		if err != nil {
+			return "", nil, errors.New("to must be RFC 3339 or YYYY-MM-DD")
+		}
+		if len(raw) == len("2006-01-02") {
+			// A bare date includes the whole day
+			to = to.AddDate(0, 0, 1)
+		}
+		addCondition("we.created_at < $%d", to)
+	}
+
+	limit, offset := 50, 0
+	if raw := params.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 500 {
+			return "", nil, errors.New("limit must be between 1 and 500")
+		}
+		limit = n
+	}
+	if raw := params.Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return "", nil, errors.New("offset must be a positive number")
+		}
+		offset = n
+	}
+
+	query := `SELECT ` + executionColumns + ` FROM workflow_executions we`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(` ORDER BY we.created_at DESC, we.id DESC LIMIT %d OFFSET %d`, limit, offset)
+	return query, args, nil
+}
+
+// GetExecution returns one execution with its decoded context and step results
+func GetExecution(c echo.Context) error {
+	id := c.Param("id")
+
+	e, err := scanExecution(db.GetDB().QueryRow(`SELECT `+executionColumns+` FROM workflow_executions we WHERE we.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Execution not found"})
+	}
+	if err != nil {
+		c.Logger().Error("Failed to get execution: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get execution"})
+	}
+
+	rows, err := db.GetDB().Query(`SELECT id FROM workflow_executions WHERE parent_execution_id = $1 ORDER BY id`, e.ID)
+	if err != nil {
+		c.Logger().Error("Failed to list child executions: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get execution"})
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var childID int
+		if err := rows.Scan(&childID); err == nil {
+			e.ChildIDs = append(e.ChildIDs, childID)
+		}
+	}
+	return c.JSON(http.StatusOK, e)
+}
+
+// cancelReason builds the result recorded on cancelled executions
+func cancelReason(c echo.Context) string {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	c.Bind(&req)
+	if req.Reason != "" {
+		return "Cancelled: " + req.Reason
+	}
+	return "Cancelled"
+}
+
+// CancelExecution stops an execution that is pending or waiting. Releasing the
+// lease fences off a worker that is running a step: it cannot advance the
+// execution afterwards, though the step itself may still finish.
+func CancelExecution(c echo.Context) error {
+	id := c.Param("id")
+	reason := cancelReason(c)
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	defer tx.Rollback()
+
+	e, err := scanExecution(tx.QueryRow(`
+		UPDATE workflow_executions we
+		SET status = $2, result = $3, finished_at = NOW(), resume_handle = NULL, locked_by = NULL, lease_expires_at = NULL
+		WHERE we.id = $1 AND we.status = ANY($4)
+		RETURNING `+executionColumns,
+		id, string(models.StatusCancelled), reason, pq.Array(models.ActiveStatuses)))
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Execution not found or already finished"})
+	}
+	if err != nil {
+		c.Logger().Error("Failed to cancel execution: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel execution"})
+	}
+
+	if err := scheduler.CancelWaits(tx, []int{e.ID}); err != nil {
+		c.Logger().Error("Failed to cancel approvals and event waits: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel execution"})
+	}
+
+	// Parallel branches go down with the execution that forked them, and a
+	// cancelled branch may be the last one its parent was waiting on
+	if err := scheduler.CancelBranches(tx, e.ID, reason); err != nil {
+		c.Logger().Error("Failed to cancel branches: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel execution"})
+	}
+	if e.ForkID != nil {
+		if err := scheduler.SettleFork(tx, *e.ForkID); err != nil {
+			c.Logger().Error("Failed to settle branches: ", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel execution"})
+		}
+	}
+	// A parent waiting on this execution carries on with its CANCELLED status
+	if err := scheduler.ResumeParent(tx, e.ID); err != nil {
+		c.Logger().Error("Failed to resume parent execution: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel execution"})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Transaction failed"})
+	}
+	return c.JSON(http.StatusOK, e)
+}
+
+// CancelWorkflowExecutions cancels every unfinished execution of a workflow
+func CancelWorkflowExecutions(c echo.Context) error {
+	workflowID := c.Param("id")
+	reason := cancelReason(c)
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		UPDATE workflow_executions
+		SET status = $2, result = $3, finished_at = NOW(), resume_handle = NULL, locked_by = NULL, lease_expires_at = NULL
+		WHERE workflow_id = $1 AND status = ANY($4)
+		RETURNING id, fork_id
+	`, workflowID, string(models.StatusCancelled), reason, pq.Array(models.ActiveStatuses))
+	if err != nil {
+		c.Logger().Error("Failed to cancel executions: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel executions"})
+	}
+	type cancelledRow struct {
+		id     int
+		forkID sql.NullInt64
+	}
+	cancelledRows := []cancelledRow{}
+	ids := []int{}
+	for rows.Next() {
+		var r cancelledRow
+		if err := rows.Scan(&r.id, &r.forkID); err != nil {
+			rows.Close()
+			c.Logger().Error("Failed to cancel executions: ", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel executions"})
+		}
+		cancelledRows = append(cancelledRows, r)
+		ids = append(ids, r.id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.Logger().Error("Failed to cancel executions: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel executions"})
+	}
+	cancelled := int64(len(cancelledRows))
+
+	if err := scheduler.CancelWaits(tx, ids); err != nil {
+		c.Logger().Error("Failed to cancel approvals and event waits: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel executions"})
+	}
+
+	// As in CancelExecution: branches go down with their execution, and parents
+	// in other workflows waiting on a cancelled execution carry on
+	for _, r := range cancelledRows {
+		if err := scheduler.CancelBranches(tx, r.id, reason); err != nil {
+			c.Logger().Error("Failed to cancel branches: ", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel executions"})
+		}
+		if r.forkID.Valid {
+			if err := scheduler.SettleFork(tx, int(r.forkID.Int64)); err != nil {
+				c.Logger().Error("Failed to settle branches: ", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel executions"})
+			}
+		}
+		if err := scheduler.ResumeParent(tx, r.id); err != nil {
+			c.Logger().Error("Failed to resume parent execution: ", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel executions"})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Transaction failed"})
+	}
+	return c.JSON(http.StatusOK, map[string]int64{"cancelled": cancelled})
+}
+
+// RetryExecution re-queues a failed or dead-lettered execution at the node that
+// failed, keeping its context, step results and workflow version. The retried
+// node gets a fresh attempt budget. It also serves the dead-letter replay route.
+// Parallel branches are not retried.
+func RetryExecution(c echo.Context) error {
+	id := c.Param("id")
+
+	e, err := scanExecution(db.GetDB().QueryRow(`
+		UPDATE workflow_executions we
+		SET status = 'PENDING', attempt = 0, next_run_at = NOW(), result = NULL, finished_at = NULL,
+			resume_handle = NULL, locked_by = NULL, lease_expires_at = NULL
+		WHERE we.id = $1 AND we.status IN ($2, $3) AND we.current_node_id IS NOT NULL AND we.fork_id IS NULL
+		RETURNING `+executionColumns,
+		id, string(models.StatusFailed), string(models.StatusDeadLetter)))
+	if err == sql.ErrNoRows {
+		// A branch's result is merged when its fork settles, which may already
+		// have happened, so a re-run branch could end up with nothing to join
+		var branch bool
+		db.GetDB().QueryRow(`SELECT fork_id IS NOT NULL FROM workflow_executions WHERE id = $1`, id).Scan(&branch)
+		if branch {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Parallel branches cannot be retried on their own"})
+		}
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Execution not found or not failed"})
+	}
+	if err != nil {
+		c.Logger().Error("Failed to retry execution: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retry execution"})
+	}
+	return c.JSON(http.StatusOK, e)
+}
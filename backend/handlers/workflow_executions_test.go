@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/wesuuu/helpnow/backend/db/dbtest"
+)
+
+// executionRow is a row of executionColumns
+func executionRow(id int64, forkID interface{}, status, result string) []driver.Value {
+	now := time.Now()
+	return []driver.Value{id, int64(1), nil, forkID, nil, nil, nil, int64(0), nil, "node-1", status, int64(0), result, now, nil, nil, nil, now, now}
+}
+
+func newExecutionContext(method, target, body string, id string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	if id != "" {
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+	}
+	return c, rec
+}
+
+func TestExecutionsQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   string
+		want     []string // Fragments the query must contain
+		dontWant []string
+		args     int
+		err      string
+	}{
+		{"defaults", "", []string{"WHERE we.fork_id IS NULL", "LIMIT 50 OFFSET 0"}, nil, 0, ""},
+		{"ids and statuses", "workflow_id=3&subject_id=8&status=failed,%20dead_letter", []string{"we.workflow_id = $1", "we.subject_id = $2", "we.status = ANY($3)", "we.fork_id IS NULL"}, nil, 3, ""},
+		{"branches of a fork", "fork_id=7", []string{"we.fork_id = $1"}, []string{"IS NULL"}, 1, ""},
+		{"date range", "from=2026-01-01&to=2026-01-31", []string{"we.created_at >= $1", "we.created_at < $2"}, nil, 2, ""},
+		{"paging", "limit=10&offset=20", []string{"LIMIT 10 OFFSET 20"}, nil, 0, ""},
+		{"unknown status", "status=failed,bogus", nil, nil, 0, `unknown status "BOGUS"`},
+		{"bad id", "workflow_id=abc", nil, nil, 0, "workflow_id must be a number"},
+		{"bad fork", "fork_id=abc", nil, nil, 0, "fork_id must be a number"},
+		{"bad date", "from=yesterday", nil, nil, 0, "from must be RFC 3339 or YYYY-MM-DD"},
+		{"limit too high", "limit=501", nil, nil, 0, "limit must be between 1 and 500"},
+		{"negative offset", "offset=-1", nil, nil, 0, "offset must be a positive number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, _ := url.ParseQuery(tt.params)
+			query, args, err := executionsQuery(params)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("executionsQuery() error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("executionsQuery() unexpected error %v", err)
+			}
+			for _, fragment := range tt.want {
+				if !strings.Contains(query, fragment) {
+					t.Errorf("Expected %q in %s", fragment, query)
+				}
+			}
+			for _, fragment := range tt.dontWant {
+				if strings.Contains(query, fragment) {
+					t.Errorf("Did not expect %q in %s", fragment, query)
+				}
+			}
+			if len(args) != tt.args {
+				t.Errorf("Expected %d args, got %v", tt.args, args)
+			}
+		})
+	}
+
+	// A bare to date includes the whole day
+	params, _ := url.ParseQuery("to=2026-01-31")
+	_, args, _ := executionsQuery(params)
+	if to, ok := args[0].(time.Time); !ok || !to.Equal(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected to=2026-01-31 to end at the start of February, got %v", args[0])
+	}
+}
+
+func TestListExecutionsRejectsUnknownStatus(t *testing.T) {
+	c, rec := newExecutionContext(http.MethodGet, "/executions?status=stuck", "", "")
+	if err := ListExecutions(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown status, got %d", rec.Code)
+	}
+}
+
+func TestCancelExecutionSettlesAndResumes(t *testing.T) {
+	fake := dbtest.Use(t,
+		dbtest.Result{Match: "RETURNING we.id", Rows: [][]driver.Value{executionRow(9, int64(5), "CANCELLED", "Cancelled: duplicate")}},
+		// The fork has other branches still running, and the execution has no parent
+		dbtest.Result{Match: "FOR UPDATE OF we", Rows: [][]driver.Value{{"WAITING_FOR_BRANCHES", nil, "split-1", "{}", nil, nil, false}}},
+		dbtest.Result{Match: "SELECT status, current_node_id, context, step_results", Rows: [][]driver.Value{{"PENDING", "node-2", nil, nil}}},
+		dbtest.Result{Match: "SELECT parent_execution_id, parent_node_id", Rows: [][]driver.Value{{nil, nil, int64(1), "CANCELLED", "Cancelled: duplicate", nil}}},
+	)
+
+	c, rec := newExecutionContext(http.MethodPost, "/executions/9/cancel", `{"reason":"duplicate"}`, "9")
+	if err := CancelExecution(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	ran := fake.AssertOrder(t,
+		"RETURNING we.id",
+		"workflow_approvals",
+		"workflow_event_waits",
+		"WITH RECURSIVE tree",
+		"FOR UPDATE OF we",
+		"SELECT parent_execution_id, parent_node_id",
+	)
+	if reason := ran[0].Args[2]; reason != "Cancelled: duplicate" {
+		t.Errorf("Expected the reason to be recorded, got %v", reason)
+	}
+	if forkID := ran[3].Args[0]; forkID != int64(9) {
+		t.Errorf("Expected the execution's own branches to be cancelled, got fork %v", forkID)
+	}
+	if forkID := ran[4].Args[0]; forkID != int64(5) {
+		t.Errorf("Expected the fork the execution belongs to to be settled, got %v", forkID)
+	}
+	if childID := ran[5].Args[0]; childID != int64(9) {
+		t.Errorf("Expected the parent of execution 9 to be resumed, got %v", childID)
+	}
+	if !fake.Committed {
+		t.Error("Expected the cancellation to be committed")
+	}
+}
+
+func TestCancelExecutionAlreadyFinished(t *testing.T) {
+	fake := dbtest.Use(t)
+
+	c, rec := newExecutionContext(http.MethodPost, "/executions/9/cancel", "", "9")
+	if err := CancelExecution(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409, got %d", rec.Code)
+	}
+	if fake.Committed || len(fake.Statements) != 1 {
+		t.Errorf("Expected nothing else to run, ran:\n%s", fake)
+	}
+}
+
+func TestRetryExecutionRefusesBranches(t *testing.T) {
+	tests := []struct {
+		name   string
+		branch bool
+		want   string
+	}{
+		{"branch", true, "Parallel branches cannot be retried on their own"},
+		{"not failed", false, "Execution not found or not failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The retry UPDATE matches no row, so the handler looks up why
+			dbtest.Use(t, dbtest.Result{Match: "SELECT fork_id IS NOT NULL", Rows: [][]driver.Value{{tt.branch}}})
+
+			c, rec := newExecutionContext(http.MethodPost, "/executions/12/retry", "", "12")
+			if err := RetryExecution(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("Expected 409 %q, got %d: %s", tt.want, rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestRetryExecutionRequeues(t *testing.T) {
+	fake := dbtest.Use(t, dbtest.Result{Match: "RETURNING we.id", Rows: [][]driver.Value{executionRow(12, nil, "PENDING", "")}})
+
+	c, rec := newExecutionContext(http.MethodPost, "/executions/12/retry", "", "12")
+	if err := RetryExecution(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	ran := fake.AssertOrder(t, "RETURNING we.id")
+	if !strings.Contains(ran[0].Query, "we.fork_id IS NULL") {
+		t.Errorf("Expected the retry to skip parallel branches: %s", ran[0].Query)
+	}
+	if fake.Index("SELECT fork_id IS NOT NULL", 0) >= 0 {
+		t.Error("Did not expect a branch lookup after a successful retry")
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/scheduler"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// SimulationRequest is the body of POST /workflows/:id/simulate
+type SimulationRequest struct {
+	Context     map[string]interface{}            `json:"context"`
+	SubjectID   *int                              `json:"subject_id,omitempty"`    // Person whose fields templates should see
+	Version     int                               `json:"version,omitempty"`       // Defaults to the latest saved graph, drafts included
+	StartNodeID string                            `json:"start_node_id,omitempty"` // Defaults to the first trigger
+	Decisions   map[string]string                 `json:"decisions,omitempty"`     // Approval node ID -> approved, rejected or timeout; wait node ID -> matched or timeout; split node ID -> variant
+	Mocks       map[string]map[string]interface{} `json:"mocks,omitempty"`         // Action node ID -> output to use instead of running it
+}
+
+// SimulateWorkflow dry-runs a workflow against a sample context. Emails, HTTP
+// requests and database updates are mocked and delays are reported, not waited out.
+func SimulateWorkflow(c echo.Context) error {
+	workflowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid workflow ID"})
+	}
+
+	var req SimulationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+	}
+
+	var steps string
+	var orgID sql.NullInt64
+	err = db.GetDB().QueryRow(`SELECT steps, organization_id FROM workflows WHERE id = $1`, workflowID).Scan(&steps, &orgID)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Workflow not found"})
+	}
+	if err != nil {
+		c.Logger().Error("Failed to load workflow: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load workflow"})
+	}
+
+	if req.Version > 0 {
+		v, err := getWorkflowVersion(workflowID, req.Version)
+		if err == sql.ErrNoRows {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Version not found"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get version"})
+		}
+		steps = v.Steps
+	}
+
+	var graph workflows.Graph
+	if err := json.Unmarshal([]byte(steps), &graph); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Only graph workflows can be simulated"})
+	}
+	nodes := make(map[string]workflows.Node, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		nodes[n.ID] = n
+	}
+	if _, ok := nodes[req.StartNodeID]; req.StartNodeID != "" && !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "start_node_id is not in the workflow"})
+	}
+	for nodeID, decision := range req.Decisions {
+		allowed := []string{workflows.HandleApproved, workflows.HandleRejected, workflows.HandleTimeout}
+		switch models.NodeType(nodes[nodeID].Type) {
+		case models.NodeTypeWaitForEvent:
+			allowed = []string{workflows.HandleMatched, workflows.HandleTimeout}
+		case models.NodeTypeSplit:
+			if split, err := workflows.ParseSplitNode(nodes[nodeID].Properties); err == nil {
+				allowed = split.Handles()
+			}
+		}
+		if !slices.Contains(allowed, decision) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "decision for " + nodeID + " must be one of: " + strings.Join(allowed, " ")})
+		}
+	}
+
+	result := scheduler.Simulate(c.Request().Context(), graph, scheduler.SimulationOptions{
+		WorkflowID:     workflowID,
+		OrganizationID: int(orgID.Int64),
+		SubjectID:      req.SubjectID,
+		Context:        req.Context,
+		StartNodeID:    req.StartNodeID,
+		Decisions:      req.Decisions,
+		Mocks:          req.Mocks,
+	})
+	return c.JSON(http.StatusOK, result)
+}
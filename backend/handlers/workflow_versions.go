@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// WorkflowVersion is an immutable snapshot of a workflow graph. Saving a workflow
+// creates a new version; new executions start on the published one and keep it
+// until they finish.
+type WorkflowVersion struct {
+	ID          int        `json:"id"`
+	WorkflowID  int        `json:"workflow_id"`
+	Version     int        `json:"version"`
+	Steps       string     `json:"steps,omitempty"`
+	Note        string     `json:"note,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	PublishedAt *time.Time `json:"published_at"` // Nil while the version is a draft
+	Live        bool       `json:"live"`         // New executions start on this version
+}
+
+const workflowVersionColumns = `v.id, v.workflow_id, v.version, v.steps, COALESCE(v.note, ''), v.created_at, v.published_at, (w.published_version_id = v.id)`
+
+func scanWorkflowVersion(row rowScanner) (WorkflowVersion, error) {
+	var v WorkflowVersion
+	var live sql.NullBool
+	err := row.Scan(&v.ID, &v.WorkflowID, &v.Version, &v.Steps, &v.Note, &v.CreatedAt, &v.PublishedAt, &live)
+	v.Live = live.Bool
+	return v, err
+}
+
+// createWorkflowVersion snapshots steps as the workflow's next version. When
+// publish is set the version also becomes the one new executions start on.
+func createWorkflowVersion(tx *sql.Tx, workflowID int, steps, note string, publish bool) (WorkflowVersion, error) {
+	// Lock the workflow so concurrent saves get consecutive version numbers
+	if _, err := tx.Exec(`SELECT id FROM workflows WHERE id = $1 FOR UPDATE`, workflowID); err != nil {
+		return WorkflowVersion{}, err
+	}
+
+	v := WorkflowVersion{WorkflowID: workflowID, Steps: steps, Note: note}
+	err := tx.QueryRow(`
+		INSERT INTO workflow_versions (workflow_id, version, steps, note)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, NULLIF($3, '') FROM workflow_versions WHERE workflow_id = $1
+		RETURNING id, version, created_at
+	`, workflowID, steps, note).Scan(&v.ID, &v.Version, &v.CreatedAt)
+	if err != nil {
+		return v, err
+	}
+
+	if publish {
+		publishedAt, err := publishVersion(tx, workflowID, v.ID)
+		if err != nil {
+			return v, err
+		}
+		v.PublishedAt = &publishedAt
+		v.Live = true
+	}
+	return v, nil
+}
+
+// publishVersion makes a version the one new executions start on
+func publishVersion(tx *sql.Tx, workflowID, versionID int) (time.Time, error) {
+	var publishedAt time.Time
+	err := tx.QueryRow(`
+		UPDATE workflow_versions SET published_at = COALESCE(published_at, NOW()) WHERE id = $1 RETURNING published_at
+	`, versionID).Scan(&publishedAt)
+	if err != nil {
+		return publishedAt, err
+	}
+	_, err = tx.Exec(`UPDATE workflows SET published_version_id = $1 WHERE id = $2`, versionID, workflowID)
+	return publishedAt, err
+}
+
+// replaceTriggers rebuilds the workflow's trigger rows from a published graph
+func replaceTriggers(tx *sql.Tx, workflowID int, triggers []triggerRow) error {
+	if _, err := tx.Exec("DELETE FROM workflow_triggers WHERE workflow_id = $1", workflowID); err != nil {
+		return fmt.Errorf("failed to clear triggers: %w", err)
+	}
+	return saveTriggers(tx, workflowID, triggers)
+}
+
+func getWorkflowVersion(workflowID, version int) (WorkflowVersion, error) {
+	return scanWorkflowVersion(db.GetDB().QueryRow(`
+		SELECT `+workflowVersionColumns+`
+		FROM workflow_versions v JOIN workflows w ON w.id = v.workflow_id
+		WHERE v.workflow_id = $1 AND v.version = $2
+	`, workflowID, version))
+}
+
+func parseVersionParams(c echo.Context) (int, int, error) {
+	workflowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid workflow ID")
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		return 0, 0, fmt.Errorf("invalid version")
+	}
+	return workflowID, version, nil
+}
+
+// ListWorkflowVersions returns a workflow's versions, newest first, without their graphs
+func ListWorkflowVersions(c echo.Context) error {
+	workflowID := c.Param("id")
+
+	rows, err := db.GetDB().Query(`
+		SELECT `+workflowVersionColumns+`
+		FROM workflow_versions v JOIN workflows w ON w.id = v.workflow_id
+		WHERE v.workflow_id = $1
+		ORDER BY v.version DESC
+	`, workflowID)
+	if err != nil {
+		c.Logger().Error("Failed to list workflow versions: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list versions"})
+	}
+	defer rows.Close()
+
+	versions := []WorkflowVersion{}
+	for rows.Next() {
+		v, err := scanWorkflowVersion(rows)
+		if err != nil {
+			c.Logger().Error("Scan error: ", err)
+			continue
+		}
+		v.Steps = ""
+		versions = append(versions, v)
+	}
+	return c.JSON(http.StatusOK, versions)
+}
+
+func GetWorkflowVersion(c echo.Context) error {
+	workflowID, version, err := parseVersionParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	v, err := getWorkflowVersion(workflowID, version)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Version not found"})
+	}
+	if err != nil {
+		c.Logger().Error("Failed to get workflow version: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get version"})
+	}
+	return c.JSON(http.StatusOK, v)
+}
+
+// PublishWorkflowVersion makes a draft version live. Versions that were published
+// before cannot be published again; roll back to them instead, which keeps the
+// history linear.
+func PublishWorkflowVersion(c echo.Context) error {
+	workflowID, version, err := parseVersionParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	v, err := getWorkflowVersion(workflowID, version)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Version not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get version"})
+	}
+	if v.PublishedAt != nil {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("Version %d was already published; use rollback to restore it", version)})
+	}
+
+	triggers, err := extractTriggers(v.Steps, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if lint := lintSteps(v.Steps); lint != nil && !lint.Valid() {
+		return invalidGraph(c, *lint)
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	defer tx.Rollback()
+
+	publishedAt, err := publishVersion(tx, workflowID, v.ID)
+	if err != nil {
+		c.Logger().Error("Failed to publish workflow version: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to publish version"})
+	}
+	// Drafts never touch the workflow's own steps, so bring them up to date
+	if _, err := tx.Exec(`UPDATE workflows SET steps = $1 WHERE id = $2`, v.Steps, workflowID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to publish version"})
+	}
+	if err := replaceTriggers(tx, workflowID, triggers); err != nil {
+		c.Logger().Error("Failed to save triggers: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save triggers"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Transaction failed"})
+	}
+
+	v.PublishedAt = &publishedAt
+	v.Live = true
+	return c.JSON(http.StatusOK, v)
+}
+
+// RollbackWorkflow restores an earlier version by publishing a copy of it as the
+// newest version. Executions already running keep the version they started on.
+func RollbackWorkflow(c echo.Context) error {
+	workflowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid workflow ID"})
+	}
+	var req struct {
+		Version int `json:"version"`
+	}
+	if err := c.Bind(&req); err != nil || req.Version < 1 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "version is required"})
+	}
+
+	target, err := getWorkflowVersion(workflowID, req.Version)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Version not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get version"})
+	}
+
+	triggers, err := extractTriggers(target.Steps, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if lint := lintSteps(target.Steps); lint != nil && !lint.Valid() {
+		return invalidGraph(c, *lint)
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	defer tx.Rollback()
+
+	v, err := createWorkflowVersion(tx, workflowID, target.Steps, fmt.Sprintf("Rollback to version %d", target.Version), true)
+	if err != nil {
+		c.Logger().Error("Failed to roll back workflow: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to roll back"})
+	}
+	if _, err := tx.Exec(`UPDATE workflows SET steps = $1 WHERE id = $2`, target.Steps, workflowID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to roll back"})
+	}
+	if err := replaceTriggers(tx, workflowID, triggers); err != nil {
+		c.Logger().Error("Failed to save triggers: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save triggers"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Transaction failed"})
+	}
+
+	return c.JSON(http.StatusCreated, v)
+}
+
+// DiffWorkflowVersions compares two versions' graphs. from defaults to the
+// published version and to defaults to the latest one.
+func DiffWorkflowVersions(c echo.Context) error {
+	workflowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid workflow ID"})
+	}
+
+	var published, latest sql.NullInt64
+	err = db.GetDB().QueryRow(`
+		SELECT (SELECT version FROM workflow_versions WHERE id = w.published_version_id),
+		       (SELECT MAX(version) FROM workflow_versions WHERE workflow_id = w.id)
+		FROM workflows w WHERE w.id = $1
+	`, workflowID).Scan(&published, &latest)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Workflow not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load versions"})
+	}
+
+	from, to := int(published.Int64), int(latest.Int64)
+	if raw := c.QueryParam("from"); raw != "" {
+		if from, err = strconv.Atoi(raw); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "from must be a version number"})
+		}
+	}
+	if raw := c.QueryParam("to"); raw != "" {
+		if to, err = strconv.Atoi(raw); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "to must be a version number"})
+		}
+	}
+
+	var graphs [2]workflows.Graph
+	for i, number := range []int{from, to} {
+		v, err := getWorkflowVersion(workflowID, number)
+		if err == sql.ErrNoRows {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("Version %d not found", number)})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get version"})
+		}
+		// Legacy (non-graph) steps compare as empty graphs
+		json.Unmarshal([]byte(v.Steps), &graphs[i])
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"from": from,
+		"to":   to,
+		"diff": workflows.DiffGraphs(graphs[0], graphs[1]),
+	})
+}
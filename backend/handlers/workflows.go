@@ -3,7 +3,9 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,6 +13,8 @@ import (
 	"github.com/lib/pq"
 	"github.com/wesuuu/helpnow/backend/db"
 	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/scheduler"
+	"github.com/wesuuu/helpnow/backend/workflows"
 )
 
 type EventDefinition struct {
@@ -35,6 +39,18 @@ type Workflow struct {
 	NextRunAt      *time.Time `json:"next_run_at"` // Added
 	Status         string     `json:"status"`
 	CreatedAt      time.Time  `json:"created_at"`
+
+	PublishedVersion *int `json:"published_version"` // Version new executions start on
+	LatestVersion    *int `json:"latest_version"`    // Newest saved version; ahead of published when a draft exists
+
+	Goal       *workflows.Goal       `json:"goal"`        // Reaching it exits the person from the workflow
+	EntryRule  *workflows.EntryRule  `json:"entry_rule"`  // How often one person may enter; nil lets them in every time
+	SendWindow *workflows.SendWindow `json:"send_window"` // When messages may go out, in each person's local time; nil uses the organization's
+
+	MaxDurationHours *int `json:"max_duration_hours"` // Executions running longer time out; nil for the default limit, 0 for none
+	Priority         int  `json:"priority"`           // Executions of higher-priority workflows run first when the worker pool is saturated
+
+	Lint *workflows.LintResult `json:"lint,omitempty"` // Issues found in the saved graph
 }
 
 type WorkflowExecution struct {
@@ -58,12 +74,35 @@ func CreateWorkflow(c echo.Context) error {
 	}
 
 	// Calculate Initial Next Run if Schedule
-	if wf.TriggerType == string(models.TriggerTypeSchedule) && wf.Schedule != nil && *wf.Schedule != "" {
-		// MVP: If simple cron "* * * * *", current logic in updates handles parsing.
-		// Here we just set it to NOW() to run immediately or soon, or parse properly.
-		// For MVP simplicity, let's set it to NOW() so scheduler picks it up and calculates real next run.
-		now := time.Now()
-		wf.NextRunAt = &now
+	if err := setLegacyNextRun(&wf); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	// Parse Graph to extract Triggers (rejects bad schedules before anything is saved)
+	triggers, err := extractTriggers(wf.Steps, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	wf.Lint = lintSteps(wf.Steps)
+	if wf.Lint != nil && !wf.Lint.Valid() {
+		return invalidGraph(c, *wf.Lint)
+	}
+
+	goal, err := goalJSON(wf.Goal)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	entryRule, err := entryRuleJSON(wf.EntryRule)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	sendWindow, err := sendWindowJSON(wf.SendWindow)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if wf.MaxDurationHours != nil && *wf.MaxDurationHours < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "max_duration_hours must be 0 (no limit) or more"})
 	}
 
 	// Default Org ID to 1 for MVP if not set
@@ -73,11 +112,17 @@ func CreateWorkflow(c echo.Context) error {
 	}
 
 	// Create Workflow Record
-	query := `INSERT INTO workflows (organization_id, site_id, audience_id, name, trigger_type, trigger_event, steps, schedule, next_run_at, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at`
+	query := `INSERT INTO workflows (organization_id, site_id, audience_id, name, trigger_type, trigger_event, steps, schedule, next_run_at, status, goal, entry_rule, send_window, max_duration_hours, priority) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING id, created_at`
 	// For legacy support/display, we might still want to populate trigger_type/schedule in the main table if we have a primary trigger?
 	// But let's assume we just save them blank or as "multiple" if we move fully?
 	// For now, keep saving them as is (binding form input) BUT ALSO parse graph.
-	err := db.GetDB().QueryRow(query, wf.OrganizationID, wf.SiteID, wf.AudienceID, wf.Name, wf.TriggerType, wf.TriggerEvent, wf.Steps, wf.Schedule, wf.NextRunAt, "ACTIVE").Scan(&wf.ID, &wf.CreatedAt)
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(query, wf.OrganizationID, wf.SiteID, wf.AudienceID, wf.Name, wf.TriggerType, wf.TriggerEvent, wf.Steps, wf.Schedule, wf.NextRunAt, "ACTIVE", goal, entryRule, sendWindow, wf.MaxDurationHours, wf.Priority).Scan(&wf.ID, &wf.CreatedAt)
 	if err != nil {
 		// Check for unique constraint violation (Postgres code 23505)
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
@@ -93,50 +138,19 @@ func CreateWorkflow(c echo.Context) error {
 	}
 	wf.Status = "ACTIVE"
 
-	// Parse Graph to extract Triggers
-	type GraphNode struct {
-		ID         string                 `json:"id"`
-		Type       string                 `json:"type"`
-		Properties map[string]interface{} `json:"properties"`
-	}
-	type GraphStruct struct {
-		Nodes []GraphNode `json:"nodes"`
-	}
-
-	var graph GraphStruct
-	if err := json.Unmarshal([]byte(wf.Steps), &graph); err == nil {
-		for _, node := range graph.Nodes {
-			if node.Type == string(models.NodeTypeTrigger) {
-				// Determine Type
-				tType := string(models.TriggerTypeEvent) // Default
-				if val, ok := node.Properties["trigger_type"].(string); ok {
-					tType = val
-				} else if node.Properties["cron"] != nil {
-					tType = string(models.TriggerTypeSchedule)
-				}
-
-				// Build Config JSON
-				configBytes, _ := json.Marshal(node.Properties)
-				configJSON := string(configBytes)
-
-				// Determine Next Run (if schedule)
-				var nextRun sql.NullTime
-				if tType == string(models.TriggerTypeSchedule) {
-					nextRun.Time = time.Now() // Run immediately/soon
-					nextRun.Valid = true
-				}
-
-				_, err := db.GetDB().Exec(`
-					INSERT INTO workflow_triggers (workflow_id, node_id, type, config, next_run_at)
-					VALUES ($1, $2, $3, $4, $5)
-				`, wf.ID, node.ID, tType, configJSON, nextRun)
-
-				if err != nil {
-					c.Logger().Error("Failed to save trigger:", err)
-				}
-			}
-		}
+	if _, err := createWorkflowVersion(tx, wf.ID, wf.Steps, "", true); err != nil {
+		c.Logger().Error("Failed to create workflow version: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create workflow version"})
 	}
+	if err := saveTriggers(tx, wf.ID, triggers); err != nil {
+		c.Logger().Error("Failed to save triggers: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save triggers"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Transaction failed"})
+	}
+	one := 1
+	wf.PublishedVersion, wf.LatestVersion = &one, &one
 
 	return c.JSON(http.StatusCreated, wf)
 }
@@ -148,14 +162,18 @@ func ListWorkflows(c echo.Context) error {
 
 	if siteID != "" && siteID != "null" {
 		rows, err = db.GetDB().Query(`
-			SELECT w.id, w.organization_id, w.site_id, s.name, w.audience_id, w.name, w.trigger_type, w.trigger_event, w.steps, w.schedule, w.next_run_at, w.status, w.created_at 
+			SELECT w.id, w.organization_id, w.site_id, s.name, w.audience_id, w.name, w.trigger_type, w.trigger_event, w.steps, w.schedule, w.next_run_at, w.status, w.created_at, w.goal, w.entry_rule, w.send_window, w.max_duration_hours, COALESCE(w.priority, 0),
+				(SELECT version FROM workflow_versions WHERE id = w.published_version_id),
+				(SELECT MAX(version) FROM workflow_versions WHERE workflow_id = w.id)
 			FROM workflows w
 			LEFT JOIN sites s ON w.site_id = s.id
 			WHERE w.site_id = $1 
 			ORDER BY w.created_at DESC`, siteID)
 	} else {
 		rows, err = db.GetDB().Query(`
-			SELECT w.id, w.organization_id, w.site_id, s.name, w.audience_id, w.name, w.trigger_type, w.trigger_event, w.steps, w.schedule, w.next_run_at, w.status, w.created_at 
+			SELECT w.id, w.organization_id, w.site_id, s.name, w.audience_id, w.name, w.trigger_type, w.trigger_event, w.steps, w.schedule, w.next_run_at, w.status, w.created_at, w.goal, w.entry_rule, w.send_window, w.max_duration_hours, COALESCE(w.priority, 0),
+				(SELECT version FROM workflow_versions WHERE id = w.published_version_id),
+				(SELECT MAX(version) FROM workflow_versions WHERE workflow_id = w.id)
 			FROM workflows w
 			LEFT JOIN sites s ON w.site_id = s.id
 			ORDER BY w.created_at DESC`)
@@ -171,10 +189,14 @@ func ListWorkflows(c echo.Context) error {
 	for rows.Next() {
 		var w Workflow
 		var siteName sql.NullString // Handle Join NULLs
-		if err := rows.Scan(&w.ID, &w.OrganizationID, &w.SiteID, &siteName, &w.AudienceID, &w.Name, &w.TriggerType, &w.TriggerEvent, &w.Steps, &w.Schedule, &w.NextRunAt, &w.Status, &w.CreatedAt); err == nil {
+		var goal, entryRule, sendWindow []byte
+		if err := rows.Scan(&w.ID, &w.OrganizationID, &w.SiteID, &siteName, &w.AudienceID, &w.Name, &w.TriggerType, &w.TriggerEvent, &w.Steps, &w.Schedule, &w.NextRunAt, &w.Status, &w.CreatedAt, &goal, &entryRule, &sendWindow, &w.MaxDurationHours, &w.Priority, &w.PublishedVersion, &w.LatestVersion); err == nil {
 			if siteName.Valid {
 				w.SiteName = siteName.String
 			}
+			w.Goal = parseGoal(goal)
+			w.EntryRule = parseEntryRule(entryRule)
+			w.SendWindow = parseSendWindow(sendWindow)
 			workflows = append(workflows, w)
 		} else {
 			c.Logger().Error("Scan error: ", err)
@@ -187,12 +209,15 @@ func GetWorkflow(c echo.Context) error {
 	id := c.Param("id")
 	var w Workflow
 	var siteName sql.NullString
+	var goal, entryRule, sendWindow []byte
 
 	err := db.GetDB().QueryRow(`
-		SELECT w.id, w.organization_id, w.site_id, s.name, w.audience_id, w.name, w.trigger_type, w.trigger_event, w.steps, w.schedule, w.next_run_at, w.status, w.created_at 
+		SELECT w.id, w.organization_id, w.site_id, s.name, w.audience_id, w.name, w.trigger_type, w.trigger_event, w.steps, w.schedule, w.next_run_at, w.status, w.created_at, w.goal, w.entry_rule, w.send_window, w.max_duration_hours, COALESCE(w.priority, 0),
+				(SELECT version FROM workflow_versions WHERE id = w.published_version_id),
+				(SELECT MAX(version) FROM workflow_versions WHERE workflow_id = w.id)
 		FROM workflows w
 		LEFT JOIN sites s ON w.site_id = s.id
-		WHERE w.id = $1`, id).Scan(&w.ID, &w.OrganizationID, &w.SiteID, &siteName, &w.AudienceID, &w.Name, &w.TriggerType, &w.TriggerEvent, &w.Steps, &w.Schedule, &w.NextRunAt, &w.Status, &w.CreatedAt)
+		WHERE w.id = $1`, id).Scan(&w.ID, &w.OrganizationID, &w.SiteID, &siteName, &w.AudienceID, &w.Name, &w.TriggerType, &w.TriggerEvent, &w.Steps, &w.Schedule, &w.NextRunAt, &w.Status, &w.CreatedAt, &goal, &entryRule, &sendWindow, &w.MaxDurationHours, &w.Priority, &w.PublishedVersion, &w.LatestVersion)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -205,26 +230,83 @@ func GetWorkflow(c echo.Context) error {
 	if siteName.Valid {
 		w.SiteName = siteName.String
 	}
+	w.Goal = parseGoal(goal)
+	w.EntryRule = parseEntryRule(entryRule)
+	w.SendWindow = parseSendWindow(sendWindow)
 
 	return c.JSON(http.StatusOK, w)
 }
 
 func UpdateWorkflow(c echo.Context) error {
 	id := c.Param("id")
+	wfID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid workflow ID"})
+	}
+
 	var wf Workflow
 	if err := c.Bind(&wf); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
 	}
 
 	// Calculate Initial Next Run if Schedule
-	if wf.TriggerType == string(models.TriggerTypeSchedule) && wf.Schedule != nil && *wf.Schedule != "" {
-		now := time.Now()
-		wf.NextRunAt = &now
+	if err := setLegacyNextRun(&wf); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	triggers, err := extractTriggers(wf.Steps, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	// Drafts may be saved mid-edit with errors; they are enforced on publish
+	draft := c.QueryParam("draft") == "true"
+	wf.Lint = lintSteps(wf.Steps)
+	if wf.Lint != nil && !wf.Lint.Valid() && !draft {
+		return invalidGraph(c, *wf.Lint)
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	defer tx.Rollback()
+	wf.ID = wfID
+
+	// A draft only snapshots the graph as a new version. The workflow, its
+	// settings and its triggers stay as published until the draft is published.
+	if draft {
+		v, err := createWorkflowVersion(tx, wfID, wf.Steps, "", false)
+		if err != nil {
+			c.Logger().Error("Failed to create workflow version: ", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create workflow version"})
+		}
+		if err := tx.Commit(); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Transaction failed"})
+		}
+		wf.LatestVersion = &v.Version
+		return c.JSON(http.StatusOK, wf)
+	}
+
+	goal, err := goalJSON(wf.Goal)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	entryRule, err := entryRuleJSON(wf.EntryRule)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	sendWindow, err := sendWindowJSON(wf.SendWindow)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if wf.MaxDurationHours != nil && *wf.MaxDurationHours < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "max_duration_hours must be 0 (no limit) or more"})
 	}
 
 	// Update Workflow Record
-	query := `UPDATE workflows SET site_id=$1, audience_id=$2, name=$3, trigger_type=$4, trigger_event=$5, steps=$6, schedule=$7, next_run_at=$8 WHERE id=$9`
-	_, err := db.GetDB().Exec(query, wf.SiteID, wf.AudienceID, wf.Name, wf.TriggerType, wf.TriggerEvent, wf.Steps, wf.Schedule, wf.NextRunAt, id)
+	query := `UPDATE workflows SET site_id=$1, audience_id=$2, name=$3, trigger_type=$4, trigger_event=$5, steps=$6, schedule=$7, next_run_at=$8, goal=$9, entry_rule=$10, send_window=$11, max_duration_hours=$12, priority=$13 WHERE id=$14`
+	_, err = tx.Exec(query, wf.SiteID, wf.AudienceID, wf.Name, wf.TriggerType, wf.TriggerEvent, wf.Steps, wf.Schedule, wf.NextRunAt, goal, entryRule, sendWindow, wf.MaxDurationHours, wf.Priority, wfID)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
 			return c.JSON(http.StatusConflict, map[string]string{"error": "Workflow with this name already exists"})
@@ -236,59 +318,427 @@ func UpdateWorkflow(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update workflow"})
 	}
 
-	// Re-create Triggers
-	// 1. Delete existing triggers for this workflow
-	_, err = db.GetDB().Exec("DELETE FROM workflow_triggers WHERE workflow_id = $1", id)
+	// Every save is a new version, published along with the triggers built from it
+	v, err := createWorkflowVersion(tx, wfID, wf.Steps, "", true)
 	if err != nil {
-		c.Logger().Error("Failed to clear triggers: ", err)
+		c.Logger().Error("Failed to create workflow version: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create workflow version"})
+	}
+	if err := replaceTriggers(tx, wfID, triggers); err != nil {
+		c.Logger().Error("Failed to save triggers: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save triggers"})
 	}
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Transaction failed"})
+	}
+	wf.LatestVersion = &v.Version
+	wf.PublishedVersion = &v.Version
 
-	// 2. Parse Graph and create new triggers
-	type GraphNode struct {
-		ID         string                 `json:"id"`
-		Type       string                 `json:"type"`
-		Properties map[string]interface{} `json:"properties"`
+	return c.JSON(http.StatusOK, wf)
+}
+
+// WorkflowConversion is a person leaving a workflow early by reaching its goal
+type WorkflowConversion struct {
+	ExecutionID int       `json:"execution_id"`
+	SubjectID   *int      `json:"subject_id"`
+	NodeID      *string   `json:"node_id"` // Node the execution was at when it exited
+	GoalType    string    `json:"goal_type"`
+	ConvertedAt time.Time `json:"converted_at"`
+}
+
+// GetWorkflowConversions reports how many executions of a workflow ended by
+// reaching its goal, out of how many were started, with the latest conversions
+func GetWorkflowConversions(c echo.Context) error {
+	workflowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid workflow ID"})
 	}
-	type GraphStruct struct {
-		Nodes []GraphNode `json:"nodes"`
+
+	var goal []byte
+	var entered, converted int
+	err = db.GetDB().QueryRow(`
+		SELECT w.goal,
+			(SELECT COUNT(*) FROM workflow_executions WHERE workflow_id = w.id AND fork_id IS NULL),
+			(SELECT COUNT(*) FROM workflow_conversions WHERE workflow_id = w.id)
+		FROM workflows w WHERE w.id = $1
+	`, workflowID).Scan(&goal, &entered, &converted)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Workflow not found"})
+	}
+	if err != nil {
+		c.Logger().Error("Failed to count conversions: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get conversions"})
 	}
 
-	var graph GraphStruct
-	if err := json.Unmarshal([]byte(wf.Steps), &graph); err == nil {
-		for _, node := range graph.Nodes {
-			if node.Type == string(models.NodeTypeTrigger) {
-				// Determine Type
-				tType := string(models.TriggerTypeEvent) // Default
-				if val, ok := node.Properties["trigger_type"].(string); ok {
-					tType = val
-				} else if node.Properties["cron"] != nil {
-					tType = string(models.TriggerTypeSchedule)
-				}
+	rows, err := db.GetDB().Query(`
+		SELECT execution_id, subject_id, node_id, goal_type, converted_at
+		FROM workflow_conversions WHERE workflow_id = $1
+		ORDER BY converted_at DESC, id DESC LIMIT 50
+	`, workflowID)
+	if err != nil {
+		c.Logger().Error("Failed to list conversions: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get conversions"})
+	}
+	defer rows.Close()
+	recent := []WorkflowConversion{}
+	for rows.Next() {
+		var conv WorkflowConversion
+		if err := rows.Scan(&conv.ExecutionID, &conv.SubjectID, &conv.NodeID, &conv.GoalType, &conv.ConvertedAt); err != nil {
+			c.Logger().Error("Scan error: ", err)
+			continue
+		}
+		recent = append(recent, conv)
+	}
 
-				// Build Config JSON
-				configBytes, _ := json.Marshal(node.Properties)
-				configJSON := string(configBytes)
+	rate := 0.0
+	if entered > 0 {
+		rate = float64(converted) / float64(entered)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"workflow_id":     workflowID,
+		"goal":            parseGoal(goal),
+		"entered":         entered,
+		"converted":       converted,
+		"conversion_rate": rate,
+		"recent":          recent,
+	})
+}
 
-				// Determine Next Run (if schedule)
-				var nextRun sql.NullTime
-				if tType == string(models.TriggerTypeSchedule) {
-					nextRun.Time = time.Now()
-					nextRun.Valid = true
-				}
+// SplitVariantStats compares how the executions sent down one variant of a SPLIT
+// node went
+type SplitVariantStats struct {
+	Variant        string  `json:"variant"`
+	Assigned       int     `json:"assigned"`
+	Active         int     `json:"active"`
+	Completed      int     `json:"completed"`
+	Failed         int     `json:"failed"`
+	Converted      int     `json:"converted"` // Reached the workflow's goal after being assigned
+	ConversionRate float64 `json:"conversion_rate"`
+	EventCount     int     `json:"event_count,omitempty"` // Triggered the event asked for after being assigned
+	EventRate      float64 `json:"event_rate,omitempty"`
+}
 
-				_, err := db.GetDB().Exec(`
-					INSERT INTO workflow_triggers (workflow_id, node_id, type, config, next_run_at)
-					VALUES ($1, $2, $3, $4, $5)
-				`, id, node.ID, tType, configJSON, nextRun)
+// GetSplitStats reports, per variant of a SPLIT node, how many executions were
+// assigned and how they ended. With ?event=name it also counts the people who
+// triggered that event after being assigned.
+func GetSplitStats(c echo.Context) error {
+	workflowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid workflow ID"})
+	}
+	nodeID := c.Param("node_id")
+	event := c.QueryParam("event")
 
-				if err != nil {
-					c.Logger().Error("Failed to save trigger:", err)
-				}
+	rows, err := db.GetDB().Query(`
+		SELECT a.variant,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE we.status = ANY($4)),
+			COUNT(*) FILTER (WHERE we.status = 'COMPLETED'),
+			COUNT(*) FILTER (WHERE we.status IN ('FAILED', 'DEAD_LETTER')),
+			COUNT(*) FILTER (WHERE EXISTS (
+				SELECT 1 FROM workflow_conversions wc
+				WHERE wc.workflow_id = a.workflow_id AND wc.subject_id = a.subject_id AND wc.converted_at >= a.assigned_at
+			)),
+			COUNT(*) FILTER (WHERE $3 <> '' AND EXISTS (
+				SELECT 1 FROM person_events pe
+				WHERE pe.person_id = a.subject_id AND pe.created_at >= a.assigned_at
+				AND COALESCE(pe.event::jsonb->>'name', pe.event::jsonb->>'event_type', pe.event::jsonb->>'type') = $3
+			))
+		FROM workflow_split_assignments a
+		JOIN workflow_executions we ON we.id = a.execution_id
+		WHERE a.workflow_id = $1 AND a.node_id = $2
+		GROUP BY a.variant
+		ORDER BY a.variant
+	`, workflowID, nodeID, event, pq.Array(models.ActiveStatuses))
+	if err != nil {
+		c.Logger().Error("Failed to get split stats: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get split stats"})
+	}
+	defer rows.Close()
+
+	variants := []SplitVariantStats{}
+	for rows.Next() {
+		var v SplitVariantStats
+		if err := rows.Scan(&v.Variant, &v.Assigned, &v.Active, &v.Completed, &v.Failed, &v.Converted, &v.EventCount); err != nil {
+			c.Logger().Error("Scan error: ", err)
+			continue
+		}
+		if v.Assigned > 0 {
+			v.ConversionRate = float64(v.Converted) / float64(v.Assigned)
+			v.EventRate = float64(v.EventCount) / float64(v.Assigned)
+		}
+		variants = append(variants, v)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"workflow_id": workflowID,
+		"node_id":     nodeID,
+		"event":       event,
+		"variants":    variants,
+	})
+}
+
+// goalJSON validates a workflow's goal and returns it for the goal column, or nil
+// for a workflow without one
+func goalJSON(goal *workflows.Goal) (interface{}, error) {
+	if goal == nil {
+		return nil, nil
+	}
+	if err := goal.Validate(); err != nil {
+		return nil, err
+	}
+	data, _ := json.Marshal(goal)
+	return string(data), nil
+}
+
+// parseGoal reads the goal column
+func parseGoal(data []byte) *workflows.Goal {
+	if len(data) == 0 {
+		return nil
+	}
+	var goal workflows.Goal
+	if err := json.Unmarshal(data, &goal); err != nil {
+		return nil
+	}
+	return &goal
+}
+
+// entryRuleJSON validates a workflow's entry rule and returns it for the
+// entry_rule column, or nil for a workflow without one
+func entryRuleJSON(rule *workflows.EntryRule) (interface{}, error) {
+	if rule == nil {
+		return nil, nil
+	}
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	data, _ := json.Marshal(rule)
+	return string(data), nil
+}
+
+// parseEntryRule reads the entry_rule column
+func parseEntryRule(data []byte) *workflows.EntryRule {
+	if len(data) == 0 {
+		return nil
+	}
+	var rule workflows.EntryRule
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return nil
+	}
+	return &rule
+}
+
+// sendWindowJSON validates a send window and returns it for a send_window
+// column, or nil when there is none
+func sendWindowJSON(window *workflows.SendWindow) (interface{}, error) {
+	if window == nil {
+		return nil, nil
+	}
+	if err := window.Validate(); err != nil {
+		return nil, err
+	}
+	data, _ := json.Marshal(window)
+	return string(data), nil
+}
+
+// parseSendWindow reads a send_window column
+func parseSendWindow(data []byte) *workflows.SendWindow {
+	if len(data) == 0 {
+		return nil
+	}
+	var window workflows.SendWindow
+	if err := json.Unmarshal(data, &window); err != nil {
+		return nil
+	}
+	return &window
+}
+
+// lintSteps lints a workflow's graph. Legacy (non-graph) steps are not linted and
+// return nil.
+func lintSteps(steps string) *workflows.LintResult {
+	var graph workflows.Graph
+	if err := json.Unmarshal([]byte(steps), &graph); err != nil {
+		return nil
+	}
+	result := workflows.LintGraph(graph)
+	return &result
+}
+
+func invalidGraph(c echo.Context, result workflows.LintResult) error {
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":    result.Err().Error(),
+		"errors":   result.Errors,
+		"warnings": result.Warnings,
+	})
+}
+
+// ValidateWorkflow lints a graph without saving it, for the builder. The body is
+// either a workflow ({"steps": "<graph JSON>"}) or a graph ({"nodes": [], "edges": []}).
+func ValidateWorkflow(c echo.Context) error {
+	var req struct {
+		Steps string `json:"steps"`
+		workflows.Graph
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+	}
+
+	graph := req.Graph
+	if req.Steps != "" {
+		graph = workflows.Graph{}
+		if err := json.Unmarshal([]byte(req.Steps), &graph); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "steps is not a workflow graph"})
+		}
+	}
+
+	result := workflows.LintGraph(graph)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"valid":    result.Valid(),
+		"errors":   result.Errors,
+		"warnings": result.Warnings,
+	})
+}
+
+// triggerRow is a trigger node extracted from a workflow graph, ready to be saved
+type triggerRow struct {
+	NodeID    string
+	Type      string
+	Config    string
+	NextRunAt sql.NullTime
+}
+
+// extractTriggers parses the graph and builds one trigger row per TRIGGER node.
+// Scheduled triggers get their first fire time computed from their cron expression.
+func extractTriggers(steps string, now time.Time) ([]triggerRow, error) {
+	var graph workflows.Graph
+	if err := json.Unmarshal([]byte(steps), &graph); err != nil {
+		// Legacy (non-graph) steps have no trigger nodes
+		return nil, nil
+	}
+
+	triggers := []triggerRow{}
+	for _, node := range graph.Nodes {
+		if node.Type != string(models.NodeTypeTrigger) {
+			continue
+		}
+
+		// Determine Type
+		tType := string(models.TriggerTypeEvent) // Default
+		if val, ok := node.Properties["trigger_type"].(string); ok {
+			tType = val
+		} else if node.Properties["cron"] != nil {
+			tType = string(models.TriggerTypeSchedule)
+		}
+
+		// Build Config JSON
+		configBytes, _ := json.Marshal(node.Properties)
+
+		// Determine Next Run (if schedule)
+		var nextRun sql.NullTime
+		if tType == string(models.TriggerTypeSchedule) {
+			if err := workflows.ValidateTriggerNode(tType, node.Properties); err != nil {
+				return nil, fmt.Errorf("node %s (%s): %w", node.ID, tType, err)
+			}
+			next, _, err := workflows.NextTriggerRun(tType, node.Properties, now)
+			if err != nil {
+				return nil, fmt.Errorf("node %s (%s): %w", node.ID, tType, err)
 			}
+			nextRun = sql.NullTime{Time: next, Valid: !next.IsZero()}
 		}
+
+		triggers = append(triggers, triggerRow{
+			NodeID:    node.ID,
+			Type:      tType,
+			Config:    string(configBytes),
+			NextRunAt: nextRun,
+		})
 	}
+	return triggers, nil
+}
 
-	return c.JSON(http.StatusOK, wf)
+// saveTriggers inserts the workflow's trigger rows and syncs its webhook tokens
+func saveTriggers(tx *sql.Tx, workflowID int, triggers []triggerRow) error {
+	for _, t := range triggers {
+		_, err := tx.Exec(`
+			INSERT INTO workflow_triggers (workflow_id, node_id, type, config, next_run_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, workflowID, t.NodeID, t.Type, t.Config, t.NextRunAt)
+		if err != nil {
+			return fmt.Errorf("failed to save trigger %s: %w", t.NodeID, err)
+		}
+	}
+	return syncWebhookTokens(tx, workflowID, triggers)
+}
+
+// setLegacyNextRun computes next_run_at for the deprecated workflow-level schedule column
+func setLegacyNextRun(wf *Workflow) error {
+	if wf.TriggerType != string(models.TriggerTypeSchedule) || wf.Schedule == nil || *wf.Schedule == "" {
+		return nil
+	}
+	schedule, err := workflows.ParseSchedule(*wf.Schedule, "")
+	if err != nil {
+		return err
+	}
+	next := schedule.Next(time.Now())
+	wf.NextRunAt = &next
+	return nil
+}
+
+// PreviewTriggerSchedule returns the next fire times of a scheduled trigger node
+func PreviewTriggerSchedule(c echo.Context) error {
+	workflowID := c.Param("id")
+	nodeID := c.Param("node_id")
+
+	count := 5
+	if raw := c.QueryParam("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 100 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "count must be between 1 and 100"})
+		}
+		count = n
+	}
+
+	var tType string
+	var configStr sql.NullString
+	err := db.GetDB().QueryRow(`
+		SELECT type, config FROM workflow_triggers WHERE workflow_id = $1 AND node_id = $2
+	`, workflowID, nodeID).Scan(&tType, &configStr)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Trigger not found"})
+	} else if err != nil {
+		c.Logger().Error("Failed to load trigger: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load trigger"})
+	}
+
+	config := map[string]interface{}{}
+	if configStr.Valid && configStr.String != "" {
+		json.Unmarshal([]byte(configStr.String), &config)
+	}
+
+	runs := []time.Time{}
+	after := time.Now()
+	for i := 0; i < count; i++ {
+		next, ok, err := workflows.NextTriggerRun(tType, config, after)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Trigger is not scheduled"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		if next.IsZero() {
+			break
+		}
+		runs = append(runs, next)
+		after = next
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"workflow_id": workflowID,
+		"node_id":     nodeID,
+		"cron":        config["cron"],
+		"timezone":    config["timezone"],
+		"next_runs":   runs,
+	})
 }
 
 // Event Definitions
@@ -352,6 +802,7 @@ func TriggerWorkflow(siteID int, eventName string, contextData map[string]interf
 	defer rows.Close()
 
 	contextJSON, _ := json.Marshal(contextData)
+	personID := sitePersonID(siteID, contextData)
 
 	type TriggerConfig struct {
 		TriggerEvent string `json:"trigger_event"`
@@ -423,15 +874,86 @@ func TriggerWorkflow(siteID int, eventName string, contextData map[string]interf
 			}
 
 			// 2. Create Execution
-			_, err := db.GetDB().Exec(`
-				INSERT INTO workflow_executions (workflow_id, current_node_id, status, context, next_run_at) 
-				VALUES ($1, $2, 'PENDING', $3, NOW())`,
-				workflowID, nodeID, string(contextJSON))
-			if err != nil {
-				// fmt.Println("Failed to trigger execution:", err)
+			if err := enterWorkflow(workflowID, nodeID, personID, string(contextJSON)); err != nil {
+				scheduler.Logger.Error("Failed to trigger execution:", err)
 			}
 		}
 	}
 
+	// Resume executions waiting for this event from the same person, and exit the
+	// ones it is the goal of
+	deliverSiteEvent(personID, eventName, contextData)
 	return nil
 }
+
+// deliverSiteEvent passes a site event to WAIT_FOR_EVENT nodes and workflow
+// goals
+func deliverSiteEvent(personID int, eventName string, contextData map[string]interface{}) {
+	if personID == 0 {
+		return
+	}
+	if _, err := scheduler.DeliverEvent(personID, eventName, contextData); err != nil {
+		scheduler.Logger.Error("Failed to deliver event to waiting executions:", err)
+	}
+	if _, err := scheduler.ReachGoalEvent(personID, eventName, contextData); err != nil {
+		scheduler.Logger.Error("Failed to exit executions that reached their goal:", err)
+	}
+}
+
+// sitePersonID identifies the person behind a site event: by person_id in the
+// event data, or by email within the site's organization. It returns 0 when
+// nobody matches.
+func sitePersonID(siteID int, contextData map[string]interface{}) int {
+	personID, _ := contextData["person_id"].(float64)
+	email, _ := contextData["email"].(string)
+	if email == "" {
+		email, _ = contextData["user_email"].(string)
+	}
+	if personID == 0 && email == "" {
+		return 0
+	}
+
+	var id int
+	err := db.GetDB().QueryRow(`
+		SELECT p.id FROM people p
+		JOIN sites s ON s.organization_id = p.organization_id
+		WHERE s.id = $1 AND (p.id = $2 OR (p.email = $3 AND $3 <> ''))
+		ORDER BY p.id = $2 DESC, p.id
+		LIMIT 1
+	`, siteID, int(personID), email).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0
+	}
+	if err != nil {
+		scheduler.Logger.Error("Failed to identify the person behind a site event:", err)
+		return 0
+	}
+	return id
+}
+
+// enterWorkflow starts an execution of a workflow from an event. Events from a
+// known person are held to the workflow's entry rule and the message cap, and the
+// execution runs for them.
+func enterWorkflow(workflowID int, nodeID string, personID int, contextJSON string) error {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if personID != 0 {
+		admitted, err := scheduler.AdmitEntry(tx, workflowID, personID)
+		if err != nil || !admitted {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO workflow_executions (workflow_id, version_id, subject_id, current_node_id, status, context, next_run_at, priority)
+		SELECT id, published_version_id, NULLIF($2, 0), $3, 'PENDING', $4, NOW(), COALESCE(priority, 0) FROM workflows WHERE id = $1`,
+		workflowID, personID, nodeID, contextJSON)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
@@ -79,7 +83,11 @@ func main() {
 
 	// Migration fix/init
 	db.GetDB().Exec("ALTER TABLE organizations ADD COLUMN IF NOT EXISTS system_prompt TEXT")
+	db.GetDB().Exec("ALTER TABLE organizations ADD COLUMN IF NOT EXISTS http_allowlist TEXT[] DEFAULT '{}'")
+	db.GetDB().Exec("ALTER TABLE organizations ADD COLUMN IF NOT EXISTS daily_message_cap INTEGER DEFAULT 0")
+	db.GetDB().Exec("ALTER TABLE organizations ADD COLUMN IF NOT EXISTS send_window JSONB")
 	db.GetDB().Exec("ALTER TABLE sites ADD COLUMN IF NOT EXISTS tracking_id TEXT UNIQUE")
+	db.GetDB().Exec("ALTER TABLE people ADD COLUMN IF NOT EXISTS timezone TEXT")
 
 	// Workflow Migrations
 	db.GetDB().Exec("ALTER TABLE workflows ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id)")
@@ -89,6 +97,12 @@ func main() {
 	db.GetDB().Exec("ALTER TABLE workflows ADD COLUMN IF NOT EXISTS next_run_at TIMESTAMP WITH TIME ZONE")
 	db.GetDB().Exec("ALTER TABLE workflows ALTER COLUMN site_id DROP NOT NULL")
 	db.GetDB().Exec("ALTER TABLE workflows ALTER COLUMN trigger_event DROP NOT NULL")
+	db.GetDB().Exec("ALTER TABLE workflows ADD COLUMN IF NOT EXISTS published_version_id INTEGER")
+	db.GetDB().Exec("ALTER TABLE workflows ADD COLUMN IF NOT EXISTS goal JSONB")
+	db.GetDB().Exec("ALTER TABLE workflows ADD COLUMN IF NOT EXISTS entry_rule JSONB")
+	db.GetDB().Exec("ALTER TABLE workflows ADD COLUMN IF NOT EXISTS send_window JSONB")
+	db.GetDB().Exec("ALTER TABLE workflows ADD COLUMN IF NOT EXISTS max_duration_hours INTEGER")
+	db.GetDB().Exec("ALTER TABLE workflows ADD COLUMN IF NOT EXISTS priority INTEGER DEFAULT 0")
 
 	// Triggers
 	db.GetDB().Exec(`CREATE TABLE IF NOT EXISTS workflow_triggers (
@@ -105,6 +119,18 @@ func main() {
 	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS has_failed BOOLEAN DEFAULT FALSE")
 	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS result TEXT")
 	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS context TEXT")
+	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS locked_by TEXT")
+	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMP WITH TIME ZONE")
+	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS priority INTEGER DEFAULT 0")
+	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS attempt INTEGER DEFAULT 0")
+	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS resume_handle TEXT")
+	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS version_id INTEGER")
+	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS fanout_key TEXT")
+	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS fork_id INTEGER REFERENCES workflow_executions(id) ON DELETE CASCADE")
+	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS branch TEXT")
+	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS parent_execution_id INTEGER REFERENCES workflow_executions(id) ON DELETE SET NULL")
+	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS parent_node_id TEXT")
+	db.GetDB().Exec("ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS depth INTEGER DEFAULT 0")
 
 	// Create tables if they didn't exist
 	// Tables are handled by schema.sql migration logic below
@@ -131,9 +157,37 @@ func main() {
 
 	// Workflows & Events
 	e.POST("/workflows", handlers.CreateWorkflow)
+	e.POST("/workflows/validate", handlers.ValidateWorkflow)
 	e.GET("/workflows", handlers.ListWorkflows)
 	e.GET("/workflows/:id", handlers.GetWorkflow)
 	e.PUT("/workflows/:id", handlers.UpdateWorkflow)
+	e.GET("/workflows/:id/triggers/:node_id/preview", handlers.PreviewTriggerSchedule)
+	e.GET("/workflows/:id/webhooks", handlers.ListWorkflowWebhooks)
+	e.GET("/workflows/:id/versions", handlers.ListWorkflowVersions)
+	e.GET("/workflows/:id/versions/diff", handlers.DiffWorkflowVersions)
+	e.GET("/workflows/:id/versions/:version", handlers.GetWorkflowVersion)
+	e.POST("/workflows/:id/versions/:version/publish", handlers.PublishWorkflowVersion)
+	e.POST("/workflows/:id/rollback", handlers.RollbackWorkflow)
+	e.POST("/workflows/:id/simulate", handlers.SimulateWorkflow)
+	e.GET("/workflows/:id/conversions", handlers.GetWorkflowConversions)
+	e.GET("/workflows/:id/splits/:node_id/stats", handlers.GetSplitStats)
+	e.POST("/public/hooks/:trigger_token", handlers.ReceiveWebhook)
+
+	// Workflow Executions
+	e.GET("/workflow-executions", handlers.ListExecutions)
+	e.GET("/workflow-executions/:id", handlers.GetExecution)
+	e.POST("/workflow-executions/:id/cancel", handlers.CancelExecution)
+	e.POST("/workflow-executions/:id/retry", handlers.RetryExecution)
+	e.POST("/workflows/:id/executions/cancel", handlers.CancelWorkflowExecutions)
+	e.GET("/workflow-executions/dead-letter", handlers.ListDeadLetterExecutions)
+	e.GET("/workflow-executions/stuck", handlers.ListStuckExecutions)
+	e.POST("/workflow-executions/:id/replay", handlers.RetryExecution)
+
+	// Workflow Approvals
+	e.GET("/approvals", handlers.ListApprovals)
+	e.GET("/approvals/:id", handlers.GetApproval)
+	e.POST("/approvals/:id/approve", handlers.ApproveApproval)
+	e.POST("/approvals/:id/reject", handlers.RejectApproval)
 	e.POST("/events/definitions", handlers.CreateEventDefinition)
 	e.GET("/events/definitions", handlers.ListEventDefinitions)
 
@@ -237,6 +291,17 @@ func main() {
 	// Organizations
 	e.GET("/organizations/:id", handlers.GetOrganization)
 	e.PUT("/organizations/:id", handlers.UpdateOrganization)
+	e.GET("/organizations/:id/http-allowlist", handlers.GetHTTPAllowlist)
+	e.PUT("/organizations/:id/http-allowlist", handlers.UpdateHTTPAllowlist)
+	e.GET("/organizations/:id/message-cap", handlers.GetMessageCap)
+	e.PUT("/organizations/:id/message-cap", handlers.UpdateMessageCap)
+	e.GET("/organizations/:id/send-window", handlers.GetSendWindow)
+	e.PUT("/organizations/:id/send-window", handlers.UpdateSendWindow)
+
+	// HTTP Credentials (secrets kept in the secret store)
+	e.POST("/http-credentials", handlers.CreateHTTPCredential)
+	e.GET("/http-credentials", handlers.ListHTTPCredentials)
+	e.DELETE("/http-credentials/:id", handlers.DeleteHTTPCredential)
 
 	// Integrations
 	e.POST("/integrations", handlers.CreateIntegration)
@@ -289,11 +354,42 @@ func main() {
 		log.Println("schema.sql not found, skipping migration")
 	}
 
+	// Give workflows saved before versioning their first published version
+	db.GetDB().Exec(`
+		INSERT INTO workflow_versions (workflow_id, version, steps, published_at)
+		SELECT w.id, 1, w.steps, NOW() FROM workflows w
+		WHERE NOT EXISTS (SELECT 1 FROM workflow_versions v WHERE v.workflow_id = w.id)
+	`)
+	db.GetDB().Exec(`
+		UPDATE workflows w SET published_version_id = v.id
+		FROM workflow_versions v
+		WHERE w.published_version_id IS NULL AND v.workflow_id = w.id AND v.version = 1
+	`)
+
 	// Start Server
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 	log.Println("Starting server on port " + port)
-	e.Logger.Fatal(e.Start(":" + port))
+	go func() {
+		if err := e.Start(":" + port); err != nil && err != http.ErrServerClosed {
+			e.Logger.Fatal(err)
+		}
+	}()
+
+	// Graceful Shutdown: stop accepting requests, then let the worker drain
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := e.Shutdown(ctx); err != nil {
+		log.Println("Server shutdown error:", err)
+	}
+	if err := scheduler.Stop(ctx); err != nil {
+		log.Println("Worker did not drain before timeout:", err)
+	}
 }
@@ -71,13 +71,26 @@ type Routine struct {
 type RunStatus string
 
 const (
-	StatusPending         RunStatus = "PENDING"
-	StatusRunning         RunStatus = "RUNNING"
-	StatusCompleted       RunStatus = "COMPLETED"
-	StatusFailed          RunStatus = "FAILED"
-	StatusWaitingForHuman RunStatus = "WAITING_FOR_HUMAN"
+	StatusPending            RunStatus = "PENDING"
+	StatusRunning            RunStatus = "RUNNING"
+	StatusCompleted          RunStatus = "COMPLETED"
+	StatusFailed             RunStatus = "FAILED"
+	StatusWaitingForHuman    RunStatus = "WAITING_FOR_HUMAN"
+	StatusWaitingForEvent    RunStatus = "WAITING_FOR_EVENT"    // Parked at a WAIT_FOR_EVENT node
+	StatusWaitingForBranches RunStatus = "WAITING_FOR_BRANCHES" // Forked into parallel branches that have not finished
+	StatusWaitingForChild    RunStatus = "WAITING_FOR_CHILD"    // Waiting for a workflow started by Run Workflow
+	StatusDeadLetter         RunStatus = "DEAD_LETTER"          // A node with a retry policy gave up; can be inspected and replayed
+	StatusCancelled          RunStatus = "CANCELLED"            // Stopped through the API before finishing
+	StatusExited             RunStatus = "EXITED"               // Left early because the person reached the workflow's goal
+	StatusTimedOut           RunStatus = "TIMED_OUT"            // Ended by the reaper after running longer than allowed
 )
 
+// ActiveStatuses are the statuses of workflow executions that have not finished
+var ActiveStatuses = []string{string(StatusPending), string(StatusWaitingForHuman), string(StatusWaitingForEvent), string(StatusWaitingForBranches), string(StatusWaitingForChild)}
+
+// ExecutionStatuses are all the statuses a workflow execution can have
+var ExecutionStatuses = append([]string{string(StatusRunning), string(StatusCompleted), string(StatusFailed), string(StatusDeadLetter), string(StatusCancelled), string(StatusExited), string(StatusTimedOut)}, ActiveStatuses...)
+
 type RoutineRun struct {
 	ID         int       `json:"id"`
 	RoutineID  int       `json:"routine_id"`
@@ -188,6 +201,7 @@ type Person struct {
 	Ethnicity         string        `json:"ethnicity"`
 	Gender            string        `json:"gender"`
 	Location          string        `json:"location"` // Kept as string to match TEXT column
+	TimeZone          string        `json:"timezone"` // IANA name, e.g. Europe/Paris
 	LastInteractionAt *time.Time    `json:"last_interaction_at"`
 	Score             *int          `json:"score"`
 	Events            []PersonEvent `json:"events,omitempty"` // populated on detail view
@@ -3,9 +3,15 @@ package models
 type NodeType string
 
 const (
-	NodeTypeTrigger   NodeType = "TRIGGER"
-	NodeTypeAction    NodeType = "ACTION"
-	NodeTypeCondition NodeType = "CONDITION"
+	NodeTypeTrigger      NodeType = "TRIGGER"
+	NodeTypeAction       NodeType = "ACTION"
+	NodeTypeCondition    NodeType = "CONDITION"
+	NodeTypeApproval     NodeType = "APPROVAL"
+	NodeTypeWaitForEvent NodeType = "WAIT_FOR_EVENT"
+	NodeTypeJoin         NodeType = "JOIN"
+	NodeTypeSplit        NodeType = "SPLIT"
+	NodeTypeLoop         NodeType = "LOOP"
+	NodeTypeDelay        NodeType = "DELAY"
 )
 
 type ActionType string
@@ -20,4 +26,5 @@ type TriggerType string
 const (
 	TriggerTypeEvent    TriggerType = "EVENT"
 	TriggerTypeSchedule TriggerType = "SCHEDULE"
+	TriggerTypeWebhook  TriggerType = "WEBHOOK"
 )
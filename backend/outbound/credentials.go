@@ -0,0 +1,82 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/secrets"
+)
+
+// Supported HTTP credential types
+const (
+	AuthBearer = "bearer" // Authorization: Bearer <secret>
+	AuthBasic  = "basic"  // Authorization: Basic base64(username:secret)
+	AuthHeader = "header" // <header_name>: <secret>
+)
+
+// HTTPCredential is a stored credential that HTTP Request actions can reference
+// by ID. The secret itself lives in the secret store, never in node properties.
+type HTTPCredential struct {
+	ID             int
+	OrganizationID int
+	AuthType       string
+	HeaderName     string
+	Username       string
+	Secret         string
+}
+
+// CredentialSecretPath is where a credential's secret is kept in the secret store
+func CredentialSecretPath(credentialID int) string {
+	return fmt.Sprintf("http_credentials/%d", credentialID)
+}
+
+// LoadHTTPCredential loads a credential belonging to the organization, with its secret
+func LoadHTTPCredential(ctx context.Context, orgID, credentialID int) (*HTTPCredential, error) {
+	conn := db.GetDB()
+	if conn == nil {
+		return nil, errors.New("database connection not available")
+	}
+
+	cred := HTTPCredential{ID: credentialID}
+	err := conn.QueryRowContext(ctx, `
+		SELECT organization_id, auth_type, COALESCE(header_name, '') FROM http_credentials WHERE id = $1 AND organization_id = $2
+	`, credentialID, orgID).Scan(&cred.OrganizationID, &cred.AuthType, &cred.HeaderName)
+	if err != nil {
+		return nil, fmt.Errorf("credential %d not found for organization %d: %w", credentialID, orgID, err)
+	}
+
+	if secrets.GlobalSecretStore == nil {
+		return nil, errors.New("secret store not configured")
+	}
+	data, err := secrets.GlobalSecretStore.Read(ctx, CredentialSecretPath(credentialID))
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("secret for credential %d is missing", credentialID)
+	}
+	cred.Secret, _ = data["secret"].(string)
+	cred.Username, _ = data["username"].(string)
+	return &cred, nil
+}
+
+// Apply adds the credential to an outgoing request
+func (c *HTTPCredential) Apply(req *http.Request) error {
+	switch c.AuthType {
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+c.Secret)
+	case AuthBasic:
+		req.SetBasicAuth(c.Username, c.Secret)
+	case AuthHeader:
+		if c.HeaderName == "" {
+			return errors.New("header credential has no header name")
+		}
+		req.Header.Set(c.HeaderName, c.Secret)
+	default:
+		return fmt.Errorf("unsupported credential type %q", c.AuthType)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/wesuuu/helpnow/backend/db"
+)
+
+// ErrBlockedDestination is returned when a request targets a private or otherwise
+// internal address that the organization's allowlist does not permit.
+var ErrBlockedDestination = errors.New("destination address is not allowed")
+
+// blockedPrefixes are special-purpose ranges not covered by the netip helpers
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // Reserved
+}
+
+// HTTPPolicy decides which destinations outbound HTTP requests may reach. Public
+// addresses are always allowed; internal ones only if listed in Allowlist.
+type HTTPPolicy struct {
+	// Allowlist entries are hostnames ("api.internal"), wildcard suffixes
+	// ("*.corp.example.com") or CIDR ranges ("10.0.0.0/8").
+	Allowlist []string
+}
+
+// ValidateAllowlistEntry checks that an allowlist entry is a hostname, wildcard or CIDR
+func ValidateAllowlistEntry(entry string) error {
+	if _, err := netip.ParsePrefix(entry); err == nil {
+		return nil
+	}
+	if _, err := netip.ParseAddr(entry); err == nil {
+		return nil
+	}
+	host := strings.TrimPrefix(entry, "*.")
+	if host == "" || strings.ContainsAny(host, "/:* ") {
+		return fmt.Errorf("invalid allowlist entry %q: use a hostname, *.domain or CIDR range", entry)
+	}
+	return nil
+}
+
+// hostAllowed reports whether a hostname is allowlisted by name
+func (p *HTTPPolicy) hostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, entry := range p.Allowlist {
+		entry = strings.ToLower(entry)
+		if suffix, ok := strings.CutPrefix(entry, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckAddr returns ErrBlockedDestination if addr is internal and not allowlisted
+func (p *HTTPPolicy) CheckAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+	if !isInternal(addr) {
+		return nil
+	}
+	for _, entry := range p.Allowlist {
+		if prefix, err := netip.ParsePrefix(entry); err == nil && prefix.Contains(addr) {
+			return nil
+		}
+		if allowed, err := netip.ParseAddr(entry); err == nil && allowed.Unmap() == addr {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrBlockedDestination, addr)
+}
+
+func isInternal(addr netip.Addr) bool {
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialContext resolves the host itself and checks every address before
+// connecting, so a DNS answer cannot point a public name at an internal address.
+func (p *HTTPPolicy) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	if p.hostAllowed(host) {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if err := p.CheckAddr(addr); err != nil {
+			return nil, fmt.Errorf("%s resolves to a blocked address: %w", host, err)
+		}
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// maxCachedTransports caps the transports kept for reuse; past it the cache is
+// emptied and their idle connections closed
+const maxCachedTransports = 256
+
+var (
+	transportsMu sync.Mutex
+	transports   = map[string]*http.Transport{}
+)
+
+// transportFor returns the shared transport for a policy, so requests made under
+// the same allowlist reuse its keep-alive connections
+func transportFor(policy *HTTPPolicy) *http.Transport {
+	key := strings.Join(policy.Allowlist, "\n")
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if transport, ok := transports[key]; ok {
+		return transport
+	}
+	if len(transports) >= maxCachedTransports {
+		for _, transport := range transports {
+			transport.CloseIdleConnections()
+		}
+		transports = map[string]*http.Transport{}
+	}
+
+	// The transport outlives this call, so it dials with its own copy of the policy
+	owned := &HTTPPolicy{Allowlist: append([]string(nil), policy.Allowlist...)}
+	transport := &http.Transport{
+		Proxy:               nil, // A proxy would bypass the address checks
+		DialContext:         owned.dialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     30 * time.Second,
+	}
+	transports[key] = transport
+	return transport
+}
+
+// NewHTTPClient returns a client that enforces the policy on every connection,
+// including redirects. Clients for the same policy share a transport.
+func NewHTTPClient(policy *HTTPPolicy, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: transportFor(policy),
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("stopped after 5 redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// OrgHTTPAllowlist loads the organization's outbound HTTP allowlist
+func OrgHTTPAllowlist(ctx context.Context, orgID int) ([]string, error) {
+	conn := db.GetDB()
+	if conn == nil {
+		return nil, errors.New("database connection not available")
+	}
+
+	var allowlist []string
+	err := conn.QueryRowContext(ctx, `SELECT COALESCE(http_allowlist, '{}') FROM organizations WHERE id = $1`, orgID).Scan(pq.Array(&allowlist))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load allowlist for organization %d: %w", orgID, err)
+	}
+	return allowlist, nil
+}
@@ -0,0 +1,98 @@
+package outbound
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestCheckAddr(t *testing.T) {
+	policy := &HTTPPolicy{}
+	blocked := []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fd00::1", "::ffff:127.0.0.1"}
+	for _, s := range blocked {
+		if err := policy.CheckAddr(netip.MustParseAddr(s)); !errors.Is(err, ErrBlockedDestination) {
+			t.Errorf("Expected %s to be blocked, got %v", s, err)
+		}
+	}
+	for _, s := range []string{"8.8.8.8", "2606:4700::1111"} {
+		if err := policy.CheckAddr(netip.MustParseAddr(s)); err != nil {
+			t.Errorf("Expected %s to be allowed, got %v", s, err)
+		}
+	}
+
+	policy.Allowlist = []string{"10.0.0.0/8", "192.168.1.5"}
+	if err := policy.CheckAddr(netip.MustParseAddr("10.1.2.3")); err != nil {
+		t.Errorf("Expected allowlisted range to pass, got %v", err)
+	}
+	if err := policy.CheckAddr(netip.MustParseAddr("192.168.1.5")); err != nil {
+		t.Errorf("Expected allowlisted address to pass, got %v", err)
+	}
+	if err := policy.CheckAddr(netip.MustParseAddr("192.168.1.6")); err == nil {
+		t.Error("Expected address outside the allowlist to be blocked")
+	}
+}
+
+func TestHostAllowed(t *testing.T) {
+	policy := &HTTPPolicy{Allowlist: []string{"api.internal", "*.corp.example.com"}}
+	for host, want := range map[string]bool{
+		"api.internal":         true,
+		"API.internal.":        true,
+		"svc.corp.example.com": true,
+		"corp.example.com":     false,
+		"evilcorp.example.com": false,
+		"other.internal":       false,
+	} {
+		if got := policy.hostAllowed(host); got != want {
+			t.Errorf("hostAllowed(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestValidateAllowlistEntry(t *testing.T) {
+	for _, entry := range []string{"10.0.0.0/8", "127.0.0.1", "api.internal", "*.corp.example.com"} {
+		if err := ValidateAllowlistEntry(entry); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", entry, err)
+		}
+	}
+	for _, entry := range []string{"", "*", "http://x", "10.0.0.0/99", "a b"} {
+		if err := ValidateAllowlistEntry(entry); err == nil {
+			t.Errorf("Expected %q to be rejected", entry)
+		}
+	}
+}
+
+func TestHTTPClientBlocksLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	_, err := NewHTTPClient(&HTTPPolicy{}, 5*time.Second).Get(server.URL)
+	if !errors.Is(err, ErrBlockedDestination) {
+		t.Fatalf("Expected loopback request to be blocked, got %v", err)
+	}
+
+	resp, err := NewHTTPClient(&HTTPPolicy{Allowlist: []string{"127.0.0.0/8"}}, 5*time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected allowlisted request to succeed, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPClientSharesTransport(t *testing.T) {
+	a := NewHTTPClient(&HTTPPolicy{Allowlist: []string{"10.0.0.0/8"}}, 5*time.Second)
+	b := NewHTTPClient(&HTTPPolicy{Allowlist: []string{"10.0.0.0/8"}}, 30*time.Second)
+	c := NewHTTPClient(&HTTPPolicy{}, 5*time.Second)
+	if a.Transport != b.Transport {
+		t.Error("Expected clients for the same allowlist to share a transport")
+	}
+	if a.Transport == c.Transport {
+		t.Error("Expected clients for different allowlists to use different transports")
+	}
+}
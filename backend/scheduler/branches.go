@@ -0,0 +1,353 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// Parallel branches
+//
+// When a node has several outgoing edges on the handle it leaves through, each
+// target runs as a branch: a child execution with fork_id pointing at the
+// execution that forked. Branches are claimed, leased, retried and parked like any
+// other execution. The forking execution waits in WAITING_FOR_BRANCHES until they
+// settle: it resumes at the JOIN node they met at, or finishes when every branch
+// ended without one. Branch step results and context are merged back into it.
+
+var errLeaseLost = errors.New("lease lost")
+
+// branchLabel names a branch after the node it starts at, nested under the label
+// of the branch that forked it
+func branchLabel(parent, target string) string {
+	if parent == "" {
+		return target
+	}
+	return parent + "/" + target
+}
+
+// forkExecution starts a branch per target and parks the execution until they settle
+func forkExecution(exec ScheduledExecution, graph workflows.Graph, targets []string) error {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, target := range targets {
+		if err := startBranch(tx, exec, graph, target, branchLabel(exec.Branch.String, target), nil); err != nil {
+			return err
+		}
+	}
+	if err := parkForBranches(tx, exec); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// startBranch inserts a branch of exec starting at target. It runs with the
+// given context, or a copy of exec's when ctxData is nil, at exec's Run Workflow
+// depth. It is not a child: only exec reports back to a waiting parent.
+func startBranch(tx *sql.Tx, exec ScheduledExecution, graph workflows.Graph, target, label string, ctxData map[string]interface{}) error {
+	runAt := time.Now()
+	if node := findNode(graph, target); node != nil {
+		runAt = runAt.Add(nodeDelay(node))
+	}
+	var contextJSON sql.NullString
+	if ctxData != nil {
+		data, _ := json.Marshal(ctxData)
+		contextJSON = sql.NullString{String: string(data), Valid: true}
+	}
+	res, err := tx.Exec(`
+		INSERT INTO workflow_executions (workflow_id, version_id, subject_id, current_node_id, status, next_run_at, created_at, context, priority, fork_id, branch, depth)
+		SELECT workflow_id, version_id, subject_id, $2, 'PENDING', $3, NOW(), COALESCE($6, context), priority, id, $4, depth
+		FROM workflow_executions
+		WHERE id = $1 AND locked_by = $5
+	`, exec.ID, target, runAt, label, workerID, contextJSON)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errLeaseLost
+	}
+	return nil
+}
+
+// parkForBranches releases the execution to wait in WAITING_FOR_BRANCHES
+func parkForBranches(tx *sql.Tx, exec ScheduledExecution) error {
+	_, err := tx.Exec(`
+		UPDATE workflow_executions
+		SET status = $2, next_run_at = NULL, attempt = 0, resume_handle = NULL, locked_by = NULL, lease_expires_at = NULL
+		WHERE id = $1 AND locked_by = $3
+	`, exec.ID, string(models.StatusWaitingForBranches), workerID)
+	return err
+}
+
+// arriveAtJoin ends a branch at a JOIN node and lets the forking execution
+// continue if the join's condition is now met. With mode "any" the branches still
+// running are cancelled.
+func arriveAtJoin(exec ScheduledExecution, node *workflows.Node) {
+	join, err := workflows.ParseJoinNode(node.Properties)
+	if err != nil {
+		recordStepResult(exec.ID, exec.ResultJSON, StepResult{NodeID: node.ID, Status: "failed", Output: "Invalid join node", Error: err.Error(), Branch: exec.Branch.String}, true)
+		finishExecution(exec, "FAILED", "Invalid join node")
+		return
+	}
+	recordStepResult(exec.ID, exec.ResultJSON, StepResult{NodeID: node.ID, Status: "success", Output: "Arrived at join", Branch: exec.Branch.String}, exec.HasFailed)
+
+	err = func() error {
+		tx, err := db.GetDB().Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`
+			UPDATE workflow_executions
+			SET status = 'COMPLETED', result = $2, finished_at = NOW(), locked_by = NULL, lease_expires_at = NULL
+			WHERE id = $1 AND locked_by = $3
+		`, exec.ID, "Joined at "+node.ID, workerID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return errLeaseLost
+		}
+
+		forkID := int(exec.ForkID.Int64)
+		if join.Mode == workflows.JoinAny {
+			if err := CancelBranches(tx, forkID, "Another branch reached "+node.ID+" first"); err != nil {
+				return err
+			}
+		}
+		if err := SettleFork(tx, forkID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}()
+	if err != nil {
+		Logger.Errorf("[Worker] Failed to join branch %d at %s: %v", exec.ID, node.ID, err)
+	}
+}
+
+// CancelBranches cancels the unfinished branches of an execution, and theirs
+func CancelBranches(tx *sql.Tx, forkID int, reason string) error {
+	rows, err := tx.Query(`
+		WITH RECURSIVE tree AS (
+			SELECT id FROM workflow_executions WHERE fork_id = $1
+			UNION
+			SELECT we.id FROM workflow_executions we JOIN tree t ON we.fork_id = t.id
+		)
+		UPDATE workflow_executions
+		SET status = $2, result = $3, finished_at = NOW(), resume_handle = NULL, locked_by = NULL, lease_expires_at = NULL
+		WHERE id IN (SELECT id FROM tree) AND status = ANY($4)
+		RETURNING id
+	`, forkID, string(models.StatusCancelled), reason, pq.Array(models.ActiveStatuses))
+	if err != nil {
+		return err
+	}
+	cancelled := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		cancelled = append(cancelled, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return CancelWaits(tx, cancelled)
+}
+
+// finishExecution marks an execution final and, in the same transaction, settles
+// the execution that forked it if it is a branch and resumes the one that started
+// it if it is a sub-workflow. Nothing changes if the worker lost the lease.
+func finishExecution(exec ScheduledExecution, status string, resultReason string) {
+	err := func() error {
+		tx, err := db.GetDB().Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`
+			UPDATE workflow_executions
+			SET status = $2, result = $3, finished_at = NOW(), locked_by = NULL, lease_expires_at = NULL
+			WHERE id = $1 AND locked_by = $4
+		`, exec.ID, status, resultReason, workerID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return errLeaseLost
+		}
+
+		if exec.ForkID.Valid {
+			if err := SettleFork(tx, int(exec.ForkID.Int64)); err != nil {
+				return fmt.Errorf("failed to settle branches of execution %d: %w", exec.ForkID.Int64, err)
+			}
+		}
+		if exec.ForkID.Valid || exec.ParentID.Valid {
+			if err := ResumeParent(tx, exec.ID); err != nil {
+				return fmt.Errorf("failed to resume parent: %w", err)
+			}
+		}
+		return tx.Commit()
+	}()
+	if err != nil {
+		Logger.Errorf("[Worker] Failed to finish execution %d as %s: %v", exec.ID, status, err)
+	}
+}
+
+// SettleFork checks an execution waiting on parallel branches. Once none are
+// active it merges their step results and context, then resumes the execution at
+// the JOIN node they met at or, without one, finishes it: FAILED if any branch
+// failed, COMPLETED otherwise. Loop iterations are summarised under the LOOP node
+// instead, which continues on its "done" handle. Call it whenever a branch ends
+// outside the worker.
+func SettleFork(tx *sql.Tx, forkID int) error {
+	var status, graphJSON string
+	var parentFork sql.NullInt64
+	var currentNodeID, contextStr, resultsStr sql.NullString
+	var hasFailed bool
+	err := tx.QueryRow(`
+		SELECT we.status, we.fork_id, we.current_node_id, COALESCE(v.steps, pv.steps, w.steps), we.context, we.step_results, COALESCE(we.has_failed, FALSE)
+		FROM workflow_executions we
+		JOIN workflows w ON w.id = we.workflow_id
+		LEFT JOIN workflow_versions v ON v.id = we.version_id
+		LEFT JOIN workflow_versions pv ON pv.id = w.published_version_id
+		WHERE we.id = $1
+		FOR UPDATE OF we
+	`, forkID).Scan(&status, &parentFork, &currentNodeID, &graphJSON, &contextStr, &resultsStr, &hasFailed)
+	if err != nil {
+		return err
+	}
+	if status != string(models.StatusWaitingForBranches) {
+		return nil
+	}
+
+	var graph workflows.Graph
+	json.Unmarshal([]byte(graphJSON), &graph)
+	loopNode := findNode(graph, currentNodeID.String)
+	if loopNode != nil && loopNode.Type != string(models.NodeTypeLoop) {
+		loopNode = nil
+	}
+
+	ctxData := map[string]interface{}{}
+	if contextStr.Valid && contextStr.String != "" {
+		json.Unmarshal([]byte(contextStr.String), &ctxData)
+	}
+	var results []StepResult
+	if resultsStr.Valid && resultsStr.String != "" {
+		json.Unmarshal([]byte(resultsStr.String), &results)
+	}
+
+	rows, err := tx.Query(`
+		SELECT status, current_node_id, context, step_results
+		FROM workflow_executions WHERE fork_id = $1 ORDER BY id
+	`, forkID)
+	if err != nil {
+		return err
+	}
+	joinNodeID := ""
+	failed := false
+	iterations := []map[string]interface{}{}
+	for rows.Next() {
+		var branchStatus string
+		var nodeID, branchContext, branchResults sql.NullString
+		if err := rows.Scan(&branchStatus, &nodeID, &branchContext, &branchResults); err != nil {
+			rows.Close()
+			return err
+		}
+		for _, active := range models.ActiveStatuses {
+			if branchStatus == active {
+				rows.Close()
+				return nil // Still running
+			}
+		}
+
+		switch branchStatus {
+		case string(models.StatusFailed), string(models.StatusDeadLetter):
+			failed = true
+		case string(models.StatusCompleted):
+			if node := findNode(graph, nodeID.String); joinNodeID == "" && node != nil && node.Type == string(models.NodeTypeJoin) {
+				joinNodeID = node.ID
+			}
+		}
+
+		branchData := map[string]interface{}{}
+		if branchContext.Valid && branchContext.String != "" {
+			json.Unmarshal([]byte(branchContext.String), &branchData)
+		}
+		var branchSteps []StepResult
+		if branchResults.Valid && branchResults.String != "" {
+			json.Unmarshal([]byte(branchResults.String), &branchSteps)
+		}
+		results = append(results, branchSteps...)
+
+		if loopNode != nil {
+			iterations = append(iterations, loopIteration(branchStatus, branchData, branchSteps))
+			continue
+		}
+		for k, v := range branchData {
+			ctxData[k] = v
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if loopNode != nil {
+		ctxData[loopNode.ID] = loopSummary(ctxData[loopNode.ID], iterations)
+	}
+	contextJSON, _ := json.Marshal(ctxData)
+	resultsJSON, _ := json.Marshal(results)
+
+	if loopNode != nil {
+		_, err = tx.Exec(`
+			UPDATE workflow_executions
+			SET status = 'PENDING', resume_handle = $2, next_run_at = NOW(),
+				context = $3, step_results = $4, has_failed = $5
+			WHERE id = $1
+		`, forkID, workflows.HandleDone, string(contextJSON), string(resultsJSON), hasFailed || failed)
+		return err
+	}
+
+	if joinNodeID != "" {
+		_, err = tx.Exec(`
+			UPDATE workflow_executions
+			SET status = 'PENDING', current_node_id = $2, resume_handle = 'default', next_run_at = NOW(),
+				context = $3, step_results = $4, has_failed = $5
+			WHERE id = $1
+		`, forkID, joinNodeID, string(contextJSON), string(resultsJSON), hasFailed || failed)
+		return err
+	}
+
+	finalStatus, reason := string(models.StatusCompleted), ""
+	if failed {
+		finalStatus, reason = string(models.StatusFailed), "A parallel branch failed"
+	}
+	_, err = tx.Exec(`
+		UPDATE workflow_executions
+		SET status = $2, result = $3, finished_at = NOW(), context = $4, step_results = $5, has_failed = $6
+		WHERE id = $1
+	`, forkID, finalStatus, reason, string(contextJSON), string(resultsJSON), hasFailed || failed)
+	if err != nil {
+		return err
+	}
+	if parentFork.Valid {
+		return SettleFork(tx, int(parentFork.Int64))
+	}
+	return ResumeParent(tx, forkID)
+}
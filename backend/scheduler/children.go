@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+)
+
+// ResumeParent continues the execution that started childID with Run Workflow, if
+// it is waiting for it. The child's status, result and context are merged into the
+// parent's context under the Run Workflow node's ID. Call it whenever an execution
+// finishes; it does nothing for executions without a waiting parent.
+func ResumeParent(tx *sql.Tx, childID int) error {
+	var parentID sql.NullInt64
+	var parentNodeID, result, contextStr sql.NullString
+	var workflowID int
+	var status string
+	err := tx.QueryRow(`
+		SELECT parent_execution_id, parent_node_id, workflow_id, status, result, context
+		FROM workflow_executions WHERE id = $1
+	`, childID).Scan(&parentID, &parentNodeID, &workflowID, &status, &result, &contextStr)
+	if err != nil {
+		return err
+	}
+	if !parentID.Valid || !parentNodeID.Valid {
+		return nil
+	}
+	for _, active := range models.ActiveStatuses {
+		if status == active {
+			return nil
+		}
+	}
+
+	childContext := map[string]interface{}{}
+	if contextStr.Valid && contextStr.String != "" {
+		json.Unmarshal([]byte(contextStr.String), &childContext)
+	}
+	patch := map[string]interface{}{
+		parentNodeID.String: map[string]interface{}{
+			"execution_id": childID,
+			"workflow_id":  workflowID,
+			"status":       status,
+			"result":       result.String,
+			"context":      childContext,
+		},
+	}
+
+	// Parents that did not ask to wait, or stopped waiting, are left alone
+	var parentStatus string
+	err = tx.QueryRow(`SELECT status FROM workflow_executions WHERE id = $1`, parentID.Int64).Scan(&parentStatus)
+	if err != nil || parentStatus != string(models.StatusWaitingForChild) {
+		return err
+	}
+	err = ResumeExecution(tx, int(parentID.Int64), parentNodeID.String, "default", patch)
+	if errors.Is(err, ErrNotWaiting) {
+		return nil
+	}
+	return err
+}
+
+// resumeIfChildDone covers a child that finished before its parent parked: the
+// child found nobody waiting, so the parent checks once it is parked.
+func resumeIfChildDone(executionID int, nodeID string) {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		Logger.Error("Failed to check child execution:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	var childID int
+	err = tx.QueryRow(`
+		SELECT id FROM workflow_executions
+		WHERE parent_execution_id = $1 AND parent_node_id = $2
+		ORDER BY id DESC LIMIT 1
+	`, executionID, nodeID).Scan(&childID)
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err == nil {
+		err = ResumeParent(tx, childID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		Logger.Errorf("[Worker] Failed to check child of execution %d: %v", executionID, err)
+	}
+}
@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// Delays
+//
+// A DELAY node pauses the execution where it is: it stays PENDING on the node with
+// next_run_at set to the wake-up time, so the worker leaves it alone until then
+// and then follows the node's default handle. Wake-up times that have already
+// passed carry straight on.
+
+// scheduleDelay works out when the execution should wake and parks it until then.
+// The wake-up time is added to the context under the node ID.
+func scheduleDelay(ctx context.Context, exec ScheduledExecution, node *workflows.Node, ctxData map[string]interface{}) nodeOutcome {
+	properties, err := resolveProperties(ctx, exec, node, ctxData)
+	if err != nil {
+		return nodeOutcome{Status: "failed", Output: "Template error: " + err.Error(), Err: err}
+	}
+	delay, err := workflows.ParseDelayNode(properties)
+	if err != nil {
+		return nodeOutcome{Status: "failed", Output: "Invalid delay node", Err: err}
+	}
+
+	zone := ""
+	if exec.SubjectID.Valid {
+		if zone, err = personTimeZone(ctx, int(exec.SubjectID.Int64)); err != nil {
+			return nodeOutcome{Status: "failed", Output: "Failed to load the person's time zone", Err: err}
+		}
+	}
+	loc := delay.Location(zone)
+
+	now := time.Now()
+	wakeAt, err := delay.WakeAt(now, ctxData, loc)
+	if err != nil {
+		return nodeOutcome{Status: "failed", Output: "Could not work out when to wake", Err: err}
+	}
+	data := map[string]interface{}{"wake_at": wakeAt.UTC().Format(time.RFC3339), "time_zone": loc.String()}
+	if !wakeAt.After(now) {
+		return nodeOutcome{Status: "success", Output: "Wake-up time " + wakeAt.Format(time.RFC3339) + " has passed, continuing", Handle: "default", Data: data}
+	}
+	return pauseUntil(wakeAt, "Waiting until "+wakeAt.In(loc).Format(time.RFC3339), data)
+}
+
+// pauseUntil is the outcome of a node that holds the execution in PENDING until
+// wakeAt and then follows its default handle
+func pauseUntil(wakeAt time.Time, output string, data map[string]interface{}) nodeOutcome {
+	return nodeOutcome{
+		Status: "success",
+		Output: output,
+		Handle: "default",
+		Data:   data,
+		Park:   &parkRequest{Status: string(models.StatusPending), WakeAt: &wakeAt, Handle: "default"},
+	}
+}
+
+// personTimeZone returns a person's IANA time zone: the one set on them, else
+// one worked out from their location, else from the longitude they were last
+// seen at. It returns "" when there is nothing to go on.
+func personTimeZone(ctx context.Context, personID int) (string, error) {
+	var zone, place string
+	var metaLocation sql.NullString
+	err := db.GetDB().QueryRowContext(ctx, `
+		SELECT COALESCE(timezone, ''), COALESCE(location, ''), (meta->'location')::text
+		FROM people WHERE id = $1
+	`, personID).Scan(&zone, &place, &metaLocation)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load person %d: %w", personID, err)
+	}
+	if zone != "" {
+		return zone, nil
+	}
+	if zone := workflows.ZoneForPlace(place); zone != "" {
+		return zone, nil
+	}
+
+	var seen models.Location
+	if metaLocation.Valid && json.Unmarshal([]byte(metaLocation.String), &seen) == nil {
+		// Regions are left out: GeoIP gives them as subdivision codes, which
+		// clash with country codes (BE is both Berlin and Belgium)
+		if zone := workflows.ZoneForPlace(seen.City); zone != "" {
+			return zone, nil
+		}
+		if zone := workflows.ZoneForCountry(seen.Country); zone != "" {
+			return zone, nil
+		}
+		if seen.Latitude != 0 || seen.Longitude != 0 {
+			return workflows.ZoneForLongitude(seen.Longitude), nil
+		}
+	}
+	return "", nil
+}
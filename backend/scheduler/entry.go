@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// Entry rules and the daily message cap
+//
+// A workflow's entry rule limits how often one person may start it, and an
+// organization's daily message cap limits how many messages one person receives
+// in any 24 hours. Both are checked when an execution is created for a person;
+// the cap is checked again before each send, and a send over it is skipped.
+
+// entryPolicy is what decides whether a person may enter a workflow
+type entryPolicy struct {
+	Rule     workflows.EntryRule
+	DailyCap int // Zero for no cap
+}
+
+// loadEntryPolicy reads a workflow's entry rule and its organization's cap.
+// Workflows without a rule let everyone in every time.
+func loadEntryPolicy(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, workflowID int) (entryPolicy, error) {
+	var ruleStr sql.NullString
+	policy := entryPolicy{Rule: workflows.EntryRule{Mode: workflows.EntryAlways}}
+	err := q.QueryRow(`
+		SELECT w.entry_rule::text, COALESCE(o.daily_message_cap, 0)
+		FROM workflows w
+		LEFT JOIN organizations o ON o.id = w.organization_id
+		WHERE w.id = $1
+	`, workflowID).Scan(&ruleStr, &policy.DailyCap)
+	if err != nil {
+		return policy, err
+	}
+	if ruleStr.Valid {
+		json.Unmarshal([]byte(ruleStr.String), &policy.Rule)
+	}
+	return policy, nil
+}
+
+// admissionSQL holds when the person in column %[1]s may start another execution
+// of a workflow. Its parameters are the workflow ID, entry rule mode, period in
+// days, active statuses and daily cap, numbered from %[2]d.
+const admissionSQL = `NOT EXISTS (
+	SELECT 1 FROM workflow_executions prior
+	WHERE prior.workflow_id = $%[2]d AND prior.subject_id = %[1]s AND prior.fork_id IS NULL
+	AND ($%[3]d = 'once'
+		OR ($%[3]d = 'one_active' AND prior.status = ANY($%[5]d))
+		OR ($%[3]d = 'once_per_period' AND prior.created_at > NOW() - make_interval(days => $%[4]d)))
+) AND ($%[6]d = 0 OR (
+	SELECT COUNT(*) FROM message_deliveries md
+	WHERE md.person_id = %[1]s AND md.sent_at > NOW() - INTERVAL '1 day'
+) < $%[6]d)`
+
+// admission returns admissionSQL for a person column with its parameters
+// numbered from first, and the arguments to pass for them
+func (p entryPolicy) admission(personColumn string, first int, workflowID int) (string, []interface{}) {
+	query := fmt.Sprintf(admissionSQL, personColumn, first, first+1, first+2, first+3, first+4)
+	args := []interface{}{workflowID, p.Rule.Mode, p.Rule.PeriodDays, pq.Array(models.ActiveStatuses), p.DailyCap}
+	return query, args
+}
+
+// AdmitEntry reports whether a person may start a new execution of a workflow
+// under its entry rule and the daily message cap. Call it in the transaction that
+// inserts the execution: it locks the person so concurrent triggers are admitted
+// one at a time.
+func AdmitEntry(tx *sql.Tx, workflowID, personID int) (bool, error) {
+	policy, err := loadEntryPolicy(tx, workflowID)
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`SELECT 1 FROM people WHERE id = $1 FOR UPDATE`, personID); err != nil {
+		return false, err
+	}
+	cond, args := policy.admission("$1", 2, workflowID)
+	var admitted bool
+	err = tx.QueryRow(`SELECT `+cond, append([]interface{}{personID}, args...)...).Scan(&admitted)
+	return admitted, err
+}
+
+// reserveMessage counts a message to the execution's subject towards the daily
+// cap before it is sent. It returns the delivery ID, or zero when the person has
+// had their fill for the day.
+func reserveMessage(ctx context.Context, exec ScheduledExecution, nodeID, channel string) (int, error) {
+	tx, err := db.GetDB().BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	personID := exec.SubjectID.Int64
+	var dailyCap, sentToday int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE((SELECT daily_message_cap FROM organizations WHERE id = p.organization_id), 0),
+			(SELECT COUNT(*) FROM message_deliveries WHERE person_id = p.id AND sent_at > NOW() - INTERVAL '1 day')
+		FROM people p WHERE p.id = $1
+		FOR UPDATE OF p
+	`, personID).Scan(&dailyCap, &sentToday)
+	if err != nil {
+		return 0, err
+	}
+	if dailyCap > 0 && sentToday >= dailyCap {
+		return 0, nil
+	}
+
+	var deliveryID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO message_deliveries (organization_id, person_id, execution_id, node_id, channel)
+		VALUES (NULLIF($1, 0), $2, $3, $4, $5) RETURNING id
+	`, exec.OrgID.Int64, personID, exec.ID, nodeID, channel).Scan(&deliveryID)
+	if err != nil {
+		return 0, err
+	}
+	return deliveryID, tx.Commit()
+}
+
+// releaseMessage gives back a reservation whose send failed
+func releaseMessage(deliveryID int) {
+	if _, err := db.GetDB().Exec(`DELETE FROM message_deliveries WHERE id = $1`, deliveryID); err != nil {
+		Logger.Error("Failed to release message reservation:", err)
+	}
+}
@@ -0,0 +1,28 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+func TestEntryPolicyAdmission(t *testing.T) {
+	policy := entryPolicy{Rule: workflows.EntryRule{Mode: workflows.EntryOncePerPeriod, PeriodDays: 7}, DailyCap: 3}
+	query, args := policy.admission("p.id", 10, 42)
+
+	if len(args) != 5 || args[0] != 42 || args[1] != workflows.EntryOncePerPeriod || args[2] != 7 || args[4] != 3 {
+		t.Errorf("unexpected args %v", args)
+	}
+	for _, param := range []string{"$10", "$11", "$12", "$13", "$14"} {
+		if !strings.Contains(query, param) {
+			t.Errorf("expected %s in query:\n%s", param, query)
+		}
+	}
+	if strings.Contains(query, "$9") || strings.Contains(query, "$15") || strings.Contains(query, "%!") {
+		t.Errorf("query uses parameters it was not given:\n%s", query)
+	}
+	if !strings.Contains(query, "prior.subject_id = p.id") {
+		t.Errorf("expected the person column in query:\n%s", query)
+	}
+}
@@ -0,0 +1,176 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// eventWait is a WAIT_FOR_EVENT node's registration, stored as the execution
+// parks so that an event cannot arrive between the two and be missed
+type eventWait struct {
+	WorkflowID int
+	NodeID     string
+	SubjectID  int64
+	Event      string
+	Filter     map[string]interface{}
+	DueAt      *time.Time
+}
+
+// waitForEvent parks the execution in WAITING_FOR_EVENT until its subject's next
+// matching event
+func waitForEvent(ctx context.Context, exec ScheduledExecution, node *workflows.Node) nodeOutcome {
+	wait, err := workflows.ParseWaitForEventNode(node.Properties)
+	if err != nil {
+		return nodeOutcome{Status: "failed", Output: "Invalid wait for event node", Err: err}
+	}
+	if exec.DryRun {
+		return nodeOutcome{
+			Status: "success",
+			Output: "Would wait for event " + wait.Event,
+			Park:   &parkRequest{Status: string(models.StatusWaitingForEvent)},
+		}
+	}
+	if !exec.SubjectID.Valid {
+		return nodeOutcome{Status: "failed", Output: "Waiting for an event needs a subject", Err: errors.New("execution has no subject to wait on")}
+	}
+
+	var dueAt *time.Time
+	if timeout := wait.Timeout(); timeout > 0 {
+		t := time.Now().Add(timeout)
+		dueAt = &t
+	}
+
+	output := "Waiting for event " + wait.Event
+	if dueAt != nil {
+		output += " (times out " + dueAt.Format(time.RFC3339) + ")"
+	}
+	return nodeOutcome{
+		Status: "success",
+		Output: output,
+		Park: &parkRequest{
+			Status: string(models.StatusWaitingForEvent),
+			WakeAt: dueAt,
+			EventWait: &eventWait{
+				WorkflowID: exec.WorkflowID,
+				NodeID:     node.ID,
+				SubjectID:  exec.SubjectID.Int64,
+				Event:      wait.Event,
+				Filter:     wait.Filter,
+				DueAt:      dueAt,
+			},
+		},
+	}
+}
+
+// registerEventWait records that an execution waits for an event. It runs in
+// the transaction that parks the execution.
+func registerEventWait(tx *sql.Tx, executionID int, wait *eventWait) error {
+	filterJSON, _ := json.Marshal(wait.Filter)
+	_, err := tx.Exec(`
+		INSERT INTO workflow_event_waits (execution_id, workflow_id, node_id, subject_id, event_name, filter, due_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (execution_id) DO UPDATE
+		SET node_id = EXCLUDED.node_id, subject_id = EXCLUDED.subject_id, event_name = EXCLUDED.event_name,
+			filter = EXCLUDED.filter, due_at = EXCLUDED.due_at, created_at = NOW()
+	`, executionID, wait.WorkflowID, wait.NodeID, wait.SubjectID, wait.Event, string(filterJSON), wait.DueAt)
+	if err != nil {
+		return fmt.Errorf("failed to register event wait: %w", err)
+	}
+	return nil
+}
+
+// DeliverEvent resumes the executions waiting for this event from this person
+// whose filters match its properties. They continue on the "matched" handle with
+// the event under their node ID in the context. It returns how many resumed.
+func DeliverEvent(personID int, eventName string, properties map[string]interface{}) (int, error) {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Waits another delivery is resuming are skipped rather than waited on
+	rows, err := tx.Query(`
+		SELECT execution_id, node_id, COALESCE(filter, '{}')
+		FROM workflow_event_waits
+		WHERE subject_id = $1 AND event_name = $2
+		FOR UPDATE SKIP LOCKED
+	`, personID, eventName)
+	if err != nil {
+		return 0, err
+	}
+	type waiting struct {
+		executionID int
+		nodeID      string
+		filter      map[string]interface{}
+	}
+	candidates := []waiting{}
+	for rows.Next() {
+		var w waiting
+		var filterStr string
+		if err := rows.Scan(&w.executionID, &w.nodeID, &filterStr); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		json.Unmarshal([]byte(filterStr), &w.filter)
+		candidates = append(candidates, w)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	resumed := 0
+	for _, w := range candidates {
+		if !workflows.MatchesFilter(w.filter, properties) {
+			continue
+		}
+		patch := map[string]interface{}{
+			w.nodeID: map[string]interface{}{"event": eventName, "properties": properties},
+		}
+		// Waits are registered as their execution parks, so one whose execution
+		// is no longer waiting is stale and only needs clearing
+		err := ResumeExecution(tx, w.executionID, w.nodeID, workflows.HandleMatched, patch)
+		if err != nil && !errors.Is(err, ErrNotWaiting) {
+			return 0, err
+		}
+		if _, err := tx.Exec(`DELETE FROM workflow_event_waits WHERE execution_id = $1`, w.executionID); err != nil {
+			return 0, err
+		}
+		if err == nil {
+			resumed++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return resumed, nil
+}
+
+// expireEventWaits sends executions whose event did not arrive in time down the
+// "timeout" handle
+func expireEventWaits() {
+	_, err := db.GetDB().Exec(`
+		WITH expired AS (
+			DELETE FROM workflow_event_waits
+			WHERE due_at <= NOW()
+			RETURNING execution_id, node_id
+		)
+		UPDATE workflow_executions we
+		SET status = 'PENDING', resume_handle = 'timeout', next_run_at = NOW()
+		FROM expired e
+		WHERE we.id = e.execution_id AND we.current_node_id = e.node_id AND we.status = 'WAITING_FOR_EVENT'
+	`)
+	if err != nil {
+		Logger.Error("Failed to expire event waits:", err)
+	}
+}
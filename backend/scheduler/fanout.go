@@ -0,0 +1,113 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/wesuuu/helpnow/backend/db"
+)
+
+const (
+	// People expanded per statement when fanning out a trigger
+	fanOutBatchSize = 500
+	// Members of this audience are never enrolled by a fan-out
+	doNotCallAudience = "Do not call"
+)
+
+// fanOut starts one execution per person in a set of audiences. Each run has a
+// key; the unique index on (workflow_id, subject_id, fanout_key) makes a run safe
+// to repeat after a crash without enrolling anyone twice.
+//
+// Audiences are the only people source for now. Dynamic segments can be added as
+// another EXISTS clause in the batch query.
+type fanOut struct {
+	WorkflowID  int
+	OrgID       int
+	NodeID      string // Node the executions start at
+	AudienceIDs []int
+	Context     string // Base context JSON; each execution adds the person's fields
+	Key         string
+}
+
+// fanOutKey identifies one firing of a scheduled trigger
+func fanOutKey(triggerID int, firedAt time.Time) string {
+	return fmt.Sprintf("trigger:%d:%d", triggerID, firedAt.Unix())
+}
+
+// uniqueIDs returns ids sorted with duplicates and zeros removed
+func uniqueIDs(ids []int) []int {
+	seen := map[int]bool{}
+	out := []int{}
+	for _, id := range ids {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// Execute pages through the people in the audiences by ID and inserts their
+// executions a batch at a time, so large audiences are never loaded into memory.
+// People in several of the audiences are enrolled once. It returns how many
+// executions were created.
+func (f fanOut) Execute(ctx context.Context) (int, error) {
+	// People the workflow's entry rule or the message cap keep out are skipped
+	policy, err := loadEntryPolicy(db.GetDB(), f.WorkflowID)
+	if err != nil {
+		return 0, err
+	}
+	admitted, admissionArgs := policy.admission("p.id", 10, f.WorkflowID)
+
+	created := 0
+	cursor := 0
+	for {
+		var last, scanned, inserted int
+		args := append([]interface{}{f.OrgID, cursor, pq.Array(f.AudienceIDs), doNotCallAudience, fanOutBatchSize,
+			f.WorkflowID, f.NodeID, f.Context, f.Key}, admissionArgs...)
+		err := db.GetDB().QueryRowContext(ctx, `
+			WITH batch AS (
+				SELECT p.id, p.first_name, p.last_name, p.email, p.age, p.gender, p.location, p.score, p.meta
+				FROM people p
+				WHERE p.organization_id = $1 AND p.id > $2
+				AND EXISTS (
+					SELECT 1 FROM audience_memberships am
+					WHERE am.person_id = p.id AND am.audience_id = ANY($3)
+				)
+				AND NOT EXISTS (
+					SELECT 1 FROM audience_memberships am
+					JOIN audiences a ON a.id = am.audience_id
+					WHERE am.person_id = p.id AND a.organization_id = $1 AND a.name = $4
+				)
+				AND `+admitted+`
+				ORDER BY p.id
+				LIMIT $5
+			), ins AS (
+				INSERT INTO workflow_executions (workflow_id, version_id, subject_id, current_node_id, status, next_run_at, created_at, context, fanout_key, priority)
+				SELECT $6, (SELECT published_version_id FROM workflows WHERE id = $6), b.id, $7, 'PENDING', NOW(), NOW(),
+					($8::jsonb || jsonb_build_object('person', jsonb_build_object(
+						'id', b.id, 'first_name', b.first_name, 'last_name', b.last_name, 'email', b.email,
+						'age', b.age, 'gender', b.gender, 'location', b.location, 'score', b.score,
+						'meta', COALESCE(b.meta, '{}'::jsonb)
+					)))::text,
+					$9, (SELECT COALESCE(priority, 0) FROM workflows WHERE id = $6)
+				FROM batch b
+				ON CONFLICT (workflow_id, subject_id, fanout_key) WHERE fanout_key IS NOT NULL DO NOTHING
+				RETURNING 1
+			)
+			SELECT COALESCE(MAX(id), 0), COUNT(*), (SELECT COUNT(*) FROM ins) FROM batch
+		`, args...).Scan(&last, &scanned, &inserted)
+		if err != nil {
+			return created, err
+		}
+		created += inserted
+		if scanned < fanOutBatchSize {
+			return created, nil
+		}
+		cursor = last
+	}
+}
@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wesuuu/helpnow/backend/db/dbtest"
+)
+
+func TestUniqueIDs(t *testing.T) {
+	got := uniqueIDs([]int{0, 7, 3, 7, 1, 3})
+	if want := []int{1, 3, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueIDs = %v, want %v", got, want)
+	}
+	if got := uniqueIDs([]int{0}); len(got) != 0 {
+		t.Errorf("uniqueIDs([0]) = %v, want empty", got)
+	}
+}
+
+func TestFanOutKey(t *testing.T) {
+	firedAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	if got, want := fanOutKey(12, firedAt), "trigger:12:1714554000"; got != want {
+		t.Errorf("fanOutKey = %q, want %q", got, want)
+	}
+	// The same firing gives the same key whatever the zone it was read in
+	if fanOutKey(12, firedAt) != fanOutKey(12, firedAt.In(time.FixedZone("x", 3600))) {
+		t.Error("fanOutKey depends on the time zone")
+	}
+}
+
+func TestFanOutExecuteBatches(t *testing.T) {
+	fake := dbtest.Use(t,
+		dbtest.Result{Match: "daily_message_cap", Rows: [][]driver.Value{{nil, int64(0)}}},
+		// A full batch, 20 of whom were enrolled by an earlier run of the same
+		// firing, then a short one that ends the fan-out
+		dbtest.Result{Match: "WITH batch AS", Once: true, Rows: [][]driver.Value{{int64(900), int64(fanOutBatchSize), int64(fanOutBatchSize - 20)}}},
+		dbtest.Result{Match: "WITH batch AS", Once: true, Rows: [][]driver.Value{{int64(1300), int64(120), int64(120)}}},
+	)
+
+	f := fanOut{WorkflowID: 4, OrgID: 1, NodeID: "trigger-1", AudienceIDs: []int{3, 5}, Context: "{}", Key: "trigger:7:1714554000"}
+	created, err := f.Execute(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != fanOutBatchSize-20+120 {
+		t.Errorf("Expected %d executions, got %d", fanOutBatchSize-20+120, created)
+	}
+
+	batches := fake.AssertOrder(t, "WITH batch AS")
+	second := fake.Index("WITH batch AS", fake.Index("WITH batch AS", 0)+1)
+	if second < 0 || fake.Index("WITH batch AS", second+1) >= 0 {
+		t.Fatalf("Expected exactly two batches, ran:\n%s", fake)
+	}
+	if cursor := batches[0].Args[1]; cursor != int64(0) {
+		t.Errorf("Expected the first batch to start at the beginning, got cursor %v", cursor)
+	}
+	if cursor := fake.Statements[second].Args[1]; cursor != int64(900) {
+		t.Errorf("Expected the second batch to start after person 900, got cursor %v", cursor)
+	}
+
+	query, args := batches[0].Query, batches[0].Args
+	if args[2] != "{3,5}" || args[8] != f.Key {
+		t.Errorf("Unexpected audiences or key in %v", args)
+	}
+	// Members of the Do not call audience are left out
+	if args[3] != doNotCallAudience || !strings.Contains(query, "NOT EXISTS") || !strings.Contains(query, "a.name = $4") {
+		t.Errorf("Expected the %q audience to be excluded: %v", doNotCallAudience, args)
+	}
+	// People are selected once however many audiences they are in, and a run
+	// repeated after a crash skips the people it already enrolled
+	if !strings.Contains(query, "WHERE am.person_id = p.id AND am.audience_id = ANY($3)") || !strings.Contains(query, "ON CONFLICT (workflow_id, subject_id, fanout_key)") {
+		t.Errorf("Expected people to be deduplicated: %s", query)
+	}
+}
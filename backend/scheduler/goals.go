@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// Goals
+//
+// A workflow's goal removes a person from every in-flight execution of it as soon
+// as they reach it: their executions, branches included, end EXITED, pending
+// approvals and event waits are dropped, and each top-level execution is recorded
+// in workflow_conversions. A worker mid-step loses its lease and its writes are
+// discarded.
+
+// ReachGoalEvent exits a person from the workflows whose goal is this event. It
+// returns how many executions converted.
+func ReachGoalEvent(personID int, eventName string, properties map[string]interface{}) (int, error) {
+	return reachGoal(personID, workflows.GoalEvent, "Goal reached: "+eventName, func(g *workflows.Goal) bool {
+		return g.MatchesEvent(eventName, properties)
+	})
+}
+
+// ReachGoalAudience exits a person from the workflows whose goal is joining this
+// audience. It returns how many executions converted.
+func ReachGoalAudience(personID, audienceID int) (int, error) {
+	return reachGoal(personID, workflows.GoalAudience, fmt.Sprintf("Goal reached: joined audience %d", audienceID), func(g *workflows.Goal) bool {
+		return g.MatchesAudience(audienceID)
+	})
+}
+
+func reachGoal(personID int, goalType, reason string, matches func(*workflows.Goal) bool) (int, error) {
+	// Only workflows the person is still in are worth checking
+	rows, err := db.GetDB().Query(`
+		SELECT w.id, w.goal::text
+		FROM workflows w
+		WHERE w.goal->>'type' = $2
+		AND EXISTS (
+			SELECT 1 FROM workflow_executions we
+			WHERE we.workflow_id = w.id AND we.subject_id = $1 AND we.status = ANY($3)
+		)
+	`, personID, goalType, pq.Array(models.ActiveStatuses))
+	if err != nil {
+		return 0, err
+	}
+	reached := []int{}
+	for rows.Next() {
+		var workflowID int
+		var goalStr string
+		if err := rows.Scan(&workflowID, &goalStr); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		var goal workflows.Goal
+		if json.Unmarshal([]byte(goalStr), &goal) == nil && matches(&goal) {
+			reached = append(reached, workflowID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(reached) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	converted := 0
+	for _, workflowID := range reached {
+		n, err := exitWorkflow(tx, workflowID, personID, goalType, reason)
+		if err != nil {
+			return 0, err
+		}
+		converted += n
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return converted, nil
+}
+
+// exitWorkflow ends a person's active executions of a workflow, records a
+// conversion for each top-level one and resumes any parent waiting on them
+func exitWorkflow(tx *sql.Tx, workflowID, personID int, goalType, reason string) (int, error) {
+	rows, err := tx.Query(`
+		WITH exited AS (
+			UPDATE workflow_executions
+			SET status = $3, result = $4, finished_at = NOW(), resume_handle = NULL, locked_by = NULL, lease_expires_at = NULL
+			WHERE workflow_id = $1 AND subject_id = $2 AND status = ANY($5)
+			RETURNING id, fork_id, current_node_id
+		), converted AS (
+			INSERT INTO workflow_conversions (workflow_id, execution_id, subject_id, node_id, goal_type)
+			SELECT $1, id, $2, current_node_id, $6 FROM exited WHERE fork_id IS NULL
+			ON CONFLICT (execution_id) DO NOTHING
+			RETURNING execution_id
+		)
+		SELECT id, id IN (SELECT execution_id FROM converted) FROM exited
+	`, workflowID, personID, string(models.StatusExited), reason, pq.Array(models.ActiveStatuses), goalType)
+	if err != nil {
+		return 0, err
+	}
+	exited, ids := []int{}, []int{}
+	for rows.Next() {
+		var id int
+		var converted bool
+		if err := rows.Scan(&id, &converted); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		exited = append(exited, id)
+		if converted {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if err := CancelWaits(tx, exited); err != nil {
+		return 0, err
+	}
+
+	// A workflow that started this one with Run Workflow carries on
+	for _, id := range ids {
+		if err := ResumeParent(tx, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
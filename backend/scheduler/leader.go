@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/wesuuu/helpnow/backend/db"
+)
+
+// schedulerLockKey is the Postgres advisory lock key held by the scheduler leader
+const schedulerLockKey int64 = 0x68656c706e6f77 // "helpnow"
+
+// LeaderElector elects a single replica to run singleton jobs (campaigns, scheduled
+// triggers) using a session-level Postgres advisory lock. The lock lives on a
+// dedicated connection, so it is released automatically if the process dies.
+type LeaderElector struct {
+	key  int64
+	conn *sql.Conn
+	mu   sync.Mutex
+}
+
+func NewLeaderElector(key int64) *LeaderElector {
+	return &LeaderElector{key: key}
+}
+
+// IsLeader reports whether this replica holds the lock, trying to acquire it if not
+func (l *LeaderElector) IsLeader(ctx context.Context) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true
+		}
+		Logger.Warn("Lost scheduler leadership (lock connection closed)")
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	conn, err := db.GetDB().Conn(ctx)
+	if err != nil {
+		Logger.Error("Leader election: failed to get connection:", err)
+		return false
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired); err != nil {
+		Logger.Error("Leader election: failed to try lock:", err)
+		conn.Close()
+		return false
+	}
+	if !acquired {
+		conn.Close()
+		return false
+	}
+
+	Logger.Infof("Acquired scheduler leadership (worker %s)", workerID)
+	l.conn = conn
+	return true
+}
+
+// Resign releases the lock so another replica can take over
+func (l *LeaderElector) Resign() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return
+	}
+	l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key)
+	l.conn.Close()
+	l.conn = nil
+}
@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// Loops
+//
+// A LOOP node runs the path on its "item" handle once per item of a list in the
+// context. Each iteration is a branch (see branches.go) whose context holds the
+// item under "item" and its position under "loop", so templates can use {{item}}.
+// The looping execution waits in WAITING_FOR_BRANCHES; once every iteration has
+// ended it continues on the "done" handle, with the iterations' results under the
+// loop node's ID and their step results appended to its own.
+
+// collectLoopItems reads the list a LOOP node iterates over. An empty list skips
+// straight to the "done" handle.
+func collectLoopItems(node *workflows.Node, ctxData map[string]interface{}) nodeOutcome {
+	loop, err := workflows.ParseLoopNode(node.Properties)
+	if err != nil {
+		return nodeOutcome{Status: "failed", Output: "Invalid loop node", Err: err}
+	}
+	items, truncated, err := loop.Collect(ctxData)
+	if err != nil {
+		return nodeOutcome{Status: "failed", Output: "Nothing to loop over", Err: err}
+	}
+
+	data := map[string]interface{}{"count": len(items), "truncated": truncated}
+	if len(items) == 0 {
+		data["results"] = []interface{}{}
+		return nodeOutcome{Status: "success", Output: "No items to loop over", Handle: workflows.HandleDone, Data: data}
+	}
+	output := fmt.Sprintf("Looping over %d items", len(items))
+	if truncated {
+		output += fmt.Sprintf(" (capped at max_iterations %d)", loop.Limit())
+	}
+	return nodeOutcome{Status: "success", Output: output, Handle: workflows.HandleItem, Data: data, Items: items}
+}
+
+// loopContext is the context an iteration runs with
+func loopContext(ctxData map[string]interface{}, nodeID string, index, count int, item interface{}) map[string]interface{} {
+	iteration := make(map[string]interface{}, len(ctxData)+2)
+	for k, v := range ctxData {
+		iteration[k] = v
+	}
+	iteration[workflows.LoopItemKey] = item
+	iteration[workflows.LoopStateKey] = map[string]interface{}{"node_id": nodeID, "index": index, "count": count}
+	return iteration
+}
+
+// startLoop starts an iteration per item at each target of the loop's "item"
+// handle and parks the execution until they have all ended
+func startLoop(exec ScheduledExecution, graph workflows.Graph, nodeID string, targets []string, items []interface{}, ctxData map[string]interface{}) error {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, item := range items {
+		label := branchLabel(exec.Branch.String, fmt.Sprintf("%s[%d]", nodeID, i))
+		iteration := loopContext(ctxData, nodeID, i, len(items), item)
+		for _, target := range targets {
+			targetLabel := label
+			if len(targets) > 1 {
+				targetLabel = branchLabel(label, target)
+			}
+			if err := startBranch(tx, exec, graph, target, targetLabel, iteration); err != nil {
+				return err
+			}
+		}
+	}
+	if err := parkForBranches(tx, exec); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// loopIteration summarises an ended iteration: its item, status and the
+// structured output of each node it ran
+func loopIteration(status string, ctxData map[string]interface{}, steps []StepResult) map[string]interface{} {
+	iteration := map[string]interface{}{
+		"item":   ctxData[workflows.LoopItemKey],
+		"status": status,
+	}
+	if state, ok := ctxData[workflows.LoopStateKey].(map[string]interface{}); ok {
+		iteration["index"] = state["index"]
+	}
+	outputs := map[string]interface{}{}
+	for _, step := range steps {
+		if data, ok := ctxData[step.NodeID]; ok {
+			outputs[step.NodeID] = data
+		}
+	}
+	iteration["outputs"] = outputs
+	return iteration
+}
+
+// loopSummary adds the iteration results and their tally to what the LOOP node
+// stored in the context when it started
+func loopSummary(existing interface{}, iterations []map[string]interface{}) map[string]interface{} {
+	summary, _ := existing.(map[string]interface{})
+	if summary == nil {
+		summary = map[string]interface{}{}
+	}
+	completed, failed := 0, 0
+	for _, iteration := range iterations {
+		switch iteration["status"] {
+		case string(models.StatusCompleted):
+			completed++
+		case string(models.StatusFailed), string(models.StatusDeadLetter):
+			failed++
+		}
+	}
+	summary["results"] = iterations
+	summary["completed"] = completed
+	summary["failed"] = failed
+	return summary
+}
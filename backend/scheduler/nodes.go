@@ -0,0 +1,415 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// nodeOutcome is what running a single node produced
+type nodeOutcome struct {
+	Status string // "success", "failed", or "deferred" to run the node again later
+	Output string
+	Handle string                 // Outgoing handle to follow
+	Data   map[string]interface{} // Structured output, merged into the context under the node ID
+	Err    error
+	Park   *parkRequest  // Set when the execution must wait instead of advancing
+	Items  []interface{} // Set by a LOOP node: run the body once per item
+}
+
+// parkRequest moves an execution out of PENDING until something resumes it
+type parkRequest struct {
+	Status    string           // e.g. WAITING_FOR_HUMAN
+	WakeAt    *time.Time       // When the wait times out, if ever
+	Handle    string           // Handle to follow on waking, for a plain pause in PENDING
+	EventWait *eventWait       // Registered along with the park, for WAIT_FOR_EVENT
+	Approval  *approvalRequest // Opened along with the park, for APPROVAL
+}
+
+// executeNode runs the logic of a single node against the execution context
+func executeNode(ctx context.Context, exec ScheduledExecution, node *workflows.Node, ctxData map[string]interface{}) nodeOutcome {
+	ctx = workflows.WithExecutionInfo(ctx, workflows.ExecutionInfo{
+		ExecutionID:    exec.ID,
+		WorkflowID:     exec.WorkflowID,
+		OrganizationID: int(exec.OrgID.Int64),
+		NodeID:         node.ID,
+		SubjectID:      int(exec.SubjectID.Int64),
+		Depth:          exec.Depth,
+		DryRun:         exec.DryRun,
+	})
+
+	switch models.NodeType(node.Type) {
+	case models.NodeTypeTrigger:
+		return nodeOutcome{Status: "success", Output: "Triggered", Handle: "default"}
+
+	case models.NodeTypeAction:
+		return executeAction(ctx, exec, node, ctxData)
+
+	case models.NodeTypeCondition:
+		return executeCondition(ctx, exec, node, ctxData)
+
+	case models.NodeTypeApproval:
+		return requestApproval(ctx, exec, node)
+
+	case models.NodeTypeWaitForEvent:
+		return waitForEvent(ctx, exec, node)
+
+	case models.NodeTypeSplit:
+		return assignVariant(ctx, exec, node)
+
+	case models.NodeTypeLoop:
+		return collectLoopItems(node, ctxData)
+
+	case models.NodeTypeDelay:
+		return scheduleDelay(ctx, exec, node, ctxData)
+
+	case models.NodeTypeJoin:
+		// Reached without a fork to merge (branches arrive through the worker)
+		return nodeOutcome{Status: "success", Output: "Joined", Handle: "default"}
+	}
+
+	return nodeOutcome{Status: "failed", Output: "Unknown node type: " + node.Type, Err: fmt.Errorf("unknown node type %q", node.Type)}
+}
+
+func executeAction(ctx context.Context, exec ScheduledExecution, node *workflows.Node, ctxData map[string]interface{}) (outcome nodeOutcome) {
+	actionType, _ := node.Properties["action"].(string)
+
+	// Look up action template
+	actionTemplate, ok := workflows.GetAction(actionType)
+	if !ok {
+		Logger.Warnf("[Worker] Unknown action type: %s", actionType)
+		return nodeOutcome{Status: "failed", Output: "Unknown action type", Err: fmt.Errorf("unknown action type %q", actionType)}
+	}
+
+	// Create a new instance of the action (to avoid shared state)
+	action := reflect.New(reflect.TypeOf(actionTemplate).Elem()).Interface().(workflows.Action)
+
+	properties, err := resolveProperties(ctx, exec, node, ctxData)
+	if err != nil {
+		return nodeOutcome{Status: "failed", Output: "Template error: " + err.Error(), Err: err}
+	}
+
+	// Unmarshal node properties into the action struct
+	if propBytes, err := json.Marshal(properties); err == nil {
+		if err := json.Unmarshal(propBytes, action); err != nil {
+			Logger.Warnf("[Worker] Failed to unmarshal action properties: %v", err)
+		}
+	}
+
+	// Messages wait for the send window, in the recipient's local time
+	if _, ok := action.(workflows.Messenger); ok && !exec.DryRun {
+		if deferred, ok := deferToSendWindow(ctx, exec); ok {
+			return deferred
+		}
+	}
+
+	// Messages to a person count towards their daily cap; over it the send is skipped
+	if messenger, ok := action.(workflows.Messenger); ok && !exec.DryRun && exec.SubjectID.Valid {
+		deliveryID, err := reserveMessage(ctx, exec, node.ID, messenger.Channel())
+		if err != nil {
+			return nodeOutcome{Status: "failed", Output: "Failed to check message cap", Handle: "default", Err: fmt.Errorf("failed to check message cap: %w", err)}
+		}
+		if deliveryID == 0 {
+			return nodeOutcome{
+				Status: "success",
+				Output: "Skipped: daily message cap reached",
+				Handle: "default",
+				Data:   map[string]interface{}{"skipped": true, "reason": "daily_message_cap"},
+			}
+		}
+		defer func() {
+			if outcome.Status == "failed" {
+				releaseMessage(deliveryID)
+			}
+		}()
+	}
+
+	// Execute with only context data
+	stepCtx, cancel := context.WithTimeout(ctx, stepTimeout)
+	defer cancel()
+	var out string
+	var data map[string]interface{}
+	if simulator, ok := action.(workflows.Simulator); ok && exec.DryRun {
+		out, data, err = simulator.Simulate(stepCtx, ctxData)
+	} else if structured, ok := action.(workflows.StructuredAction); ok {
+		out, data, err = structured.ExecuteStructured(stepCtx, ctxData)
+	} else {
+		out, err = action.Execute(stepCtx, ctxData)
+	}
+	if err != nil {
+		Logger.Warnf("[Worker] Action %s failed: %v", actionType, err)
+		return nodeOutcome{Status: "failed", Output: out, Handle: "default", Err: err}
+	}
+
+	// ALWAYS follow default for Action
+	outcome = nodeOutcome{Status: "success", Output: out, Handle: "default", Data: data}
+	if pauser, ok := action.(workflows.Pauser); ok {
+		wakeAt := pauser.PauseUntil(time.Now())
+		return pauseUntil(wakeAt, out+" until "+wakeAt.UTC().Format(time.RFC3339), map[string]interface{}{"wake_at": wakeAt.UTC().Format(time.RFC3339)})
+	}
+	if awaiter, ok := action.(workflows.Awaiter); ok && !exec.DryRun && awaiter.AwaitResult() {
+		outcome.Park = &parkRequest{Status: string(models.StatusWaitingForChild)}
+	}
+	return outcome
+}
+
+func executeCondition(ctx context.Context, exec ScheduledExecution, node *workflows.Node, ctxData map[string]interface{}) nodeOutcome {
+	// Logic Support
+	logicType := "Condition" // Hardcoded for now, or property?
+
+	logicTemplate, ok := workflows.GetLogic(logicType)
+	if !ok {
+		// Fallback (shouldn't happen if registered)
+		return nodeOutcome{Status: "failed", Output: "Logic type not found", Err: fmt.Errorf("logic type %q not registered", logicType)}
+	}
+
+	// Create a new instance of the logic
+	logic := reflect.New(reflect.TypeOf(logicTemplate).Elem()).Interface().(workflows.Logic)
+
+	properties, err := resolveProperties(ctx, exec, node, ctxData)
+	if err != nil {
+		return nodeOutcome{Status: "failed", Output: "Template error: " + err.Error(), Err: err}
+	}
+
+	// Unmarshal node properties into the logic struct
+	if propBytes, err := json.Marshal(properties); err == nil {
+		if err := json.Unmarshal(propBytes, logic); err != nil {
+			Logger.Warnf("[Worker] Failed to unmarshal logic properties: %v", err)
+		}
+	}
+
+	// Evaluate against the context and, like templates, the subject person
+	person, err := subjectPerson(ctx, exec)
+	if err != nil {
+		return nodeOutcome{Status: "failed", Output: "Failed to load the person", Err: err}
+	}
+	stepCtx, cancel := context.WithTimeout(ctx, stepTimeout)
+	defer cancel()
+	res, out, err := logic.Evaluate(stepCtx, conditionVars(ctxData, person))
+	if err != nil {
+		Logger.Warnf("[Worker] Logic failed: %v", err)
+		return nodeOutcome{Status: "failed", Output: out, Err: err}
+	}
+
+	handle := "false"
+	if res {
+		handle = "true"
+	}
+	return nodeOutcome{Status: "success", Output: out, Handle: handle}
+}
+
+// resolveProperties fills {{placeholders}} in the node's properties from the
+// execution context, earlier step results and the subject person
+func resolveProperties(ctx context.Context, exec ScheduledExecution, node *workflows.Node, ctxData map[string]interface{}) (map[string]interface{}, error) {
+	if !workflows.HasTemplate(node.Properties) {
+		return node.Properties, nil
+	}
+
+	var steps []StepResult
+	if exec.ResultJSON.Valid && exec.ResultJSON.String != "" {
+		json.Unmarshal([]byte(exec.ResultJSON.String), &steps)
+	}
+
+	person, err := subjectPerson(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+	return workflows.InterpolateProperties(node.Properties, workflows.TemplateVars(ctxData, steps, person))
+}
+
+// subjectPerson loads the person the execution runs for, or nil if it has none
+func subjectPerson(ctx context.Context, exec ScheduledExecution) (map[string]interface{}, error) {
+	if !exec.SubjectID.Valid {
+		return nil, nil
+	}
+	person, err := loadPerson(ctx, int(exec.SubjectID.Int64))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load person %d: %w", exec.SubjectID.Int64, err)
+	}
+	return person, nil
+}
+
+// conditionVars are the variables a condition sees: the context, with person
+// set to the subject's current fields when the execution has one
+func conditionVars(ctxData map[string]interface{}, person map[string]interface{}) map[string]interface{} {
+	if person == nil {
+		return ctxData
+	}
+	vars := make(map[string]interface{}, len(ctxData)+1)
+	for k, v := range ctxData {
+		vars[k] = v
+	}
+	vars["person"] = person
+	return vars
+}
+
+// loadPerson returns a person's fields as template variables
+func loadPerson(ctx context.Context, personID int) (map[string]interface{}, error) {
+	var raw string
+	err := db.GetDB().QueryRowContext(ctx, `
+		SELECT json_build_object(
+			'id', id, 'first_name', first_name, 'last_name', last_name, 'email', email,
+			'age', age, 'gender', gender, 'location', location, 'score', score, 'timezone', timezone,
+			'meta', COALESCE(meta, '{}'::jsonb)
+		)::text
+		FROM people WHERE id = $1
+	`, personID).Scan(&raw)
+	if err != nil {
+		return nil, err
+	}
+
+	person := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(raw), &person); err != nil {
+		return nil, err
+	}
+	return person, nil
+}
+
+// approvalRequest is the review task an APPROVAL node opens. It is inserted as
+// the execution parks, so a decision can never find the execution still running.
+type approvalRequest struct {
+	WorkflowID int
+	NodeID     string
+	Approval   *workflows.ApprovalNode
+	DueAt      *time.Time
+}
+
+// requestApproval parks the execution in WAITING_FOR_HUMAN with a review task
+func requestApproval(ctx context.Context, exec ScheduledExecution, node *workflows.Node) nodeOutcome {
+	approval, err := workflows.ParseApprovalNode(node.Properties)
+	if err != nil {
+		return nodeOutcome{Status: "failed", Output: "Invalid approval node", Err: err}
+	}
+	if exec.DryRun {
+		return nodeOutcome{
+			Status: "success",
+			Output: "Would wait for approval",
+			Park:   &parkRequest{Status: string(models.StatusWaitingForHuman)},
+		}
+	}
+
+	var dueAt *time.Time
+	if timeout := approval.Timeout(); timeout > 0 {
+		t := time.Now().Add(timeout)
+		dueAt = &t
+	}
+
+	output := "Waiting for approval"
+	if dueAt != nil {
+		output += " (times out " + dueAt.Format(time.RFC3339) + ")"
+	}
+	return nodeOutcome{
+		Status: "success",
+		Output: output,
+		Park: &parkRequest{
+			Status:   string(models.StatusWaitingForHuman),
+			WakeAt:   dueAt,
+			Approval: &approvalRequest{WorkflowID: exec.WorkflowID, NodeID: node.ID, Approval: approval, DueAt: dueAt},
+		},
+	}
+}
+
+// openApproval inserts the review task of a parking execution. It runs in the
+// transaction that parks the execution.
+func openApproval(tx *sql.Tx, executionID int, req *approvalRequest) error {
+	approval := req.Approval
+	_, err := tx.Exec(`
+		INSERT INTO workflow_approvals (execution_id, workflow_id, node_id, assignee_user_id, assignee_team_id, instructions, allow_context_edits, status, due_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, 'PENDING', $8)
+	`, executionID, req.WorkflowID, req.NodeID, approval.AssigneeUserID, approval.AssigneeTeamID, approval.Instructions, approval.AllowContextEdits, req.DueAt)
+	if err != nil {
+		return fmt.Errorf("failed to create approval: %w", err)
+	}
+	return nil
+}
+
+// findStartNode returns the first trigger node, or the first node of a graph
+// without triggers
+func findStartNode(graph workflows.Graph) string {
+	for _, n := range graph.Nodes {
+		if n.Type == string(models.NodeTypeTrigger) {
+			return n.ID
+		}
+	}
+	if len(graph.Nodes) > 0 {
+		return graph.Nodes[0].ID
+	}
+	return ""
+}
+
+func findNode(graph workflows.Graph, nodeID string) *workflows.Node {
+	for i, n := range graph.Nodes {
+		if n.ID == nodeID {
+			return &graph.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// findNextNodes returns the nodes reached by leaving nodeID through handle, or
+// none at the end of the flow. Nodes without branches follow every outgoing edge.
+// More than one target means the flow splits into parallel branches.
+func findNextNodes(graph workflows.Graph, nodeID, handle string) []string {
+	targets := []string{}
+	seen := map[string]bool{}
+	add := func(target string) {
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+
+	// First try specific handle
+	for _, edge := range graph.Edges {
+		if edge.Source == nodeID && edge.Handle == handle {
+			add(edge.Target)
+		}
+	}
+
+	// Fallback to default if no specific handle edge found (for actions)
+	if len(targets) == 0 && handle == "default" {
+		for _, edge := range graph.Edges {
+			if edge.Source == nodeID {
+				add(edge.Target)
+			}
+		}
+	}
+	return targets
+}
+
+// nodeDelay is how long to wait before running a node, from its delay_days and
+// delay_hours properties. Graphs that predate DELAY nodes still rely on it.
+func nodeDelay(node *workflows.Node) time.Duration {
+	getFloat := func(key string) float64 {
+		if val, ok := node.Properties[key]; ok {
+			switch v := val.(type) {
+			case float64:
+				return v
+			case int:
+				return float64(v)
+			case string:
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					return f
+				}
+			}
+		}
+		return 0
+	}
+
+	delay := time.Duration(0)
+	if days := getFloat("delay_days"); days > 0 {
+		delay += time.Duration(days) * 24 * time.Hour
+	}
+	if hours := getFloat("delay_hours"); hours > 0 {
+		delay += time.Duration(hours) * time.Hour
+	}
+	return delay
+}
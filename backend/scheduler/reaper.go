@@ -0,0 +1,260 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// Reaper
+//
+// Nothing else ends an execution that is parked forever or sits at a node that no
+// longer exists, so the scheduler leader runs a reaper every reapInterval:
+//   - executions running longer than their workflow's max_duration_hours, or
+//     DefaultMaxDurationHours when it is NULL, end TIMED_OUT (0 is no limit);
+//   - executions whose workflow or current node is gone end FAILED.
+// A reaped execution ends like a cancelled one: its branches go with it, pending
+// approvals and event waits are dropped, and a parent waiting on it carries on.
+// The reason is recorded in its result.
+
+// reapInterval is how often the leader looks for executions to reap
+const reapInterval = 10 * time.Minute
+
+// reapBatchSize caps the executions timed out in one pass
+const reapBatchSize = 500
+
+// DefaultMaxDurationHours is the longest an execution of a workflow without its
+// own max duration may run, from EXECUTION_MAX_AGE_DAYS. Unset, it is 0 and such
+// executions may run for ever, so long-running ones are never reaped by surprise.
+var DefaultMaxDurationHours = maxDurationFromEnv()
+
+func maxDurationFromEnv() int {
+	days, err := strconv.Atoi(os.Getenv("EXECUTION_MAX_AGE_DAYS"))
+	if err != nil || days < 0 {
+		return 0
+	}
+	return days * 24
+}
+
+// lastReap is when reapExecutions last ran on this replica
+var lastReap time.Time
+
+// reapExecutions times out executions over their max duration and fails those
+// that can no longer run. It does nothing if it ran less than reapInterval ago.
+func reapExecutions() {
+	if time.Since(lastReap) < reapInterval {
+		return
+	}
+	lastReap = time.Now()
+
+	timedOut, err := reapTimedOut()
+	if err != nil {
+		Logger.Error("Reaper: failed to time out executions:", err)
+	}
+	orphaned, err := reapOrphaned()
+	if err != nil {
+		Logger.Error("Reaper: failed to fail orphaned executions:", err)
+	}
+	if timedOut+orphaned > 0 {
+		Logger.Infof("Reaper: timed out %d executions and failed %d orphaned ones", timedOut, orphaned)
+	}
+}
+
+// reapTimedOut ends the top-level executions running longer than allowed.
+// Branches are ended with the execution that forked them.
+func reapTimedOut() (int, error) {
+	rows, err := db.GetDB().Query(`
+		SELECT we.id, COALESCE(w.max_duration_hours, $2)
+		FROM workflow_executions we
+		JOIN workflows w ON w.id = we.workflow_id
+		WHERE we.status = ANY($1) AND we.fork_id IS NULL
+		AND COALESCE(w.max_duration_hours, $2) > 0
+		AND we.created_at < NOW() - make_interval(hours => COALESCE(w.max_duration_hours, $2))
+		ORDER BY we.created_at
+		LIMIT $3
+	`, pq.Array(models.ActiveStatuses), DefaultMaxDurationHours, reapBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	type stale struct{ id, hours int }
+	found := []stale{}
+	for rows.Next() {
+		var s stale
+		if err := rows.Scan(&s.id, &s.hours); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		found = append(found, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	reaped := 0
+	for _, s := range found {
+		reason := fmt.Sprintf("Timed out: ran longer than the max duration of %d hours", s.hours)
+		ok, err := reapExecution(s.id, string(models.StatusTimedOut), reason)
+		if err != nil {
+			return reaped, err
+		}
+		if ok {
+			reaped++
+		}
+	}
+	return reaped, nil
+}
+
+// reapOrphaned fails executions whose workflow was deleted or whose current node
+// is not in the graph they run
+func reapOrphaned() (int, error) {
+	// One row per graph in use, with the nodes its executions are at
+	rows, err := db.GetDB().Query(`
+		SELECT we.workflow_id, we.version_id, w.id IS NOT NULL, COALESCE(v.steps, pv.steps, w.steps, ''), array_agg(DISTINCT we.current_node_id)
+		FROM workflow_executions we
+		LEFT JOIN workflows w ON w.id = we.workflow_id
+		LEFT JOIN workflow_versions v ON v.id = we.version_id
+		LEFT JOIN workflow_versions pv ON pv.id = w.published_version_id
+		WHERE we.status = ANY($1) AND we.current_node_id IS NOT NULL
+		AND (we.lease_expires_at IS NULL OR we.lease_expires_at < NOW())
+		GROUP BY we.workflow_id, we.version_id, w.id, v.steps, pv.steps, w.steps
+	`, pq.Array(models.ActiveStatuses))
+	if err != nil {
+		return 0, err
+	}
+	type orphans struct {
+		workflowID, versionID sql.NullInt64
+		nodeIDs               []string
+		reason                string
+	}
+	found := []orphans{}
+	for rows.Next() {
+		var o orphans
+		var exists bool
+		var steps string
+		var nodeIDs []string
+		if err := rows.Scan(&o.workflowID, &o.versionID, &exists, &steps, pq.Array(&nodeIDs)); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !exists {
+			o.nodeIDs, o.reason = nodeIDs, "Workflow no longer exists"
+			found = append(found, o)
+			continue
+		}
+		if missing := missingNodes(steps, nodeIDs); len(missing) > 0 {
+			o.nodeIDs, o.reason = missing, "Current node no longer exists in the workflow"
+			found = append(found, o)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	reaped := 0
+	for _, o := range found {
+		ids, err := orphanIDs(o.workflowID, o.versionID, o.nodeIDs)
+		if err != nil {
+			return reaped, err
+		}
+		for _, id := range ids {
+			ok, err := reapExecution(id, string(models.StatusFailed), o.reason)
+			if err != nil {
+				return reaped, err
+			}
+			if ok {
+				reaped++
+			}
+		}
+	}
+	return reaped, nil
+}
+
+// missingNodes returns the node IDs that are not in a graph. Legacy steps that
+// are not a graph have no nodes to check.
+func missingNodes(steps string, nodeIDs []string) []string {
+	var graph workflows.Graph
+	if err := json.Unmarshal([]byte(steps), &graph); err != nil || len(graph.Nodes) == 0 {
+		return nil
+	}
+	missing := []string{}
+	for _, id := range nodeIDs {
+		if findNode(graph, id) == nil {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// orphanIDs lists the active, unleased executions of a workflow version at any
+// of the given nodes
+func orphanIDs(workflowID, versionID sql.NullInt64, nodeIDs []string) ([]int, error) {
+	rows, err := db.GetDB().Query(`
+		SELECT id FROM workflow_executions
+		WHERE workflow_id IS NOT DISTINCT FROM $1 AND version_id IS NOT DISTINCT FROM $2
+		AND current_node_id = ANY($3) AND status = ANY($4)
+		AND (lease_expires_at IS NULL OR lease_expires_at < NOW())
+	`, workflowID, versionID, pq.Array(nodeIDs), pq.Array(models.ActiveStatuses))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// reapExecution ends an active execution with status and reason. It reports
+// false if the execution had already finished.
+func reapExecution(executionID int, status, reason string) (bool, error) {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var forkID sql.NullInt64
+	err = tx.QueryRow(`
+		UPDATE workflow_executions
+		SET status = $2, result = $3, finished_at = NOW(), resume_handle = NULL, locked_by = NULL, lease_expires_at = NULL
+		WHERE id = $1 AND status = ANY($4)
+		RETURNING fork_id
+	`, executionID, status, reason, pq.Array(models.ActiveStatuses)).Scan(&forkID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := CancelWaits(tx, []int{executionID}); err != nil {
+		return false, err
+	}
+	if err := CancelBranches(tx, executionID, reason); err != nil {
+		return false, err
+	}
+	if forkID.Valid {
+		if err := SettleFork(tx, int(forkID.Int64)); err != nil {
+			return false, err
+		}
+	}
+	if err := ResumeParent(tx, executionID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMissingNodes(t *testing.T) {
+	steps := `{"nodes": [{"id": "t1", "type": "TRIGGER"}, {"id": "a1", "type": "ACTION"}], "edges": []}`
+	if got := missingNodes(steps, []string{"t1", "a1", "gone"}); !slices.Equal(got, []string{"gone"}) {
+		t.Errorf("expected only the deleted node, got %v", got)
+	}
+	if got := missingNodes(steps, []string{"a1"}); len(got) != 0 {
+		t.Errorf("expected no missing nodes, got %v", got)
+	}
+	if got := missingNodes(`[{"action": "Send Email"}]`, []string{"a1"}); got != nil {
+		t.Errorf("expected legacy steps to be skipped, got %v", got)
+	}
+}
+
+func TestMaxDurationFromEnv(t *testing.T) {
+	t.Setenv("EXECUTION_MAX_AGE_DAYS", "")
+	if got := maxDurationFromEnv(); got != 0 {
+		t.Errorf("expected no default limit unless set, got %d hours", got)
+	}
+	t.Setenv("EXECUTION_MAX_AGE_DAYS", "180")
+	if got := maxDurationFromEnv(); got != 180*24 {
+		t.Errorf("expected 180 days, got %d hours", got)
+	}
+	t.Setenv("EXECUTION_MAX_AGE_DAYS", "-3")
+	if got := maxDurationFromEnv(); got != 0 {
+		t.Errorf("expected a negative value to be ignored, got %d hours", got)
+	}
+}
@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+)
+
+// ErrNotWaiting is returned when resuming an execution that is not parked at the given node
+var ErrNotWaiting = errors.New("execution is not waiting at this node")
+
+// ResumeExecution puts a parked execution back in the queue. The worker will not
+// re-run nodeID; it follows handle out of it instead. contextPatch, if given, is
+// merged into the execution context first.
+func ResumeExecution(tx *sql.Tx, executionID int, nodeID string, handle string, contextPatch map[string]interface{}) error {
+	var status string
+	var currentNodeID, contextStr sql.NullString
+	err := tx.QueryRow(`
+		SELECT status, current_node_id, context FROM workflow_executions WHERE id = $1 FOR UPDATE
+	`, executionID).Scan(&status, &currentNodeID, &contextStr)
+	if err == sql.ErrNoRows {
+		return ErrNotWaiting
+	} else if err != nil {
+		return err
+	}
+	if !isWaitingStatus(status) || currentNodeID.String != nodeID {
+		return ErrNotWaiting
+	}
+
+	ctxData := map[string]interface{}{}
+	if contextStr.Valid && contextStr.String != "" {
+		json.Unmarshal([]byte(contextStr.String), &ctxData)
+	}
+	for k, v := range contextPatch {
+		ctxData[k] = v
+	}
+	contextJSON, _ := json.Marshal(ctxData)
+
+	_, err = tx.Exec(`
+		UPDATE workflow_executions
+		SET status = 'PENDING', resume_handle = $2, context = $3, next_run_at = NOW()
+		WHERE id = $1
+	`, executionID, handle, string(contextJSON))
+	return err
+}
+
+// CancelWaits cancels the pending approvals of executions that were ended early
+// and drops their event waits. Call it in the transaction that ends them.
+func CancelWaits(tx *sql.Tx, executionIDs []int) error {
+	if len(executionIDs) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(`
+		UPDATE workflow_approvals SET status = 'CANCELLED', decided_at = NOW()
+		WHERE execution_id = ANY($1) AND status = 'PENDING'
+	`, pq.Array(executionIDs))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM workflow_event_waits WHERE execution_id = ANY($1)`, pq.Array(executionIDs))
+	return err
+}
+
+func isWaitingStatus(status string) bool {
+	return status == string(models.StatusWaitingForHuman) || status == string(models.StatusWaitingForEvent) ||
+		status == string(models.StatusWaitingForChild)
+}
+
+// expireApprovals times out approvals that passed their due date and sends their
+// executions down the "timeout" handle.
+func expireApprovals() {
+	_, err := db.GetDB().Exec(`
+		WITH expired AS (
+			UPDATE workflow_approvals
+			SET status = 'TIMED_OUT', decided_at = NOW()
+			WHERE status = 'PENDING' AND due_at <= NOW()
+			RETURNING execution_id, node_id
+		)
+		UPDATE workflow_executions we
+		SET status = 'PENDING', resume_handle = 'timeout', next_run_at = NOW()
+		FROM expired e
+		WHERE we.id = e.execution_id AND we.current_node_id = e.node_id AND we.status = 'WAITING_FOR_HUMAN'
+	`)
+	if err != nil {
+		Logger.Error("Failed to expire approvals:", err)
+	}
+}
@@ -1,13 +1,17 @@
 package scheduler
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/labstack/gommon/log"
 	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/workflows"
 )
 
 var Logger *log.Logger
@@ -17,19 +21,50 @@ func init() {
 	Logger.SetHeader("${time_rfc3339} | ${level} | ${prefix} |")
 }
 
+// leader guards singleton jobs so that only one replica fires campaigns and
+// scheduled triggers, no matter how many API pods are running.
+var leader = NewLeaderElector(schedulerLockKey)
+
+// stopScheduler ends the singleton job loop started by Start
+var stopScheduler = make(chan struct{})
+
+// stopOnce makes Stop safe to call more than once
+var stopOnce sync.Once
+
 func Start() {
 	ticker := time.NewTicker(1 * time.Minute)
 	// ... existing code ...
 	go func() {
-		for range ticker.C {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopScheduler:
+				return
+			case <-ticker.C:
+			}
+			if !leader.IsLeader(context.Background()) {
+				continue
+			}
 			runDueCampaigns()
 			runScheduledWorkflows()
+			reapExecutions()
 		}
 	}()
 	Logger.Info("Scheduler started")
 	StartWorker() // Start workflow worker
 }
 
+// Stop shuts the scheduler down gracefully: singleton jobs stop, leadership is
+// handed over, and the worker drains in-flight executions until ctx expires.
+func Stop(ctx context.Context) error {
+	stopOnce.Do(func() { close(stopScheduler) })
+	leader.Resign()
+	if worker == nil {
+		return nil
+	}
+	return worker.Stop(ctx)
+}
+
 func runDueCampaigns() {
 	// ... no changes to this function ...
 	dbConn := db.GetDB()
@@ -84,7 +119,7 @@ func runScheduledWorkflows() {
 	dbConn := db.GetDB()
 	// Query due scheduled triggers
 	rows, err := dbConn.Query(`
-		SELECT wt.id, wt.workflow_id, wt.node_id, w.audience_id, wt.config 
+		SELECT wt.id, wt.workflow_id, wt.node_id, w.audience_id, wt.config, wt.next_run_at, COALESCE(w.organization_id, 0)
 		FROM workflow_triggers wt
 		JOIN workflows w ON wt.workflow_id = w.id 
 		WHERE wt.type = 'SCHEDULE' AND w.status='ACTIVE' AND wt.next_run_at <= NOW()
@@ -100,8 +135,10 @@ func runScheduledWorkflows() {
 		var nodeID string
 		var audIDPtr *int
 		var configStr sql.NullString
+		var firedAt time.Time
+		var orgID int
 
-		if err := rows.Scan(&triggerID, &workflowID, &nodeID, &audIDPtr, &configStr); err != nil {
+		if err := rows.Scan(&triggerID, &workflowID, &nodeID, &audIDPtr, &configStr, &firedAt, &orgID); err != nil {
 			Logger.Error("Scan error:", err)
 			continue
 		}
@@ -120,6 +157,7 @@ func runScheduledWorkflows() {
 		}
 
 		// 2. Trigger Config Audience IDs
+		properties := map[string]interface{}{}
 		if configStr.Valid && configStr.String != "" {
 			var config struct {
 				AudienceIDs []int `json:"audience_ids"`
@@ -129,23 +167,54 @@ func runScheduledWorkflows() {
 					contextData["audience_ids"] = config.AudienceIDs
 				}
 			}
+			json.Unmarshal([]byte(configStr.String), &properties)
 		}
 		contextJSON, _ := json.Marshal(contextData)
 
-		// Note: We set current_node_id to the trigger node ID directly
-		_, err = dbConn.Exec(`
-			INSERT INTO workflow_executions (workflow_id, current_node_id, status, next_run_at, created_at, context) 
-			VALUES ($1, $2, 'PENDING', NOW(), NOW(), $3)`,
-			workflowID, nodeID, string(contextJSON))
+		configAudiences, _ := contextData["audience_ids"].([]int)
+		audienceIDs := uniqueIDs(append([]int{audienceID}, configAudiences...))
+
+		if len(audienceIDs) > 0 {
+			// One execution per person in the audiences
+			run := fanOut{
+				WorkflowID:  workflowID,
+				OrgID:       orgID,
+				NodeID:      nodeID,
+				AudienceIDs: audienceIDs,
+				Context:     string(contextJSON),
+				Key:         fanOutKey(triggerID, firedAt),
+			}
+			created, err := run.Execute(context.Background())
+			if err != nil {
+				// Leave next_run_at alone so the next tick resumes the fan-out;
+				// people who already have an execution for this run are skipped
+				Logger.Errorf("Fan-out for trigger %d stopped after %d executions: %v", triggerID, created, err)
+				continue
+			}
+			Logger.Infof("Fan-out for trigger %d created %d executions", triggerID, created)
+		} else {
+			// Note: We set current_node_id to the trigger node ID directly
+			_, err = dbConn.Exec(`
+				INSERT INTO workflow_executions (workflow_id, version_id, current_node_id, status, next_run_at, created_at, context, priority)
+				SELECT id, published_version_id, $2, 'PENDING', NOW(), NOW(), $3, COALESCE(priority, 0) FROM workflows WHERE id = $1`,
+				workflowID, nodeID, string(contextJSON))
+
+			if err != nil {
+				Logger.Error("Failed to create execution:", err)
+				continue
+			}
+		}
 
+		// Calculate Next Run from the trigger's cron expression
+		var nextRun sql.NullTime
+		next, _, err := workflows.NextTriggerRun(string(models.TriggerTypeSchedule), properties, time.Now())
 		if err != nil {
-			Logger.Error("Failed to create execution:", err)
-			continue
+			// Stop firing until the workflow is saved with a valid schedule
+			Logger.Errorf("Invalid schedule for trigger %d, disabling: %v", triggerID, err)
+		} else if !next.IsZero() {
+			nextRun = sql.NullTime{Time: next, Valid: true}
 		}
 
-		// Calculate Next Run (MVP: +24h)
-		nextRun := time.Now().Add(24 * time.Hour)
-
 		_, err = dbConn.Exec("UPDATE workflow_triggers SET next_run_at = $1 WHERE id = $2", nextRun, triggerID)
 		if err != nil {
 			Logger.Error("Failed to update trigger next run:", err)
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// Quiet hours
+//
+// Messages only go out inside a send window, in the recipient's local time. The
+// workflow's window applies if it has one, else the organization's. A send that
+// comes due outside it is deferred: the execution stays PENDING on the node, the
+// step is recorded as deferred, and the node runs again when the window opens.
+
+// loadSendWindow returns the send window a workflow's messages keep to, or nil
+// when they may go out at any hour
+func loadSendWindow(ctx context.Context, workflowID int) (*workflows.SendWindow, error) {
+	var raw sql.NullString
+	err := db.GetDB().QueryRowContext(ctx, `
+		SELECT COALESCE(w.send_window, o.send_window)::text
+		FROM workflows w
+		LEFT JOIN organizations o ON o.id = w.organization_id
+		WHERE w.id = $1
+	`, workflowID).Scan(&raw)
+	if err == sql.ErrNoRows || (err == nil && !raw.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var window workflows.SendWindow
+	if err := json.Unmarshal([]byte(raw.String), &window); err != nil {
+		return nil, fmt.Errorf("invalid send window: %w", err)
+	}
+	return &window, nil
+}
+
+// deferToSendWindow checks a send against the send window. Outside it, it
+// returns the outcome that defers the node to the window's next opening and true.
+func deferToSendWindow(ctx context.Context, exec ScheduledExecution) (nodeOutcome, bool) {
+	window, err := loadSendWindow(ctx, exec.WorkflowID)
+	if err != nil {
+		return nodeOutcome{Status: "failed", Output: "Failed to check send window", Handle: "default", Err: fmt.Errorf("failed to check send window: %w", err)}, true
+	}
+	if window == nil {
+		return nodeOutcome{}, false
+	}
+
+	zone := ""
+	if exec.SubjectID.Valid {
+		if zone, err = personTimeZone(ctx, int(exec.SubjectID.Int64)); err != nil {
+			return nodeOutcome{Status: "failed", Output: "Failed to load the person's time zone", Handle: "default", Err: err}, true
+		}
+	}
+	loc := window.Location(zone)
+
+	now := time.Now()
+	opensAt := window.NextOpen(now, loc)
+	if !opensAt.After(now) {
+		return nodeOutcome{}, false
+	}
+	return nodeOutcome{
+		Status: "deferred",
+		Output: fmt.Sprintf("Deferred to %s: outside the send window %s-%s (%s)", opensAt.In(loc).Format(time.RFC3339), window.Start, window.End, loc),
+		Park:   &parkRequest{Status: string(models.StatusPending), WakeAt: &opensAt},
+	}, true
+}
@@ -0,0 +1,320 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wesuuu/helpnow/backend/models"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// maxSimulationSteps stops simulations of graphs that loop
+const maxSimulationSteps = 100
+
+// SimulationOptions configures a dry run of a workflow graph
+type SimulationOptions struct {
+	WorkflowID     int
+	OrganizationID int
+	SubjectID      *int                              // Person to run for; their fields are available to templates
+	Context        map[string]interface{}            // Sample trigger context
+	StartNodeID    string                            // Defaults to the first trigger
+	Decisions      map[string]string                 // Handle to leave each approval or wait node through (default "approved" or "matched"), or variant for a split node
+	Mocks          map[string]map[string]interface{} // Structured output to use instead of running an action, by node ID
+}
+
+// SimulatedStep is one node run during a simulation
+type SimulatedStep struct {
+	NodeID       string                 `json:"node_id"`
+	Label        string                 `json:"label,omitempty"`
+	Type         string                 `json:"type"`
+	Status       string                 `json:"status"`
+	Output       string                 `json:"output"`
+	Error        string                 `json:"error,omitempty"`
+	Handle       string                 `json:"handle,omitempty"` // Handle followed out of the node
+	Data         map[string]interface{} `json:"data,omitempty"`
+	DelaySeconds float64                `json:"delay_seconds,omitempty"` // Wait a real execution would take before this node
+	Mocked       bool                   `json:"mocked,omitempty"`        // Output came from the request's mocks
+	Branch       string                 `json:"branch,omitempty"`        // Parallel branch the node ran on
+}
+
+// SimulationResult is the outcome of a dry run
+type SimulationResult struct {
+	Status            string                 `json:"status"` // COMPLETED, FAILED, or STOPPED at the step limit
+	Result            string                 `json:"result,omitempty"`
+	Path              []string               `json:"path"`
+	Steps             []SimulatedStep        `json:"steps"`
+	Context           map[string]interface{} `json:"context"`
+	TotalDelaySeconds float64                `json:"total_delay_seconds"` // Along the longest branch
+}
+
+// simToken is a position in the graph during a simulation. Each parallel branch
+// has its own token.
+type simToken struct {
+	nodeID  string
+	branch  string
+	forks   []string // Forks the token is a branch of, innermost last
+	delay   float64  // Wait before running nodeID
+	elapsed float64  // Total wait since the start, including delay
+}
+
+// Simulate walks a graph the way the worker would, using the same node logic, but
+// nothing is persisted: side-effecting actions run in mock mode, delays and
+// DELAY nodes are added up instead of waited out, and approvals and splits take
+// the decision given in opts.
+// Parallel branches run one after another; a JOIN continues on its last arrival,
+// or its first with mode "any", which drops the branches still queued. Loop
+// iterations run one after another before the loop continues on "done".
+func Simulate(ctx context.Context, graph workflows.Graph, opts SimulationOptions) SimulationResult {
+	result := SimulationResult{Path: []string{}, Steps: []SimulatedStep{}}
+
+	// Work on a copy so the caller's sample context is left untouched
+	ctxData := map[string]interface{}{}
+	if raw, err := json.Marshal(opts.Context); err == nil {
+		json.Unmarshal(raw, &ctxData)
+	}
+	if ctxData == nil {
+		ctxData = map[string]interface{}{}
+	}
+	result.Context = ctxData
+
+	exec := ScheduledExecution{
+		WorkflowID: opts.WorkflowID,
+		OrgID:      sql.NullInt64{Int64: int64(opts.OrganizationID), Valid: opts.OrganizationID > 0},
+		DryRun:     true,
+	}
+	if opts.SubjectID != nil {
+		exec.SubjectID = sql.NullInt64{Int64: int64(*opts.SubjectID), Valid: true}
+	}
+
+	startNodeID := opts.StartNodeID
+	if startNodeID == "" {
+		startNodeID = findStartNode(graph)
+	}
+	if startNodeID == "" {
+		result.Status, result.Result = "FAILED", "No start node found"
+		return result
+	}
+
+	history := []StepResult{}
+	queue := []simToken{{nodeID: startNodeID}}
+	forkCount := 0
+	for len(queue) > 0 {
+		if len(result.Steps) >= maxSimulationSteps {
+			result.Status = "STOPPED"
+			result.Result = fmt.Sprintf("Stopped after %d steps; the workflow may loop", maxSimulationSteps)
+			return result
+		}
+
+		tok := queue[0]
+		queue = queue[1:]
+		result.TotalDelaySeconds = max(result.TotalDelaySeconds, tok.elapsed)
+
+		node := findNode(graph, tok.nodeID)
+		if node == nil {
+			result.Status, result.Result = "FAILED", "Node not found: "+tok.nodeID
+			return result
+		}
+
+		step := SimulatedStep{
+			NodeID:       node.ID,
+			Label:        node.Label,
+			Type:         node.Type,
+			DelaySeconds: tok.delay,
+			Branch:       tok.branch,
+		}
+		result.Path = append(result.Path, node.ID)
+
+		// A branch arriving at a join
+		if node.Type == string(models.NodeTypeJoin) && len(tok.forks) > 0 {
+			fork := tok.forks[len(tok.forks)-1]
+			join, err := workflows.ParseJoinNode(node.Properties)
+			if err != nil {
+				step.Status, step.Output, step.Error = "failed", "Invalid join node", err.Error()
+				result.Steps = append(result.Steps, step)
+				result.Status, result.Result = "FAILED", "Node execution failed"
+				return result
+			}
+			step.Status = "success"
+			siblings := 0
+			for _, other := range queue {
+				if slices.Contains(other.forks, fork) {
+					siblings++
+				}
+			}
+			if join.Mode == workflows.JoinAny {
+				queue = slices.DeleteFunc(queue, func(other simToken) bool { return slices.Contains(other.forks, fork) })
+				if siblings > 0 {
+					step.Output = fmt.Sprintf("Arrived first; %d other branches cancelled", siblings)
+				}
+			} else if siblings > 0 {
+				step.Output = "Arrived at join; waiting for the other branches"
+				result.Steps = append(result.Steps, step)
+				history = append(history, StepResult{NodeID: node.ID, Status: step.Status, Output: step.Output, Branch: tok.branch})
+				continue
+			}
+			if step.Output == "" {
+				step.Output = "Joined"
+			}
+			// Continue as the branch that forked
+			tok.forks = tok.forks[:len(tok.forks)-1]
+			if k := strings.LastIndex(tok.branch, "/"); k >= 0 {
+				tok.branch = tok.branch[:k]
+			} else {
+				tok.branch = ""
+			}
+			step.Handle = "default"
+			result.Steps = append(result.Steps, step)
+			history = append(history, StepResult{NodeID: node.ID, Status: step.Status, Output: step.Output, Branch: step.Branch})
+			queue = append(queue, nextTokens(graph, tok, node.ID, "default", &forkCount)...)
+			continue
+		}
+
+		historyJSON, _ := json.Marshal(history)
+		exec.ResultJSON = sql.NullString{String: string(historyJSON), Valid: true}
+		exec.CurrentNodeID = sql.NullString{String: tok.nodeID, Valid: true}
+
+		var outcome nodeOutcome
+		mock, mocked := opts.Mocks[node.ID]
+		if mocked && node.Type == string(models.NodeTypeAction) {
+			outcome = nodeOutcome{Status: "success", Output: "Mocked output", Handle: "default", Data: mock}
+		} else {
+			mocked = false
+			outcome = executeNode(ctx, exec, node, ctxData)
+		}
+
+		step.Status = outcome.Status
+		step.Output = outcome.Output
+		step.Data = outcome.Data
+		step.Mocked = mocked
+		if outcome.Err != nil {
+			step.Error = outcome.Err.Error()
+		}
+		history = append(history, StepResult{NodeID: node.ID, Status: step.Status, Output: step.Output, Error: step.Error, Branch: tok.branch})
+
+		if outcome.Status == "failed" {
+			result.Steps = append(result.Steps, step)
+			result.Status, result.Result = "FAILED", "Node execution failed"
+			return result
+		}
+
+		if outcome.Data != nil {
+			ctxData[node.ID] = outcome.Data
+		}
+
+		handle := outcome.Handle
+		if outcome.Park != nil && outcome.Park.Handle != "" {
+			// A delay: add the wait instead of sleeping through it
+			wait := time.Until(*outcome.Park.WakeAt).Seconds()
+			tok.elapsed += wait
+			step.Output += fmt.Sprintf(" (simulated wait of %.0f seconds)", wait)
+		} else if outcome.Park != nil {
+			handle = workflows.HandleApproved
+			if node.Type == string(models.NodeTypeWaitForEvent) {
+				handle = workflows.HandleMatched
+			}
+			if decision, ok := opts.Decisions[node.ID]; ok {
+				handle = decision
+			}
+			step.Output += "; simulated decision: " + handle
+		}
+		if decision, ok := opts.Decisions[node.ID]; ok && node.Type == string(models.NodeTypeSplit) {
+			handle = decision
+			ctxData[node.ID] = map[string]interface{}{"variant": decision}
+			step.Output = "Assigned to variant " + decision + " (simulated decision)"
+		}
+		if outcome.Items != nil {
+			step.Handle = workflows.HandleItem
+			result.Steps = append(result.Steps, step)
+			if !simulateLoop(ctx, graph, opts, node.ID, outcome.Items, tok, ctxData, &result) {
+				return result
+			}
+			queue = append(queue, nextTokens(graph, tok, node.ID, workflows.HandleDone, &forkCount)...)
+			continue
+		}
+		step.Handle = handle
+		result.Steps = append(result.Steps, step)
+
+		queue = append(queue, nextTokens(graph, tok, node.ID, handle, &forkCount)...)
+	}
+
+	result.Status = "COMPLETED"
+	return result
+}
+
+// simulateLoop runs a loop's body once per item as a nested simulation, adding
+// the iterations' steps to result under their branch label and their summary to
+// the context. It returns false once the step limit is reached.
+func simulateLoop(ctx context.Context, graph workflows.Graph, opts SimulationOptions, nodeID string, items []interface{}, tok simToken, ctxData map[string]interface{}, result *SimulationResult) bool {
+	targets := findNextNodes(graph, nodeID, workflows.HandleItem)
+	iterations := []map[string]interface{}{}
+	for i, item := range items {
+		label := branchLabel(tok.branch, fmt.Sprintf("%s[%d]", nodeID, i))
+		for _, target := range targets {
+			targetLabel := label
+			if len(targets) > 1 {
+				targetLabel = branchLabel(label, target)
+			}
+			iterationOpts := opts
+			iterationOpts.StartNodeID = target
+			iterationOpts.Context = loopContext(ctxData, nodeID, i, len(items), item)
+			sub := Simulate(ctx, graph, iterationOpts)
+
+			steps := make([]StepResult, 0, len(sub.Steps))
+			for _, step := range sub.Steps {
+				if step.Branch == "" {
+					step.Branch = targetLabel
+				} else {
+					step.Branch = branchLabel(targetLabel, step.Branch)
+				}
+				result.Steps = append(result.Steps, step)
+				steps = append(steps, StepResult{NodeID: step.NodeID, Status: step.Status})
+			}
+			result.Path = append(result.Path, sub.Path...)
+			result.TotalDelaySeconds = max(result.TotalDelaySeconds, tok.elapsed+sub.TotalDelaySeconds)
+
+			status := string(models.StatusCompleted)
+			if sub.Status != "COMPLETED" {
+				status = string(models.StatusFailed)
+			}
+			iterations = append(iterations, loopIteration(status, sub.Context, steps))
+
+			if len(result.Steps) >= maxSimulationSteps {
+				result.Status = "STOPPED"
+				result.Result = fmt.Sprintf("Stopped after %d steps; the workflow may loop", maxSimulationSteps)
+				return false
+			}
+		}
+	}
+	ctxData[nodeID] = loopSummary(ctxData[nodeID], iterations)
+	return true
+}
+
+// nextTokens moves a token out of nodeID through handle, splitting it into one
+// token per branch when there are several targets
+func nextTokens(graph workflows.Graph, tok simToken, nodeID, handle string, forkCount *int) []simToken {
+	targets := findNextNodes(graph, nodeID, handle)
+	forks := tok.forks
+	if len(targets) > 1 {
+		*forkCount++
+		forks = append(slices.Clone(tok.forks), fmt.Sprintf("%s#%d", nodeID, *forkCount))
+	}
+
+	tokens := make([]simToken, 0, len(targets))
+	for _, target := range targets {
+		next := simToken{nodeID: target, branch: tok.branch, forks: forks, elapsed: tok.elapsed}
+		if len(targets) > 1 {
+			next.branch = branchLabel(tok.branch, target)
+		}
+		if n := findNode(graph, target); n != nil {
+			next.delay = nodeDelay(n).Seconds()
+		}
+		next.elapsed += next.delay
+		tokens = append(tokens, next)
+	}
+	return tokens
+}
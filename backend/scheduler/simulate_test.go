@@ -0,0 +1,308 @@
+package scheduler
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// sideEffectExecutions counts real executions of simulatorTestAction
+var sideEffectExecutions int
+
+type simulatorTestAction struct {
+	To string `json:"to"`
+}
+
+func (a *simulatorTestAction) Execute(ctx context.Context, contextData map[string]interface{}) (string, error) {
+	sideEffectExecutions++
+	return "sent", nil
+}
+
+func (a *simulatorTestAction) Simulate(ctx context.Context, contextData map[string]interface{}) (string, map[string]interface{}, error) {
+	return "would send to " + a.To, map[string]interface{}{"to": a.To}, nil
+}
+
+func TestSimulate(t *testing.T) {
+	workflows.RegisterAction("SimulatorTestAction", &simulatorTestAction{})
+	workflows.RegisterLogic("Condition", &MockLogic{})
+
+	graph := workflows.Graph{
+		Nodes: []workflows.Node{
+			{ID: "t1", Type: "TRIGGER", Properties: map[string]interface{}{}},
+			{ID: "c1", Type: "CONDITION", Properties: map[string]interface{}{"result": true, "output": "checked"}},
+			{ID: "a1", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "{{context.email}}", "delay_days": 2.0}},
+			{ID: "a2", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "nobody"}},
+			{ID: "ap", Type: "APPROVAL", Properties: map[string]interface{}{"assignee_user_id": 1.0}},
+			{ID: "a3", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "approved"}},
+			{ID: "a4", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "rejected"}},
+		},
+		Edges: []workflows.Edge{
+			{Source: "t1", Target: "c1"},
+			{Source: "c1", Target: "a1", Handle: "true"},
+			{Source: "c1", Target: "a2", Handle: "false"},
+			{Source: "a1", Target: "ap"},
+			{Source: "ap", Target: "a3", Handle: "approved"},
+			{Source: "ap", Target: "a4", Handle: "rejected"},
+		},
+	}
+
+	sideEffectExecutions = 0
+	sample := map[string]interface{}{"email": "jane@example.com"}
+	result := Simulate(context.Background(), graph, SimulationOptions{
+		Context:   sample,
+		Decisions: map[string]string{"ap": "rejected"},
+		Mocks:     map[string]map[string]interface{}{"a4": {"status": 201.0}},
+	})
+
+	if result.Status != "COMPLETED" {
+		t.Fatalf("expected COMPLETED, got %s (%s): %+v", result.Status, result.Result, result.Steps)
+	}
+	want := []string{"t1", "c1", "a1", "ap", "a4"}
+	if len(result.Path) != len(want) {
+		t.Fatalf("path = %v, want %v", result.Path, want)
+	}
+	for i := range want {
+		if result.Path[i] != want[i] {
+			t.Fatalf("path = %v, want %v", result.Path, want)
+		}
+	}
+
+	if sideEffectExecutions != 0 {
+		t.Errorf("expected side-effecting actions to be simulated, Execute ran %d times", sideEffectExecutions)
+	}
+	if got := result.Steps[2]; got.Output != "would send to jane@example.com" || got.DelaySeconds != 2*24*3600 {
+		t.Errorf("unexpected action step %+v", got)
+	}
+	if result.TotalDelaySeconds != 2*24*3600 {
+		t.Errorf("total delay = %v", result.TotalDelaySeconds)
+	}
+	if got := result.Steps[3]; got.Handle != "rejected" {
+		t.Errorf("expected the approval to follow the given decision, got %+v", got)
+	}
+	if got := result.Steps[4]; !got.Mocked || result.Context["a4"].(map[string]interface{})["status"] != 201.0 {
+		t.Errorf("expected mocked output in the context, got step %+v context %v", got, result.Context)
+	}
+	if _, ok := sample["a1"]; ok {
+		t.Error("simulation modified the caller's sample context")
+	}
+}
+
+func TestSimulateStopsLoops(t *testing.T) {
+	workflows.RegisterAction("SimulatorTestAction", &simulatorTestAction{})
+
+	graph := workflows.Graph{
+		Nodes: []workflows.Node{
+			{ID: "a1", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction"}},
+			{ID: "a2", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "delay_hours": 1.0}},
+		},
+		Edges: []workflows.Edge{{Source: "a1", Target: "a2"}, {Source: "a2", Target: "a1"}},
+	}
+
+	result := Simulate(context.Background(), graph, SimulationOptions{})
+	if result.Status != "STOPPED" || len(result.Steps) != maxSimulationSteps {
+		t.Errorf("expected the loop to stop after %d steps, got %s with %d steps", maxSimulationSteps, result.Status, len(result.Steps))
+	}
+}
+
+func TestSimulateWaitForEvent(t *testing.T) {
+	workflows.RegisterAction("SimulatorTestAction", &simulatorTestAction{})
+
+	graph := workflows.Graph{
+		Nodes: []workflows.Node{
+			{ID: "t1", Type: "TRIGGER", Properties: map[string]interface{}{}},
+			{ID: "w1", Type: "WAIT_FOR_EVENT", Properties: map[string]interface{}{"event": "purchase", "timeout_hours": 72.0}},
+			{ID: "a1", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "buyer"}},
+			{ID: "a2", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "nudge"}},
+		},
+		Edges: []workflows.Edge{
+			{Source: "t1", Target: "w1"},
+			{Source: "w1", Target: "a1", Handle: "matched"},
+			{Source: "w1", Target: "a2", Handle: "timeout"},
+		},
+	}
+
+	result := Simulate(context.Background(), graph, SimulationOptions{})
+	if result.Status != "COMPLETED" || result.Path[len(result.Path)-1] != "a1" {
+		t.Errorf("expected the wait to follow 'matched' by default, got %s via %v", result.Status, result.Path)
+	}
+
+	result = Simulate(context.Background(), graph, SimulationOptions{Decisions: map[string]string{"w1": "timeout"}})
+	if result.Status != "COMPLETED" || result.Path[len(result.Path)-1] != "a2" {
+		t.Errorf("expected the wait to follow 'timeout', got %s via %v", result.Status, result.Path)
+	}
+}
+
+func TestSimulateSplit(t *testing.T) {
+	workflows.RegisterAction("SimulatorTestAction", &simulatorTestAction{})
+
+	graph := workflows.Graph{
+		Nodes: []workflows.Node{
+			{ID: "t1", Type: "TRIGGER", Properties: map[string]interface{}{}},
+			{ID: "s1", Type: "SPLIT", Properties: map[string]interface{}{"variants": []interface{}{
+				map[string]interface{}{"name": "A", "weight": 50.0},
+				map[string]interface{}{"name": "B", "weight": 50.0},
+			}}},
+			{ID: "a1", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "variant a"}},
+			{ID: "b1", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "variant b"}},
+		},
+		Edges: []workflows.Edge{
+			{Source: "t1", Target: "s1"},
+			{Source: "s1", Target: "a1", Handle: "A"},
+			{Source: "s1", Target: "b1", Handle: "B"},
+		},
+	}
+
+	// The same person lands on the same variant every run
+	subject := 42
+	first := Simulate(context.Background(), graph, SimulationOptions{WorkflowID: 1, SubjectID: &subject})
+	second := Simulate(context.Background(), graph, SimulationOptions{WorkflowID: 1, SubjectID: &subject})
+	if first.Status != "COMPLETED" || len(first.Path) != 3 || !slices.Equal(first.Path, second.Path) {
+		t.Errorf("expected a deterministic assignment, got %v then %v", first.Path, second.Path)
+	}
+
+	result := Simulate(context.Background(), graph, SimulationOptions{Decisions: map[string]string{"s1": "B"}})
+	if result.Status != "COMPLETED" || result.Path[len(result.Path)-1] != "b1" {
+		t.Errorf("expected the split to follow variant B, got %s via %v", result.Status, result.Path)
+	}
+	if variant, _ := result.Context["s1"].(map[string]interface{}); variant["variant"] != "B" {
+		t.Errorf("expected the variant in the context, got %v", result.Context["s1"])
+	}
+}
+
+func TestSimulateLoop(t *testing.T) {
+	workflows.RegisterAction("SimulatorTestAction", &simulatorTestAction{})
+
+	graph := workflows.Graph{
+		Nodes: []workflows.Node{
+			{ID: "t1", Type: "TRIGGER", Properties: map[string]interface{}{}},
+			{ID: "l1", Type: "LOOP", Properties: map[string]interface{}{"items": "event.line_items", "max_iterations": 2.0}},
+			{ID: "a1", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "{{item.sku}}"}},
+			{ID: "done", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "ops"}},
+		},
+		Edges: []workflows.Edge{
+			{Source: "t1", Target: "l1"},
+			{Source: "l1", Target: "a1", Handle: "item"},
+			{Source: "l1", Target: "done", Handle: "done"},
+		},
+	}
+	sample := map[string]interface{}{"event": map[string]interface{}{"line_items": []interface{}{
+		map[string]interface{}{"sku": "A-1"},
+		map[string]interface{}{"sku": "B-2"},
+		map[string]interface{}{"sku": "C-3"},
+	}}}
+
+	result := Simulate(context.Background(), graph, SimulationOptions{Context: sample})
+	if want := []string{"t1", "l1", "a1", "a1", "done"}; result.Status != "COMPLETED" || !slices.Equal(result.Path, want) {
+		t.Fatalf("expected path %v, got %s via %v (%s)", want, result.Status, result.Path, result.Result)
+	}
+	if result.Steps[2].Output != "would send to A-1" || result.Steps[2].Branch != "l1[0]" || result.Steps[3].Output != "would send to B-2" {
+		t.Errorf("expected each iteration to bind its item, got %+v", result.Steps[2:4])
+	}
+
+	summary, _ := result.Context["l1"].(map[string]interface{})
+	iterations, _ := summary["results"].([]map[string]interface{})
+	if summary["truncated"] != true || summary["completed"] != 2 || len(iterations) != 2 {
+		t.Fatalf("expected two completed iterations of a truncated list, got %v", summary)
+	}
+	if outputs, _ := iterations[1]["outputs"].(map[string]interface{}); outputs["a1"] == nil {
+		t.Errorf("expected the iteration's node output in the results, got %v", iterations[1])
+	}
+}
+
+func TestSimulateDelay(t *testing.T) {
+	workflows.RegisterAction("SimulatorTestAction", &simulatorTestAction{})
+
+	graph := workflows.Graph{
+		Nodes: []workflows.Node{
+			{ID: "t1", Type: "TRIGGER", Properties: map[string]interface{}{}},
+			{ID: "d1", Type: "DELAY", Properties: map[string]interface{}{"days": 1.0}},
+			{ID: "d2", Type: "DELAY", Properties: map[string]interface{}{"mode": "until", "until": "{{context.event.expired_at}}"}},
+			{ID: "a1", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "customer"}},
+		},
+		Edges: []workflows.Edge{
+			{Source: "t1", Target: "d1"},
+			{Source: "d1", Target: "d2"},
+			{Source: "d2", Target: "a1"},
+		},
+	}
+	sample := map[string]interface{}{"event": map[string]interface{}{"expired_at": "2020-01-01T00:00:00Z"}}
+
+	result := Simulate(context.Background(), graph, SimulationOptions{Context: sample})
+	if want := []string{"t1", "d1", "d2", "a1"}; result.Status != "COMPLETED" || !slices.Equal(result.Path, want) {
+		t.Fatalf("expected path %v, got %s via %v (%s)", want, result.Status, result.Path, result.Result)
+	}
+	if result.TotalDelaySeconds < 86399 || result.TotalDelaySeconds > 86400 {
+		t.Errorf("expected a day of delay, got %v seconds", result.TotalDelaySeconds)
+	}
+	if data := result.Steps[2].Data; data == nil || data["wake_at"] != "2020-01-01T00:00:00Z" {
+		t.Errorf("expected the past wake-up time in the step data, got %+v", result.Steps[2])
+	}
+}
+
+func TestSimulateParallelBranches(t *testing.T) {
+	workflows.RegisterAction("SimulatorTestAction", &simulatorTestAction{})
+
+	graph := func(mode string) workflows.Graph {
+		return workflows.Graph{
+			Nodes: []workflows.Node{
+				{ID: "t1", Type: "TRIGGER", Properties: map[string]interface{}{}},
+				{ID: "email", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "customer"}},
+				{ID: "sales", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "sales", "delay_hours": 2.0}},
+				{ID: "j1", Type: "JOIN", Properties: map[string]interface{}{"mode": mode}},
+				{ID: "done", Type: "ACTION", Properties: map[string]interface{}{"action": "SimulatorTestAction", "to": "ops"}},
+			},
+			Edges: []workflows.Edge{
+				{Source: "t1", Target: "email"},
+				{Source: "t1", Target: "sales"},
+				{Source: "email", Target: "j1"},
+				{Source: "sales", Target: "j1"},
+				{Source: "j1", Target: "done"},
+			},
+		}
+	}
+
+	result := Simulate(context.Background(), graph("all"), SimulationOptions{})
+	if result.Status != "COMPLETED" {
+		t.Fatalf("expected COMPLETED, got %s (%s)", result.Status, result.Result)
+	}
+	want := []string{"t1", "email", "sales", "j1", "j1", "done"}
+	if !slices.Equal(result.Path, want) {
+		t.Fatalf("path = %v, want %v", result.Path, want)
+	}
+	if result.Steps[1].Branch != "email" || result.Steps[2].Branch != "sales" || result.Steps[5].Branch != "" {
+		t.Errorf("unexpected branch attribution %+v", result.Steps)
+	}
+	if result.TotalDelaySeconds != 2*3600 {
+		t.Errorf("total delay = %v, want the longest branch", result.TotalDelaySeconds)
+	}
+
+	result = Simulate(context.Background(), graph("any"), SimulationOptions{})
+	want = []string{"t1", "email", "sales", "j1", "done"}
+	if result.Status != "COMPLETED" || !slices.Equal(result.Path, want) {
+		t.Errorf("any: got %s via %v, want %v", result.Status, result.Path, want)
+	}
+}
+
+func TestFindNextNodes(t *testing.T) {
+	graph := workflows.Graph{
+		Edges: []workflows.Edge{
+			{Source: "a", Target: "b"},
+			{Source: "a", Target: "c"},
+			{Source: "a", Target: "c"},
+			{Source: "cond", Target: "x", Handle: "true"},
+			{Source: "cond", Target: "y", Handle: "true"},
+			{Source: "cond", Target: "z", Handle: "false"},
+		},
+	}
+	if got := findNextNodes(graph, "a", "default"); !slices.Equal(got, []string{"b", "c"}) {
+		t.Errorf("default handle: got %v", got)
+	}
+	if got := findNextNodes(graph, "cond", "true"); !slices.Equal(got, []string{"x", "y"}) {
+		t.Errorf("true handle: got %v", got)
+	}
+	if got := findNextNodes(graph, "cond", "maybe"); len(got) != 0 {
+		t.Errorf("unknown handle: got %v", got)
+	}
+}
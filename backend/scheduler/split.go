@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// splitKey identifies who a SPLIT node assigns: the person, so they get the same
+// variant whenever they re-enter, or the execution when there is no subject
+func splitKey(exec ScheduledExecution, nodeID string) string {
+	if exec.SubjectID.Valid {
+		return fmt.Sprintf("%d:%s:person:%d", exec.WorkflowID, nodeID, exec.SubjectID.Int64)
+	}
+	return fmt.Sprintf("%d:%s:execution:%d", exec.WorkflowID, nodeID, exec.ID)
+}
+
+// assignVariant sends the execution down the variant its subject hashes to and
+// records the assignment, so outcomes can be compared per variant. The variant
+// is also added to the context under the node ID.
+func assignVariant(ctx context.Context, exec ScheduledExecution, node *workflows.Node) nodeOutcome {
+	split, err := workflows.ParseSplitNode(node.Properties)
+	if err != nil {
+		return nodeOutcome{Status: "failed", Output: "Invalid split node", Err: err}
+	}
+	variant := split.Assign(splitKey(exec, node.ID))
+
+	if !exec.DryRun {
+		var subjectID interface{}
+		if exec.SubjectID.Valid {
+			subjectID = exec.SubjectID.Int64
+		}
+		_, err := db.GetDB().ExecContext(ctx, `
+			INSERT INTO workflow_split_assignments (execution_id, workflow_id, node_id, subject_id, variant)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (execution_id, node_id) DO UPDATE SET variant = EXCLUDED.variant, assigned_at = NOW()
+		`, exec.ID, exec.WorkflowID, node.ID, subjectID, variant)
+		if err != nil {
+			return nodeOutcome{Status: "failed", Output: "Failed to record split assignment", Err: fmt.Errorf("failed to record split assignment: %w", err)}
+		}
+	}
+
+	return nodeOutcome{
+		Status: "success",
+		Output: "Assigned to variant " + variant,
+		Handle: variant,
+		Data:   map[string]interface{}{"variant": variant},
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"reflect"
+	"fmt"
+	"math/rand"
+	"os"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/wesuuu/helpnow/backend/db"
@@ -17,58 +21,282 @@ import (
 type ScheduledExecution struct {
 	ID            int
 	WorkflowID    int
+	SubjectID     sql.NullInt64  // Person the execution runs for, if any
+	OrgID         sql.NullInt64  // Organization owning the workflow
 	CurrentNodeID sql.NullString // Replaces CurrentStep
-	GraphJSON     string         // Replaces StepsJSON
+	GraphJSON     string         // Graph of the pinned workflow version
 	ResultJSON    sql.NullString
 	HasFailed     bool
 	Context       sql.NullString
+	Attempt       int            // Attempts already made on the current node
+	ResumeHandle  sql.NullString // Handle to follow when resuming a parked node
+	ForkID        sql.NullInt64  // Execution that started this one as a parallel branch
+	Branch        sql.NullString // Label of the branch this execution runs
+	ParentID      sql.NullInt64  // Execution whose Run Workflow node started this one
+	Depth         int            // Levels of Run Workflow above this execution
+	DryRun        bool           // Simulation: actions are mocked and nothing is persisted
 }
 
-type StepResult struct {
-	NodeID string `json:"node_id"`
-	Status string `json:"status"` // "success", "failed", "skipped"
-	Output string `json:"output"`
+type StepResult = workflows.StepResult
+
+// leaseDuration is how long a claimed execution stays locked to this worker.
+// If the worker crashes, another replica reclaims the execution once it expires.
+const leaseDuration = 5 * time.Minute
+
+// stepTimeout bounds a single node execution; it must stay below leaseDuration so a
+// slow action cannot outlive its lease and be picked up by another replica.
+const stepTimeout = 4 * time.Minute
+
+// workerID identifies this process in workflow_executions.locked_by
+var workerID = newWorkerID()
+
+func newWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "worker"
+	}
+	return fmt.Sprintf("%s-%d-%04x", host, os.Getpid(), rand.Intn(0x10000))
+}
+
+// WorkerConfig controls the size and fairness of the execution pool
+type WorkerConfig struct {
+	Concurrency  int           // Number of goroutines executing nodes
+	OrgLimit     int           // Max executions in flight per organization, across all replicas
+	PollInterval time.Duration // How often to look for due executions when idle
+}
+
+// WorkerConfigFromEnv reads WORKER_CONCURRENCY, WORKER_ORG_LIMIT and WORKER_POLL_SECONDS
+func WorkerConfigFromEnv() WorkerConfig {
+	cfg := WorkerConfig{
+		Concurrency:  8,
+		OrgLimit:     4,
+		PollInterval: 5 * time.Second,
+	}
+	if n, err := strconv.Atoi(os.Getenv("WORKER_CONCURRENCY")); err == nil && n > 0 {
+		cfg.Concurrency = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("WORKER_ORG_LIMIT")); err == nil && n > 0 {
+		cfg.OrgLimit = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("WORKER_POLL_SECONDS")); err == nil && n > 0 {
+		cfg.PollInterval = time.Duration(n) * time.Second
+	}
+	if cfg.OrgLimit > cfg.Concurrency {
+		cfg.OrgLimit = cfg.Concurrency
+	}
+	return cfg
+}
+
+// Worker claims due executions and runs them on a bounded pool of goroutines
+type Worker struct {
+	cfg      WorkerConfig
+	jobs     chan ScheduledExecution
+	wake     chan struct{}
+	inflight int64
+
+	// pollCtx stops claiming new work; execCtx aborts steps that are still running
+	pollCtx    context.Context
+	stopPoll   context.CancelFunc
+	execCtx    context.Context
+	cancelExec context.CancelFunc
+
+	wg sync.WaitGroup
 }
 
+func NewWorker(cfg WorkerConfig) *Worker {
+	w := &Worker{
+		cfg:  cfg,
+		jobs: make(chan ScheduledExecution, cfg.Concurrency),
+		wake: make(chan struct{}, 1),
+	}
+	w.pollCtx, w.stopPoll = context.WithCancel(context.Background())
+	w.execCtx, w.cancelExec = context.WithCancel(context.Background())
+	return w
+}
+
+var worker *Worker
+
 func StartWorker() {
+	worker = NewWorker(WorkerConfigFromEnv())
+	worker.Start()
+}
+
+func (w *Worker) Start() {
+	for i := 0; i < w.cfg.Concurrency; i++ {
+		w.wg.Add(1)
+		go w.run()
+	}
+	go w.poll()
+	Logger.Infof("[Worker] Started %s with %d goroutines (org limit %d)", workerID, w.cfg.Concurrency, w.cfg.OrgLimit)
+}
 
+// Stop stops claiming executions and waits for in-flight ones to finish. If ctx
+// expires first, running steps are cancelled and their leases released.
+func (w *Worker) Stop(ctx context.Context) error {
+	w.stopPoll()
+
+	done := make(chan struct{})
 	go func() {
-		ticker := time.NewTicker(5 * time.Second)
-		for range ticker.C {
-			processPendingExecutions()
-		}
+		w.wg.Wait()
+		close(done)
 	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		w.cancelExec()
+		<-done
+		return ctx.Err()
+	}
 }
 
-func processPendingExecutions() {
-	// Query for executions that are PENDING and due
-	rows, err := db.GetDB().Query(`
-		SELECT we.id, we.workflow_id, we.current_node_id, w.steps, we.step_results, we.has_failed, we.context 
-		FROM workflow_executions we
-		JOIN workflows w ON we.workflow_id = w.id
-		WHERE we.status = 'PENDING' AND we.next_run_at <= NOW()
-	`)
+func (w *Worker) run() {
+	defer w.wg.Done()
+	for exec := range w.jobs {
+		processSingleExecution(w.execCtx, exec)
+		atomic.AddInt64(&w.inflight, -1)
+
+		// A slot freed up, look for more work without waiting for the ticker
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *Worker) poll() {
+	defer close(w.jobs)
+
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.pollCtx.Done():
+			return
+		case <-ticker.C:
+			recoverAbandonedLeases()
+			expireApprovals()
+			expireEventWaits()
+		case <-w.wake:
+		}
+		w.dispatch()
+	}
+}
+
+// dispatch claims as many executions as there are free goroutines
+func (w *Worker) dispatch() {
+	free := w.cfg.Concurrency - int(atomic.LoadInt64(&w.inflight))
+	if free <= 0 {
+		return
+	}
+
+	executions, err := claimExecutions(free, w.cfg.OrgLimit)
 	if err != nil {
-		Logger.Error("Scheduler error querying executions:", err)
+		Logger.Error("Scheduler error claiming executions:", err)
 		return
 	}
+
+	for _, exec := range executions {
+		atomic.AddInt64(&w.inflight, 1)
+		w.jobs <- exec
+	}
+}
+
+// claimExecutions atomically leases due PENDING executions to this worker.
+// FOR UPDATE SKIP LOCKED lets replicas claim disjoint batches without blocking;
+// rows whose lease has expired are treated as unclaimed. Executions are taken by
+// priority, and an organization never holds more than orgLimit live leases so a
+// large audience cannot starve everyone else. Each execution runs the graph of the
+// workflow version it was pinned to when it started.
+func claimExecutions(limit int, orgLimit int) ([]ScheduledExecution, error) {
+	rows, err := db.GetDB().Query(`
+		WITH active AS (
+			SELECT COALESCE(w.organization_id, 0) AS org_id, COUNT(*) AS n
+			FROM workflow_executions we
+			JOIN workflows w ON we.workflow_id = w.id
+			WHERE we.status = 'PENDING' AND we.lease_expires_at > NOW()
+			GROUP BY 1
+		),
+		ranked AS (
+			SELECT we.id, COALESCE(a.n, 0) + ROW_NUMBER() OVER (
+				PARTITION BY COALESCE(w.organization_id, 0)
+				ORDER BY we.priority DESC, we.next_run_at
+			) AS slot
+			FROM workflow_executions we
+			JOIN workflows w ON we.workflow_id = w.id
+			LEFT JOIN active a ON a.org_id = COALESCE(w.organization_id, 0)
+			WHERE we.status = 'PENDING' AND we.next_run_at <= NOW()
+			AND (we.lease_expires_at IS NULL OR we.lease_expires_at < NOW())
+		),
+		candidates AS (
+			SELECT we.id FROM workflow_executions we
+			JOIN ranked r ON r.id = we.id
+			WHERE r.slot <= $4
+			AND we.status = 'PENDING' AND (we.lease_expires_at IS NULL OR we.lease_expires_at < NOW())
+			ORDER BY we.priority DESC, we.next_run_at
+			LIMIT $3
+			FOR UPDATE OF we SKIP LOCKED
+		),
+		claimed AS (
+			UPDATE workflow_executions
+			SET locked_by = $1, lease_expires_at = NOW() + make_interval(secs => $2)
+			WHERE id IN (SELECT id FROM candidates)
+			RETURNING id, workflow_id, version_id, subject_id, current_node_id, step_results, has_failed, context, attempt, resume_handle, priority, fork_id, branch, parent_execution_id, depth
+		)
+		SELECT c.id, c.workflow_id, c.subject_id, w.organization_id, c.current_node_id, COALESCE(v.steps, pv.steps, w.steps), c.step_results, c.has_failed, c.context, c.attempt, c.resume_handle, c.fork_id, c.branch, c.parent_execution_id, COALESCE(c.depth, 0)
+		FROM claimed c
+		JOIN workflows w ON c.workflow_id = w.id
+		LEFT JOIN workflow_versions v ON v.id = c.version_id
+		LEFT JOIN workflow_versions pv ON pv.id = w.published_version_id
+		ORDER BY c.priority DESC
+	`, workerID, leaseDuration.Seconds(), limit, orgLimit)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
+	executions := []ScheduledExecution{}
 	for rows.Next() {
 		var exec ScheduledExecution
-		if err := rows.Scan(&exec.ID, &exec.WorkflowID, &exec.CurrentNodeID, &exec.GraphJSON, &exec.ResultJSON, &exec.HasFailed, &exec.Context); err != nil {
+		if err := rows.Scan(&exec.ID, &exec.WorkflowID, &exec.SubjectID, &exec.OrgID, &exec.CurrentNodeID, &exec.GraphJSON, &exec.ResultJSON, &exec.HasFailed, &exec.Context, &exec.Attempt, &exec.ResumeHandle, &exec.ForkID, &exec.Branch, &exec.ParentID, &exec.Depth); err != nil {
 			Logger.Error("Scheduler scan error:", err)
 			continue
 		}
-		processSingleExecution(exec)
+		executions = append(executions, exec)
 	}
+	return executions, rows.Err()
 }
 
-func processSingleExecution(exec ScheduledExecution) {
+// recoverAbandonedLeases releases executions whose worker died mid-run so they are
+// picked up again on the next claim.
+func recoverAbandonedLeases() {
+	rows, err := db.GetDB().Query(`
+		UPDATE workflow_executions
+		SET locked_by = NULL, lease_expires_at = NULL
+		WHERE status = 'PENDING' AND lease_expires_at < NOW()
+		RETURNING id
+	`)
+	if err != nil {
+		Logger.Error("Failed to recover abandoned leases:", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			Logger.Warnf("[Worker] Recovered abandoned lease on execution %d", id)
+		}
+	}
+}
+
+func processSingleExecution(ctx context.Context, exec ScheduledExecution) {
 	var graph workflows.Graph
 	if err := json.Unmarshal([]byte(exec.GraphJSON), &graph); err != nil {
 		Logger.Errorf("[Worker] Failed to unmarshal graph for execution %d: %v", exec.ID, err)
-		markExecutionFinal(exec.ID, "FAILED", "Invalid graph JSON")
+		finishExecution(exec, "FAILED", "Invalid graph JSON")
 		return
 	}
 
@@ -77,46 +305,23 @@ func processSingleExecution(exec ScheduledExecution) {
 		currentNodeID = exec.CurrentNodeID.String
 	} else {
 		// New execution: Find Start Node (Trigger)
-		for _, n := range graph.Nodes {
-			if n.Type == string(models.NodeTypeTrigger) {
-				currentNodeID = n.ID
-				break
-			}
-		}
-		if currentNodeID == "" && len(graph.Nodes) > 0 {
-			// Fallback: take first node
-			currentNodeID = graph.Nodes[0].ID
-		}
+		currentNodeID = findStartNode(graph)
 	}
 
 	if currentNodeID == "" {
 		Logger.Errorf("[Worker] No start node found for execution %d", exec.ID)
-		markExecutionFinal(exec.ID, "FAILED", "No start node found")
+		finishExecution(exec, "FAILED", "No start node found")
 		return
 	}
 
 	// Find the Node Object
-	var node *workflows.Node
-	for i, n := range graph.Nodes {
-		if n.ID == currentNodeID {
-			node = &graph.Nodes[i]
-			break
-		}
-	}
-
+	node := findNode(graph, currentNodeID)
 	if node == nil {
 		Logger.Errorf("[Worker] Node %s not found in graph", currentNodeID)
-		markExecutionFinal(exec.ID, "FAILED", "Node not found")
+		finishExecution(exec, "FAILED", "Node not found")
 		return
 	}
 
-	Logger.Infof("[Worker] Executing Node: %s (%s)", node.Label, node.Type)
-
-	// --- EXECUTE NODE LOGIC ---
-	output := ""
-	status := "success"
-	handleToFollow := "default" // Used for branching
-
 	// Prepare Context
 	var ctxData map[string]interface{}
 	if exec.Context.Valid {
@@ -126,149 +331,140 @@ func processSingleExecution(exec ScheduledExecution) {
 		ctxData = make(map[string]interface{})
 	}
 
-	switch models.NodeType(node.Type) {
-	case models.NodeTypeTrigger:
-		output = "Triggered"
-		handleToFollow = "default"
+	// --- EXECUTE NODE LOGIC ---
+	var outcome nodeOutcome
+	if exec.ResumeHandle.Valid && exec.ResumeHandle.String != "" {
+		// Resumed after a pause (e.g. an approval decision): the node already ran,
+		// only its outgoing handle is left to follow
+		Logger.Infof("[Worker] Resuming Node: %s via %s", node.Label, exec.ResumeHandle.String)
+		outcome = nodeOutcome{
+			Status: "success",
+			Output: "Resumed via " + exec.ResumeHandle.String,
+			Handle: exec.ResumeHandle.String,
+		}
+	} else if models.NodeType(node.Type) == models.NodeTypeJoin && exec.ForkID.Valid {
+		// A parallel branch reached a join: it ends here and the execution that
+		// forked it continues once the join's condition is met
+		Logger.Infof("[Worker] Branch %s arrived at join %s", exec.Branch.String, node.Label)
+		arriveAtJoin(exec, node)
+		return
+	} else {
+		Logger.Infof("[Worker] Executing Node: %s (%s)", node.Label, node.Type)
+		outcome = executeNode(ctx, exec, node, ctxData)
+	}
 
-	case models.NodeTypeAction:
-		actionType, _ := node.Properties["action"].(string)
-		output = "Executed " + actionType
+	if outcome.Err != nil && ctx.Err() != nil {
+		// Worker is shutting down: hand the execution back instead of failing it
+		Logger.Warnf("[Worker] Execution %d interrupted by shutdown, releasing lease", exec.ID)
+		releaseLease(exec.ID)
+		return
+	}
 
-		// Look up action template
-		if actionTemplate, ok := workflows.GetAction(actionType); ok {
-			// Create a new instance of the action (to avoid shared state)
-			action := reflect.New(reflect.TypeOf(actionTemplate).Elem()).Interface().(workflows.Action)
+	status := outcome.Status
+	handleToFollow := outcome.Handle
+	stepErr := outcome.Err
+	if status == "failed" {
+		exec.HasFailed = true
+	}
 
-			// Unmarshal node properties into the action struct
-			if propBytes, err := json.Marshal(node.Properties); err == nil {
-				if err := json.Unmarshal(propBytes, action); err != nil {
-					Logger.Warnf("[Worker] Failed to unmarshal action properties: %v", err)
-				}
-			}
+	result := StepResult{
+		NodeID: currentNodeID,
+		Status: status,
+		Output: outcome.Output,
+		Branch: exec.Branch.String,
+	}
+	if exec.Attempt > 0 {
+		result.Attempt = exec.Attempt + 1
+	}
+	if stepErr != nil {
+		result.Error = stepErr.Error()
+	}
+	if status == "deferred" && outcome.Park != nil {
+		result.DeferredUntil = outcome.Park.WakeAt
+	}
 
-			// Execute with only context data
-			out, err := action.Execute(context.Background(), ctxData)
-			output = out
-			if err != nil {
-				status = "failed"
-				exec.HasFailed = true
-				Logger.Warnf("[Worker] Action %s failed: %v", actionType, err)
-			}
-		} else {
-			Logger.Warnf("[Worker] Unknown action type: %s", actionType)
-			output = "Unknown action type"
-			status = "failed"
-			exec.HasFailed = true
+	// Retry failed actions according to the node's retry policy
+	if stepErr != nil && models.NodeType(node.Type) == models.NodeTypeAction {
+		policy, err := workflows.ParseRetryPolicy(node.Properties)
+		if err != nil {
+			Logger.Errorf("[Worker] Invalid retry policy on node %s of execution %d: %v", currentNodeID, exec.ID, err)
+			result.Error = fmt.Sprintf("%v (not retried: %v)", stepErr, err)
+			recordStepResult(exec.ID, exec.ResultJSON, result, exec.HasFailed)
+			finishExecution(exec, "FAILED", "Invalid retry policy: "+err.Error())
+			return
 		}
-		// ALWAYS follow default for Action
-		handleToFollow = "default"
-
-	case models.NodeTypeCondition:
-		// Logic Support
-		logicType := "Condition" // Hardcoded for now, or property?
-
-		if logicTemplate, ok := workflows.GetLogic(logicType); ok {
-			// Create a new instance of the logic
-			logic := reflect.New(reflect.TypeOf(logicTemplate).Elem()).Interface().(workflows.Logic)
-
-			// Unmarshal node properties into the logic struct
-			if propBytes, err := json.Marshal(node.Properties); err == nil {
-				if err := json.Unmarshal(propBytes, logic); err != nil {
-					Logger.Warnf("[Worker] Failed to unmarshal logic properties: %v", err)
-				}
+		if policy != nil {
+			attempt := exec.Attempt + 1
+			result.Attempt = attempt
+
+			if policy.ShouldRetry(attempt, stepErr) {
+				retryAt := time.Now().Add(policy.Backoff(attempt))
+				result.Status = "retrying"
+				Logger.Infof("[Worker] Retrying node %s of execution %d at %s (attempt %d/%d)", currentNodeID, exec.ID, retryAt.Format(time.RFC3339), attempt, policy.MaxAttempts)
+				recordStepResult(exec.ID, exec.ResultJSON, result, exec.HasFailed)
+				scheduleRetry(exec.ID, attempt, retryAt)
+				return
 			}
 
-			// Evaluate with only context data
-			res, out, err := logic.Evaluate(context.Background(), ctxData)
-			if err != nil {
-				Logger.Warnf("[Worker] Logic failed: %v", err)
-				status = "failed"
-				exec.HasFailed = true
-			}
-			output = out
-			if res {
-				handleToFollow = "true"
-			} else {
-				handleToFollow = "false"
-			}
-		} else {
-			// Fallback (shouldn't happen if registered)
-			output = "Logic type not found"
-			status = "failed"
+			recordStepResult(exec.ID, exec.ResultJSON, result, exec.HasFailed)
+			finishExecution(exec, string(models.StatusDeadLetter), deadLetterReason(policy, attempt, stepErr))
+			return
 		}
 	}
 
 	// Record Result
-	recordStepResult(exec.ID, exec.ResultJSON, StepResult{
-		NodeID: currentNodeID,
-		Status: status,
-		Output: output,
-	}, exec.HasFailed)
+	recordStepResult(exec.ID, exec.ResultJSON, result, exec.HasFailed)
 
 	if status == "failed" {
-		markExecutionFinal(exec.ID, "FAILED", "Node execution failed")
+		finishExecution(exec, "FAILED", "Node execution failed")
 		return
 	}
 
-	// --- FIND NEXT NODE ---
-	var nextNodeID string
-
-	// First try specific handle
-	for _, edge := range graph.Edges {
-		if edge.Source == currentNodeID && edge.Handle == handleToFollow {
-			nextNodeID = edge.Target
-			break
+	// Make structured output available to later nodes
+	if outcome.Data != nil {
+		ctxData[currentNodeID] = outcome.Data
+		if err := saveExecutionContext(exec.ID, ctxData); err != nil {
+			Logger.Errorf("[Worker] Failed to save context for execution %d: %v", exec.ID, err)
 		}
 	}
 
-	// Fallback to default if no specific handle edge found (for actions)
-	if nextNodeID == "" && handleToFollow == "default" {
-		for _, edge := range graph.Edges {
-			if edge.Source == currentNodeID {
-				nextNodeID = edge.Target
-				break
-			}
+	// The node asked to wait (approval, ...) rather than advance
+	if outcome.Park != nil {
+		parkExecution(exec.ID, outcome.Park)
+		if outcome.Park.Status == string(models.StatusWaitingForChild) {
+			resumeIfChildDone(exec.ID, currentNodeID)
 		}
+		return
 	}
 
-	if nextNodeID != "" {
-		// Find next node object to check for delays
-		var nextNode *workflows.Node
-		for i, n := range graph.Nodes {
-			if n.ID == nextNodeID {
-				nextNode = &graph.Nodes[i]
-				break
+	// A loop runs its body once per item, each iteration as a branch
+	if outcome.Items != nil {
+		if targets := findNextNodes(graph, currentNodeID, workflows.HandleItem); len(targets) > 0 {
+			Logger.Infof("[Worker] Execution %d looping over %d items at %s", exec.ID, len(outcome.Items), currentNodeID)
+			if err := startLoop(exec, graph, currentNodeID, targets, outcome.Items, ctxData); err != nil {
+				Logger.Errorf("[Worker] Failed to start loop of execution %d: %v", exec.ID, err)
+				finishExecution(exec, "FAILED", "Failed to start loop iterations")
 			}
+			return
 		}
+		handleToFollow = workflows.HandleDone
+	}
 
+	// --- FIND NEXT NODE ---
+	nextNodeIDs := findNextNodes(graph, currentNodeID, handleToFollow)
+
+	if len(nextNodeIDs) > 1 {
+		// Several edges: run each target as a parallel branch
+		Logger.Infof("[Worker] Execution %d forking into %d branches at %s", exec.ID, len(nextNodeIDs), currentNodeID)
+		if err := forkExecution(exec, graph, nextNodeIDs); err != nil {
+			Logger.Errorf("[Worker] Failed to fork execution %d: %v", exec.ID, err)
+			finishExecution(exec, "FAILED", "Failed to start parallel branches")
+		}
+	} else if len(nextNodeIDs) == 1 {
+		nextNodeID := nextNodeIDs[0]
 		delayDuration := time.Duration(0)
-		if nextNode != nil {
-			getFloat := func(key string) float64 {
-				if val, ok := nextNode.Properties[key]; ok {
-					switch v := val.(type) {
-					case float64:
-						return v
-					case int:
-						return float64(v)
-					case string:
-						if f, err := strconv.ParseFloat(v, 64); err == nil {
-							return f
-						}
-					}
-				}
-				return 0
-			}
-
-			days := getFloat("delay_days")
-			hours := getFloat("delay_hours")
-
-			if days > 0 {
-				delayDuration += time.Duration(days) * 24 * time.Hour
-			}
-			if hours > 0 {
-				delayDuration += time.Duration(hours) * time.Hour
-			}
+		if nextNode := findNode(graph, nextNodeID); nextNode != nil {
+			delayDuration = nodeDelay(nextNode)
 		}
 
 		// Schedule next node
@@ -278,7 +474,7 @@ func processSingleExecution(exec ScheduledExecution) {
 		updateExecutionNode(exec.ID, nextNodeID, nextRunAt)
 	} else {
 		// End of flow
-		markExecutionFinal(exec.ID, "COMPLETED", "")
+		finishExecution(exec, "COMPLETED", "")
 	}
 }
 
@@ -294,30 +490,107 @@ func recordStepResult(executionID int, currentJSON sql.NullString, result StepRe
 	_, err := db.GetDB().Exec(`
 		UPDATE workflow_executions 
 		SET step_results = $1, has_failed = $2
-		WHERE id = $3
-	`, string(jsonBytes), hasFailed, executionID)
+		WHERE id = $3 AND locked_by = $4
+	`, string(jsonBytes), hasFailed, executionID, workerID)
 
 	return err
 }
 
+// saveExecutionContext persists the execution context after a node added to it
+func saveExecutionContext(executionID int, ctxData map[string]interface{}) error {
+	contextJSON, err := json.Marshal(ctxData)
+	if err != nil {
+		return err
+	}
+	_, err = db.GetDB().Exec(`
+		UPDATE workflow_executions
+		SET context = $1
+		WHERE id = $2 AND locked_by = $3
+	`, string(contextJSON), executionID, workerID)
+	return err
+}
+
 func updateExecutionNode(executionID int, nextNodeID string, nextRunAt time.Time) {
 	_, err := db.GetDB().Exec(`
 		UPDATE workflow_executions 
-		SET current_node_id = $1, next_run_at = $2 
-		WHERE id = $3
-	`, nextNodeID, nextRunAt, executionID)
+		SET current_node_id = $1, next_run_at = $2, attempt = 0, resume_handle = NULL, locked_by = NULL, lease_expires_at = NULL
+		WHERE id = $3 AND locked_by = $4
+	`, nextNodeID, nextRunAt, executionID, workerID)
 	if err != nil {
 		Logger.Error("Failed to update execution node:", err)
 	}
 }
 
-func markExecutionFinal(executionID int, status string, resultReason string) {
+// deadLetterReason explains why a node under a retry policy gave up
+func deadLetterReason(policy *workflows.RetryPolicy, attempts int, err error) string {
+	if attempts >= policy.MaxAttempts {
+		return fmt.Sprintf("Retries exhausted after %d attempts: %v", attempts, err)
+	}
+	return fmt.Sprintf("Not retried on attempt %d, retry_on does not cover the error: %v", attempts, err)
+}
+
+// parkExecution takes an execution out of the queue until it is resumed. If
+// WakeAt is set, the wait times out at that time. A pause in PENDING sets the
+// handle the execution follows when it wakes. An event wait or approval is
+// created in the same transaction, so it exists exactly when the execution is parked.
+func parkExecution(executionID int, park *parkRequest) {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		Logger.Error("Failed to park execution:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE workflow_executions
+		SET status = $2, next_run_at = $3, attempt = 0, resume_handle = NULLIF($5, ''), locked_by = NULL, lease_expires_at = NULL
+		WHERE id = $1 AND locked_by = $4
+	`, executionID, park.Status, park.WakeAt, workerID, park.Handle)
+	if err != nil {
+		Logger.Error("Failed to park execution:", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// Lease lost: whoever holds the execution now decides what it waits for
+		return
+	}
+	if park.EventWait != nil {
+		if err := registerEventWait(tx, executionID, park.EventWait); err != nil {
+			Logger.Error("Failed to park execution:", err)
+			return
+		}
+	}
+	if park.Approval != nil {
+		if err := openApproval(tx, executionID, park.Approval); err != nil {
+			Logger.Error("Failed to park execution:", err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		Logger.Error("Failed to park execution:", err)
+	}
+}
+
+// scheduleRetry re-runs the current node at retryAt
+func scheduleRetry(executionID int, attempt int, retryAt time.Time) {
 	_, err := db.GetDB().Exec(`
-		UPDATE workflow_executions 
-		SET status = $2, result = $3, finished_at = NOW() 
-		WHERE id = $1
-	`, executionID, status, resultReason)
+		UPDATE workflow_executions
+		SET attempt = $2, next_run_at = $3, locked_by = NULL, lease_expires_at = NULL
+		WHERE id = $1 AND locked_by = $4
+	`, executionID, attempt, retryAt, workerID)
+	if err != nil {
+		Logger.Error("Failed to schedule retry:", err)
+	}
+}
+
+// releaseLease hands an execution back to the pool without changing its state
+func releaseLease(executionID int) {
+	_, err := db.GetDB().Exec(`
+		UPDATE workflow_executions
+		SET locked_by = NULL, lease_expires_at = NULL
+		WHERE id = $1 AND locked_by = $2
+	`, executionID, workerID)
 	if err != nil {
-		Logger.Error("Failed to mark execution final:", err)
+		Logger.Error("Failed to release lease:", err)
 	}
 }
@@ -3,11 +3,17 @@ package scheduler
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/db/dbtest"
 	"github.com/wesuuu/helpnow/backend/workflows"
+	_ "github.com/wesuuu/helpnow/backend/workflows/actions"
+	"github.com/wesuuu/helpnow/backend/workflows/logic"
 )
 
 // Mock Action for testing
@@ -402,3 +408,238 @@ func TestActionContextCreation(t *testing.T) {
 		t.Error("Node data not properly set")
 	}
 }
+
+func TestWorkerConfigFromEnv(t *testing.T) {
+	t.Setenv("WORKER_CONCURRENCY", "3")
+	t.Setenv("WORKER_ORG_LIMIT", "10")
+	t.Setenv("WORKER_POLL_SECONDS", "")
+
+	cfg := WorkerConfigFromEnv()
+
+	if cfg.Concurrency != 3 {
+		t.Errorf("Expected Concurrency=3, got %d", cfg.Concurrency)
+	}
+	// Org limit can never exceed the pool size
+	if cfg.OrgLimit != 3 {
+		t.Errorf("Expected OrgLimit capped to 3, got %d", cfg.OrgLimit)
+	}
+	if cfg.PollInterval != 5*time.Second {
+		t.Errorf("Expected default PollInterval=5s, got %v", cfg.PollInterval)
+	}
+}
+
+// Mock action returning structured output
+type MockStructuredAction struct{}
+
+func (a *MockStructuredAction) Execute(ctx context.Context, contextData map[string]interface{}) (string, error) {
+	return "plain", nil
+}
+
+func (a *MockStructuredAction) ExecuteStructured(ctx context.Context, contextData map[string]interface{}) (string, map[string]interface{}, error) {
+	return "structured", map[string]interface{}{"status": 200.0}, nil
+}
+
+func TestExecuteActionStructuredOutput(t *testing.T) {
+	workflows.RegisterAction("StructuredTestAction", &MockStructuredAction{})
+
+	node := &workflows.Node{ID: "n-1", Type: "ACTION", Properties: map[string]interface{}{"action": "StructuredTestAction"}}
+	outcome := executeAction(context.Background(), ScheduledExecution{ID: 1}, node, map[string]interface{}{})
+
+	if outcome.Status != "success" || outcome.Output != "structured" {
+		t.Fatalf("Expected structured execution, got %+v", outcome)
+	}
+	if outcome.Data["status"] != 200.0 {
+		t.Errorf("Expected data to be returned, got %v", outcome.Data)
+	}
+}
+
+// MockAwaiterAction starts nothing but asks to wait when Wait is set
+type MockAwaiterAction struct {
+	Wait bool `json:"wait"`
+}
+
+func (a *MockAwaiterAction) Execute(ctx context.Context, contextData map[string]interface{}) (string, error) {
+	return "started", nil
+}
+
+func (a *MockAwaiterAction) AwaitResult() bool { return a.Wait }
+
+func TestExecuteActionAwaiterParks(t *testing.T) {
+	workflows.RegisterAction("AwaiterTestAction", &MockAwaiterAction{})
+
+	waiting := &workflows.Node{ID: "n-1", Type: "ACTION", Properties: map[string]interface{}{"action": "AwaiterTestAction", "wait": true}}
+	outcome := executeAction(context.Background(), ScheduledExecution{ID: 1}, waiting, map[string]interface{}{})
+	if outcome.Park == nil || outcome.Park.Status != "WAITING_FOR_CHILD" {
+		t.Errorf("Expected the execution to park for the child, got %+v", outcome)
+	}
+
+	outcome = executeAction(context.Background(), ScheduledExecution{ID: 1, DryRun: true}, waiting, map[string]interface{}{})
+	if outcome.Park != nil {
+		t.Error("Dry runs must not park for a child")
+	}
+
+	noWait := &workflows.Node{ID: "n-2", Type: "ACTION", Properties: map[string]interface{}{"action": "AwaiterTestAction"}}
+	if outcome := executeAction(context.Background(), ScheduledExecution{ID: 1}, noWait, map[string]interface{}{}); outcome.Park != nil {
+		t.Errorf("Expected no wait without wait set, got %+v", outcome.Park)
+	}
+}
+
+func TestWaitForEventRegistersOnPark(t *testing.T) {
+	node := &workflows.Node{ID: "w-1", Type: "WAIT_FOR_EVENT", Properties: map[string]interface{}{
+		"event": "purchase", "filter": map[string]interface{}{"plan": "pro"}, "timeout_hours": 2,
+	}}
+	exec := ScheduledExecution{ID: 1, WorkflowID: 7, SubjectID: sql.NullInt64{Int64: 42, Valid: true}}
+
+	outcome := waitForEvent(context.Background(), exec, node)
+	if outcome.Park == nil || outcome.Park.Status != "WAITING_FOR_EVENT" {
+		t.Fatalf("Expected the execution to park for the event, got %+v", outcome)
+	}
+	wait := outcome.Park.EventWait
+	if wait == nil {
+		t.Fatal("Expected the wait to be registered with the park")
+	}
+	if wait.NodeID != "w-1" || wait.SubjectID != 42 || wait.Event != "purchase" || wait.Filter["plan"] != "pro" {
+		t.Errorf("Unexpected wait %+v", wait)
+	}
+	if wait.DueAt == nil || wait.DueAt != outcome.Park.WakeAt {
+		t.Errorf("Expected the wait to time out when the execution wakes, got %v and %v", wait.DueAt, outcome.Park.WakeAt)
+	}
+
+	exec.DryRun = true
+	if outcome := waitForEvent(context.Background(), exec, node); outcome.Park == nil || outcome.Park.EventWait != nil {
+		t.Errorf("Dry runs must park without registering a wait, got %+v", outcome.Park)
+	}
+}
+
+func TestRunWorkflowDepthLimitAfterFork(t *testing.T) {
+	// A branch forked at the depth limit inherits the depth of its execution
+	branch := ScheduledExecution{
+		ID:     2,
+		ForkID: sql.NullInt64{Int64: 1, Valid: true},
+		Branch: sql.NullString{String: "b", Valid: true},
+		Depth:  5,
+	}
+	node := &workflows.Node{ID: "run", Type: "ACTION", Properties: map[string]interface{}{"action": "Run Workflow", "workflow_id": 3}}
+
+	outcome := executeNode(context.Background(), branch, node, map[string]interface{}{})
+	if outcome.Status != "failed" || outcome.Output != "Sub-workflow depth limit reached" {
+		t.Errorf("Expected the depth limit to stop the branch, got %+v", outcome)
+	}
+}
+
+func TestStopTwice(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		if err := Stop(context.Background()); err != nil {
+			t.Fatalf("Stop %d: unexpected error %v", i+1, err)
+		}
+	}
+}
+
+func TestDeadLetterReason(t *testing.T) {
+	policy := &workflows.RetryPolicy{MaxAttempts: 3, RetryOn: []string{"transient"}}
+	if got := deadLetterReason(policy, 3, errors.New("boom")); got != "Retries exhausted after 3 attempts: boom" {
+		t.Errorf("Unexpected reason for exhausted retries: %s", got)
+	}
+	if got := deadLetterReason(policy, 1, errors.New("bad request")); got != "Not retried on attempt 1, retry_on does not cover the error: bad request" {
+		t.Errorf("Unexpected reason for a non-retryable error: %s", got)
+	}
+}
+
+func TestRequestApprovalOpensOnPark(t *testing.T) {
+	node := &workflows.Node{ID: "ap-1", Type: "APPROVAL", Properties: map[string]interface{}{"assignee_user_id": 4, "timeout_hours": 24}}
+	exec := ScheduledExecution{ID: 1, WorkflowID: 7}
+
+	outcome := requestApproval(context.Background(), exec, node)
+	if outcome.Park == nil || outcome.Park.Status != "WAITING_FOR_HUMAN" {
+		t.Fatalf("Expected the execution to park for a human, got %+v", outcome)
+	}
+	req := outcome.Park.Approval
+	if req == nil {
+		t.Fatal("Expected the approval to be opened with the park")
+	}
+	if req.WorkflowID != 7 || req.NodeID != "ap-1" || req.Approval.AssigneeUserID == nil || *req.Approval.AssigneeUserID != 4 {
+		t.Errorf("Unexpected approval %+v", req)
+	}
+	if req.DueAt == nil || req.DueAt != outcome.Park.WakeAt {
+		t.Errorf("Expected the approval to time out when the execution wakes, got %v and %v", req.DueAt, outcome.Park.WakeAt)
+	}
+
+	exec.DryRun = true
+	if outcome := requestApproval(context.Background(), exec, node); outcome.Park == nil || outcome.Park.Approval != nil {
+		t.Errorf("Dry runs must park without opening an approval, got %+v", outcome.Park)
+	}
+}
+
+func TestConditionSeesSubjectPerson(t *testing.T) {
+	cond := &logic.ConditionLogic{Expression: `person.score > 50 && event.plan == "pro"`}
+	ctxData := map[string]interface{}{"event": map[string]interface{}{"plan": "pro"}}
+	person := map[string]interface{}{"id": float64(9), "score": float64(80)}
+
+	res, out, err := cond.Evaluate(context.Background(), conditionVars(ctxData, person))
+	if err != nil || !res {
+		t.Errorf("Expected the subject-backed condition to pass, got %v (%s, %v)", res, out, err)
+	}
+	if _, ok := ctxData["person"]; ok {
+		t.Error("conditionVars must not modify the execution context")
+	}
+	if vars := conditionVars(ctxData, nil); len(vars) != 1 {
+		t.Errorf("Expected executions without a subject to see only the context, got %v", vars)
+	}
+}
+
+func TestFinishExecutionSettlesInSameTransaction(t *testing.T) {
+	fake := dbtest.Use(t,
+		dbtest.Result{Match: "SET status = $2, result = $3, finished_at = NOW()", Affected: 1},
+		// Another branch of fork 5 is still running, and the branch has no parent
+		dbtest.Result{Match: "FOR UPDATE OF we", Rows: [][]driver.Value{{"WAITING_FOR_BRANCHES", nil, "split-1", "{}", nil, nil, false}}},
+		dbtest.Result{Match: "SELECT status, current_node_id, context, step_results", Rows: [][]driver.Value{{"PENDING", "node-2", nil, nil}}},
+		dbtest.Result{Match: "SELECT parent_execution_id, parent_node_id", Rows: [][]driver.Value{{nil, nil, int64(1), "COMPLETED", "", nil}}},
+	)
+
+	finishExecution(ScheduledExecution{ID: 9, ForkID: sql.NullInt64{Int64: 5, Valid: true}}, "COMPLETED", "")
+
+	ran := fake.AssertOrder(t, "finished_at = NOW()", "FOR UPDATE OF we", "SELECT parent_execution_id")
+	if ran[0].Args[3] != workerID {
+		t.Errorf("Expected the update to be fenced by the lease, got %v", ran[0].Args)
+	}
+	if ran[1].Args[0] != int64(5) {
+		t.Errorf("Expected fork 5 to be settled, got %v", ran[1].Args)
+	}
+	if !fake.Committed {
+		t.Error("Expected the execution to be finished and settled in one commit")
+	}
+}
+
+func TestFinishExecutionLeaseLost(t *testing.T) {
+	fake := dbtest.Use(t)
+
+	finishExecution(ScheduledExecution{ID: 9, ForkID: sql.NullInt64{Int64: 5, Valid: true}}, "COMPLETED", "")
+
+	if fake.Committed || fake.Index("FOR UPDATE OF we", 0) >= 0 {
+		t.Errorf("Expected nothing to be settled once the lease is lost, ran:\n%s", fake)
+	}
+}
+
+func TestCancelBranchesCancelsWaits(t *testing.T) {
+	fake := dbtest.Use(t, dbtest.Result{Match: "WITH RECURSIVE tree", Rows: [][]driver.Value{{int64(4)}, {int64(6)}}})
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := CancelBranches(tx, 2, "Cancelled"); err != nil {
+		t.Fatal(err)
+	}
+
+	ran := fake.AssertOrder(t, "WITH RECURSIVE tree", "UPDATE workflow_approvals", "DELETE FROM workflow_event_waits")
+	if ran[1].Args[0] != "{4,6}" || ran[2].Args[0] != "{4,6}" {
+		t.Errorf("Expected the waits of branches 4 and 6 to be cancelled, got %v and %v", ran[1].Args, ran[2].Args)
+	}
+
+	// Nothing to release when no branch was still running
+	before := len(fake.Statements)
+	if err := CancelWaits(tx, nil); err != nil || len(fake.Statements) != before {
+		t.Errorf("Expected CancelWaits without executions to do nothing, got %v", err)
+	}
+}
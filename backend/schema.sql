@@ -2,6 +2,9 @@ CREATE TABLE IF NOT EXISTS organizations (
     id SERIAL PRIMARY KEY,
     name TEXT NOT NULL,
     system_prompt TEXT,
+    http_allowlist TEXT[] DEFAULT '{}', -- Internal hosts/CIDRs that HTTP Request actions may reach
+    daily_message_cap INTEGER DEFAULT 0, -- Most messages one person may receive in 24 hours; 0 for no cap
+    send_window JSONB, -- Quiet hours outside it, in each person's local time: {"start": "08:00", "end": "20:00"}
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
@@ -75,6 +78,7 @@ CREATE TABLE IF NOT EXISTS people (
     score INTEGER DEFAULT 0,
     event_history JSONB,
     meta JSONB,
+    timezone TEXT, -- IANA name, e.g. Europe/Paris; delays wake in it
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
@@ -154,6 +158,12 @@ CREATE TABLE IF NOT EXISTS workflows (
     -- END DEPRECATED
     steps TEXT NOT NULL, -- JSON array of steps
     status TEXT DEFAULT 'ACTIVE', -- ACTIVE, PAUSED
+    published_version_id INTEGER, -- workflow_versions row new executions start on
+    goal JSONB, -- Exit criterion: {"type": "event", "event": ...} or {"type": "audience", "audience_id": ...}
+    entry_rule JSONB, -- How often one person may enter: {"mode": "once_per_period", "period_days": 7}
+    send_window JSONB, -- Overrides the organization's send window
+    max_duration_hours INTEGER, -- Executions running longer are timed out by the reaper; NULL for the default, 0 for no limit
+    priority INTEGER DEFAULT 0, -- Copied onto each execution; higher runs first when the worker pool is saturated
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     UNIQUE(organization_id, name)
 );
@@ -172,22 +182,150 @@ CREATE TABLE IF NOT EXISTS workflow_triggers (
 CREATE INDEX IF NOT EXISTS idx_workflow_triggers_workflow_id ON workflow_triggers(workflow_id);
 CREATE INDEX IF NOT EXISTS idx_workflow_triggers_type ON workflow_triggers(type);
 
+-- Immutable snapshots of a workflow graph. Every save creates one; executions are
+-- pinned to the version they started on so edits never change in-flight runs.
+CREATE TABLE IF NOT EXISTS workflow_versions (
+    id SERIAL PRIMARY KEY,
+    workflow_id INTEGER REFERENCES workflows(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL, -- 1, 2, 3... per workflow
+    steps TEXT NOT NULL, -- JSON graph, never modified after insert
+    note TEXT, -- Optional description, e.g. "Rollback to version 3"
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    published_at TIMESTAMP WITH TIME ZONE, -- NULL while the version is a draft
+    UNIQUE(workflow_id, version)
+);
+
+-- Stable public tokens for WEBHOOK trigger nodes. Kept apart from workflow_triggers,
+-- which is rebuilt on every workflow update, so a node keeps its URL across edits.
+CREATE TABLE IF NOT EXISTS webhook_tokens (
+    token TEXT PRIMARY KEY,
+    workflow_id INTEGER REFERENCES workflows(id) ON DELETE CASCADE,
+    node_id TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(workflow_id, node_id)
+);
+
+-- Signatures of accepted webhook deliveries, kept for the replay window
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    token TEXT NOT NULL,
+    signature TEXT NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (token, signature)
+);
+
 
 CREATE TABLE IF NOT EXISTS workflow_executions (
     id SERIAL PRIMARY KEY,
     workflow_id INTEGER REFERENCES workflows(id),
+    version_id INTEGER REFERENCES workflow_versions(id), -- Graph version this execution runs; NULL for executions created before versioning
     subject_id INTEGER, -- Optional: Link to a 'people' record if applicable
     current_step INTEGER DEFAULT 0, -- Deprecated in favor of current_node_id for Graph workflows
     current_node_id TEXT, -- ID of the current node in the graph
-    status TEXT NOT NULL, -- PENDING, COMPLETED, FAILED
+    status TEXT NOT NULL, -- PENDING, COMPLETED, FAILED, DEAD_LETTER, WAITING_FOR_HUMAN, WAITING_FOR_EVENT, WAITING_FOR_BRANCHES, WAITING_FOR_CHILD, CANCELLED, EXITED, TIMED_OUT
     next_run_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     context TEXT, -- JSON blob of event data
     step_results TEXT, -- JSON array of results from each step
     has_failed BOOLEAN DEFAULT FALSE, -- Track if any step has failed
+    priority INTEGER DEFAULT 0, -- From the workflow; higher runs first when the worker pool is saturated
+    attempt INTEGER DEFAULT 0, -- Attempts made on the current node (retry policies)
+    result TEXT, -- Final outcome or failure reason
+    resume_handle TEXT, -- Handle to follow out of current_node_id when a parked execution resumes
+    locked_by TEXT, -- Worker currently holding the lease on this execution
+    lease_expires_at TIMESTAMP WITH TIME ZONE, -- Lease is reclaimable after this time
+    fanout_key TEXT, -- Trigger firing that enrolled subject_id; one execution per person per firing
+    fork_id INTEGER REFERENCES workflow_executions(id) ON DELETE CASCADE, -- Execution that started this one as a parallel branch
+    branch TEXT, -- Branch label, from the node the branch started at ("a/b" when nested)
+    parent_execution_id INTEGER REFERENCES workflow_executions(id) ON DELETE SET NULL, -- Execution whose Run Workflow node started this one
+    parent_node_id TEXT, -- That Run Workflow node
+    depth INTEGER DEFAULT 0, -- Levels of Run Workflow above this execution
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     finished_at TIMESTAMP WITH TIME ZONE
 );
 
+CREATE INDEX IF NOT EXISTS idx_workflow_executions_due ON workflow_executions(status, next_run_at);
+CREATE INDEX IF NOT EXISTS idx_workflow_executions_priority ON workflow_executions(priority DESC, next_run_at) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_workflow_executions_fork ON workflow_executions(fork_id) WHERE fork_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_workflow_executions_parent ON workflow_executions(parent_execution_id) WHERE parent_execution_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workflow_executions_fanout ON workflow_executions(workflow_id, subject_id, fanout_key) WHERE fanout_key IS NOT NULL;
+
+-- Human review tasks created by APPROVAL nodes
+CREATE TABLE IF NOT EXISTS workflow_approvals (
+    id SERIAL PRIMARY KEY,
+    execution_id INTEGER REFERENCES workflow_executions(id) ON DELETE CASCADE,
+    workflow_id INTEGER REFERENCES workflows(id) ON DELETE CASCADE,
+    node_id TEXT NOT NULL,
+    assignee_user_id INTEGER REFERENCES users(id),
+    assignee_team_id INTEGER,
+    instructions TEXT,
+    allow_context_edits BOOLEAN DEFAULT FALSE,
+    status TEXT NOT NULL DEFAULT 'PENDING', -- PENDING, APPROVED, REJECTED, TIMED_OUT, CANCELLED
+    comment TEXT,
+    decided_by INTEGER REFERENCES users(id),
+    decided_at TIMESTAMP WITH TIME ZONE,
+    due_at TIMESTAMP WITH TIME ZONE, -- Follow the 'timeout' handle after this
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_workflow_approvals_pending ON workflow_approvals(status, due_at);
+
+-- Executions parked at a WAIT_FOR_EVENT node, looked up by subject and event name
+-- when an event arrives. A row lives only while its execution is waiting.
+CREATE TABLE IF NOT EXISTS workflow_event_waits (
+    execution_id INTEGER PRIMARY KEY REFERENCES workflow_executions(id) ON DELETE CASCADE,
+    workflow_id INTEGER REFERENCES workflows(id) ON DELETE CASCADE,
+    node_id TEXT NOT NULL,
+    subject_id INTEGER NOT NULL REFERENCES people(id) ON DELETE CASCADE,
+    event_name TEXT NOT NULL,
+    filter JSONB, -- Event properties that must match
+    due_at TIMESTAMP WITH TIME ZONE, -- Follow the 'timeout' handle after this
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_workflow_event_waits_subject ON workflow_event_waits(subject_id, event_name);
+CREATE INDEX IF NOT EXISTS idx_workflow_event_waits_due ON workflow_event_waits(due_at) WHERE due_at IS NOT NULL;
+
+-- People who left a workflow early by reaching its goal, one row per top-level
+-- execution they exited
+CREATE TABLE IF NOT EXISTS workflow_conversions (
+    id SERIAL PRIMARY KEY,
+    workflow_id INTEGER REFERENCES workflows(id) ON DELETE CASCADE,
+    execution_id INTEGER UNIQUE REFERENCES workflow_executions(id) ON DELETE CASCADE,
+    subject_id INTEGER REFERENCES people(id) ON DELETE SET NULL,
+    node_id TEXT, -- Node the execution was at when it exited
+    goal_type TEXT NOT NULL, -- 'event', 'audience'
+    converted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_workflow_conversions_workflow ON workflow_conversions(workflow_id, converted_at);
+
+-- Variant each execution was sent down at a SPLIT node, for comparing outcomes
+-- per variant
+CREATE TABLE IF NOT EXISTS workflow_split_assignments (
+    execution_id INTEGER REFERENCES workflow_executions(id) ON DELETE CASCADE,
+    workflow_id INTEGER REFERENCES workflows(id) ON DELETE CASCADE,
+    node_id TEXT NOT NULL,
+    subject_id INTEGER REFERENCES people(id) ON DELETE SET NULL,
+    variant TEXT NOT NULL,
+    assigned_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (execution_id, node_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_workflow_split_assignments_node ON workflow_split_assignments(workflow_id, node_id, variant);
+
+-- Messages sent to people by workflow actions, counted against the organization's
+-- daily message cap. A row is written before the send and removed if it fails.
+CREATE TABLE IF NOT EXISTS message_deliveries (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE,
+    person_id INTEGER NOT NULL REFERENCES people(id) ON DELETE CASCADE,
+    execution_id INTEGER REFERENCES workflow_executions(id) ON DELETE SET NULL,
+    node_id TEXT,
+    channel TEXT NOT NULL, -- 'email'
+    sent_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_deliveries_person ON message_deliveries(person_id, sent_at);
+
 CREATE TABLE IF NOT EXISTS data_sources (
     id SERIAL PRIMARY KEY,
     organization_id INTEGER REFERENCES organizations(id),
@@ -239,3 +377,13 @@ CREATE TABLE IF NOT EXISTS content_templates (
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
+
+-- Credentials for HTTP Request actions; secrets are kept in the secret store at http_credentials/<id>
+CREATE TABLE IF NOT EXISTS http_credentials (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER REFERENCES organizations(id),
+    name TEXT NOT NULL,
+    auth_type TEXT NOT NULL, -- bearer, basic, header
+    header_name TEXT, -- For auth_type = header
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
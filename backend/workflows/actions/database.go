@@ -22,3 +22,8 @@ func (a *UpdateDBAction) Execute(ctx context.Context, contextData map[string]int
 	// Stub implementation
 	return fmt.Sprintf("Simulated update to %s:%s", a.Table, a.RecordID), nil
 }
+
+// Simulate describes the update without writing it
+func (a *UpdateDBAction) Simulate(ctx context.Context, contextData map[string]interface{}) (string, map[string]interface{}, error) {
+	return fmt.Sprintf("Would update %s:%s with %s", a.Table, a.RecordID, a.Data), nil, nil
+}
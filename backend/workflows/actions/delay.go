@@ -3,6 +3,7 @@ package actions
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/wesuuu/helpnow/backend/workflows"
 )
@@ -11,12 +12,17 @@ func init() {
 	workflows.RegisterAction("Delay", &DelayAction{})
 }
 
-// DelayAction pauses workflow execution
+// DelayAction pauses workflow execution for a number of minutes. DELAY nodes
+// offer more ways to set the wake-up time.
 type DelayAction struct {
 	DelayMinutes int `json:"delay_minutes" validate:"required,min=1" desc:"Number of minutes to delay execution."`
 }
 
 func (a *DelayAction) Execute(ctx context.Context, contextData map[string]interface{}) (output string, err error) {
-	// Stub implementation
-	return fmt.Sprintf("Simulated delay of %d minutes", a.DelayMinutes), nil
+	return fmt.Sprintf("Delaying %d minutes", a.DelayMinutes), nil
+}
+
+// PauseUntil holds the execution for DelayMinutes
+func (a *DelayAction) PauseUntil(now time.Time) time.Time {
+	return now.Add(time.Duration(a.DelayMinutes) * time.Minute)
 }
@@ -2,6 +2,7 @@ package actions
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"strconv"
@@ -21,37 +22,84 @@ type SendEmailAction struct {
 }
 
 func (a *SendEmailAction) Execute(ctx context.Context, contextData map[string]interface{}) (output string, err error) {
+	email, out, err := a.compose(ctx, contextData)
+	if err != nil {
+		return out, err
+	}
+
+	// Send Email
+	sendErr := outbound.SendEmail(ctx, email)
+	if sendErr != nil {
+		return "Failed to send email: " + sendErr.Error(), sendErr
+	}
+
+	return fmt.Sprintf("Email sent to %s (Template %d)", email.To, a.TemplateID), nil
+}
+
+// Channel counts sent emails towards the daily message cap
+func (a *SendEmailAction) Channel() string {
+	return "email"
+}
+
+// Simulate loads the template and resolves the recipient without sending
+func (a *SendEmailAction) Simulate(ctx context.Context, contextData map[string]interface{}) (string, map[string]interface{}, error) {
+	email, out, err := a.compose(ctx, contextData)
+	if err != nil {
+		return out, nil, err
+	}
+	data := map[string]interface{}{
+		"to":          email.To,
+		"subject":     email.Subject,
+		"template_id": a.TemplateID,
+	}
+	return fmt.Sprintf("Would send %q to %s (Template %d)", email.Subject, email.To, a.TemplateID), data, nil
+}
+
+// compose builds the email from the template and the recipient in the context,
+// falling back to the email of the person the execution runs for. On failure it
+// also returns the step output to report.
+func (a *SendEmailAction) compose(ctx context.Context, contextData map[string]interface{}) (outbound.Email, string, error) {
 	// Fetch Template from database
 	var subject, body string
 	row := db.GetDB().QueryRow("SELECT subject, body FROM email_templates WHERE id = $1", a.TemplateID)
 	if err := row.Scan(&subject, &body); err != nil {
-		return "Failed to fetch template", fmt.Errorf("failed to fetch template %d: %w", a.TemplateID, err)
+		return outbound.Email{}, "Failed to fetch template", fmt.Errorf("failed to fetch template %d: %w", a.TemplateID, err)
 	}
 
-	// Extract Recipient from context
-	recipient := ""
-	if email, ok := contextData["email"].(string); ok {
-		recipient = email
-	} else if _, ok := contextData["person_id"].(float64); ok {
-		// MVP: Not implemented - would fetch person's email from DB
+	recipient := contextEmail(contextData)
+	if info, ok := workflows.ExecutionInfoFrom(ctx); recipient == "" && ok && info.SubjectID != 0 {
+		var email sql.NullString
+		err := db.GetDB().QueryRowContext(ctx, "SELECT email FROM people WHERE id = $1", info.SubjectID).Scan(&email)
+		if err != nil && err != sql.ErrNoRows {
+			return outbound.Email{}, "Failed to load the recipient", fmt.Errorf("failed to load person %d: %w", info.SubjectID, err)
+		}
+		recipient = email.String
 	}
 
 	if recipient == "" {
-		return "No recipient email in context", errors.New("no recipient email found in context")
+		return outbound.Email{}, "No recipient email in context", errors.New("no recipient email found in context")
 	}
 
-	// Send Email
-	sendErr := outbound.SendEmail(ctx, outbound.Email{
+	return outbound.Email{
 		To:      recipient,
 		From:    "notifications@helpnow.ai",
 		Subject: subject,
 		Body:    body,
-	})
-	if sendErr != nil {
-		return "Failed to send email: " + sendErr.Error(), sendErr
-	}
+	}, "", nil
+}
 
-	return fmt.Sprintf("Email sent to %s (Template %d)", recipient, a.TemplateID), nil
+// contextEmail returns the recipient set in the context: a top-level email, as
+// sent by site events, or the email of the person a fan-out enrolled
+func contextEmail(contextData map[string]interface{}) string {
+	if email, ok := contextData["email"].(string); ok && email != "" {
+		return email
+	}
+	if person, ok := contextData["person"].(map[string]interface{}); ok {
+		if email, ok := person["email"].(string); ok {
+			return email
+		}
+	}
+	return ""
 }
 
 // Helper function to parse template_id from various types (for backward compatibility during migration)
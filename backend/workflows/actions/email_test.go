@@ -0,0 +1,72 @@
+package actions
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/wesuuu/helpnow/backend/db/dbtest"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+func TestContextEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		context map[string]interface{}
+		want    string
+	}{
+		{"site event", map[string]interface{}{"email": "a@example.com"}, "a@example.com"},
+		{"fanned out", map[string]interface{}{"person": map[string]interface{}{"id": float64(4), "email": "b@example.com"}}, "b@example.com"},
+		{"event email wins", map[string]interface{}{"email": "a@example.com", "person": map[string]interface{}{"email": "b@example.com"}}, "a@example.com"},
+		{"none", map[string]interface{}{"person": map[string]interface{}{"id": float64(4)}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contextEmail(tt.context); got != tt.want {
+				t.Errorf("contextEmail() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComposeFannedOutContext(t *testing.T) {
+	dbtest.Use(t, dbtest.Result{Match: "FROM email_templates", Rows: [][]driver.Value{{"Welcome", "Hello"}}})
+
+	// Fan-out puts the person's fields under person
+	ctxData := map[string]interface{}{"person": map[string]interface{}{"id": float64(4), "email": "b@example.com"}}
+	email, _, err := (&SendEmailAction{TemplateID: 2}).compose(context.Background(), ctxData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.To != "b@example.com" || email.Subject != "Welcome" {
+		t.Errorf("Unexpected email %+v", email)
+	}
+}
+
+func TestComposeLoadsSubjectEmail(t *testing.T) {
+	fake := dbtest.Use(t,
+		dbtest.Result{Match: "FROM email_templates", Rows: [][]driver.Value{{"Welcome", "Hello"}}},
+		dbtest.Result{Match: "FROM people", Rows: [][]driver.Value{{"c@example.com"}}},
+	)
+
+	ctx := workflows.WithExecutionInfo(context.Background(), workflows.ExecutionInfo{ExecutionID: 1, SubjectID: 9})
+	email, _, err := (&SendEmailAction{TemplateID: 2}).compose(ctx, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.To != "c@example.com" {
+		t.Errorf("Expected the subject's email, got %q", email.To)
+	}
+	if ran := fake.AssertOrder(t, "FROM people"); ran[0].Args[0] != int64(9) {
+		t.Errorf("Expected person 9 to be loaded, got %v", ran[0].Args)
+	}
+}
+
+func TestComposeWithoutRecipient(t *testing.T) {
+	dbtest.Use(t, dbtest.Result{Match: "FROM email_templates", Rows: [][]driver.Value{{"Welcome", "Hello"}}})
+
+	_, out, err := (&SendEmailAction{TemplateID: 2}).compose(context.Background(), map[string]interface{}{})
+	if err == nil || out != "No recipient email in context" {
+		t.Errorf("Expected a missing recipient to fail, got %q, %v", out, err)
+	}
+}
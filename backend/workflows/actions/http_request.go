@@ -1,9 +1,17 @@
 package actions
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
 
+	"github.com/wesuuu/helpnow/backend/outbound"
 	"github.com/wesuuu/helpnow/backend/workflows"
 )
 
@@ -11,15 +19,179 @@ func init() {
 	workflows.RegisterAction("HTTP Request", &HTTPRequestAction{})
 }
 
+const (
+	defaultHTTPTimeout     = 30 * time.Second
+	defaultMaxResponseSize = 1024 * 1024
+)
+
 // HTTPRequestAction sends an HTTP request
 type HTTPRequestAction struct {
-	Method  string `json:"method" validate:"required,oneof=GET POST PUT DELETE PATCH" desc:"HTTP Method to use for the request."`
-	URL     string `json:"url" validate:"required,url" desc:"Target URL for the request."`
-	Headers string `json:"headers,omitempty" desc:"Optional JSON string of request headers."`
-	Body    string `json:"body,omitempty" desc:"Optional request body payload."`
+	Method           string `json:"method" validate:"required,oneof=GET POST PUT DELETE PATCH" desc:"HTTP Method to use for the request."`
+	URL              string `json:"url" validate:"required,url" desc:"Target URL for the request."`
+	Headers          string `json:"headers,omitempty" desc:"Optional JSON string of request headers."`
+	Body             string `json:"body,omitempty" desc:"Optional request body payload."`
+	TimeoutSeconds   int    `json:"timeout_seconds,omitempty" validate:"omitempty,min=1,max=120" desc:"Optional: Seconds to wait for a response (default 30)."`
+	Retries          int    `json:"retries,omitempty" validate:"omitempty,min=0,max=5" desc:"Optional: How many times to retry when the server responds with a 5xx status."`
+	MaxResponseKB    int    `json:"max_response_kb,omitempty" validate:"omitempty,min=1,max=10240" desc:"Optional: Largest response body accepted, in KB (default 1024)."`
+	CredentialID     int    `json:"credential_id,omitempty" validate:"omitempty,min=1" desc:"Optional: ID of a stored HTTP credential to authenticate with, instead of putting tokens in headers."`
+	AllowErrorStatus bool   `json:"allow_error_status,omitempty" desc:"Optional: Treat 4xx responses as success so a condition can branch on the status."`
+}
+
+// Validate checks the URL scheme and that headers are a JSON object of strings
+func (a *HTTPRequestAction) Validate() error {
+	if a.URL != "" {
+		u, err := url.Parse(a.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return errors.New("url must be an http or https URL")
+		}
+	}
+	if _, err := a.parseHeaders(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *HTTPRequestAction) parseHeaders() (map[string]string, error) {
+	headers := map[string]string{}
+	if a.Headers == "" {
+		return headers, nil
+	}
+	if err := json.Unmarshal([]byte(a.Headers), &headers); err != nil {
+		return nil, fmt.Errorf("headers must be a JSON object of strings: %w", err)
+	}
+	return headers, nil
 }
 
 func (a *HTTPRequestAction) Execute(ctx context.Context, contextData map[string]interface{}) (output string, err error) {
-	// Stub implementation for now
-	return fmt.Sprintf("Simulated %s to %s", a.Method, a.URL), nil
+	output, _, err = a.ExecuteStructured(ctx, contextData)
+	return output, err
+}
+
+// ExecuteStructured performs the request and returns the response status, headers
+// and body (decoded when it is JSON) as structured output.
+func (a *HTTPRequestAction) ExecuteStructured(ctx context.Context, contextData map[string]interface{}) (string, map[string]interface{}, error) {
+	if err := a.Validate(); err != nil {
+		return "Invalid request", nil, err
+	}
+	headers, _ := a.parseHeaders()
+
+	policy := &outbound.HTTPPolicy{}
+	info, _ := workflows.ExecutionInfoFrom(ctx)
+	if info.OrganizationID > 0 {
+		allowlist, err := outbound.OrgHTTPAllowlist(ctx, info.OrganizationID)
+		if err != nil {
+			return "Failed to load HTTP allowlist", nil, err
+		}
+		policy.Allowlist = allowlist
+	}
+
+	var cred *outbound.HTTPCredential
+	if a.CredentialID > 0 {
+		if info.OrganizationID == 0 {
+			return "Credentials need an organization", nil, errors.New("credential_id is set but the workflow has no organization")
+		}
+		var err error
+		if cred, err = outbound.LoadHTTPCredential(ctx, info.OrganizationID, a.CredentialID); err != nil {
+			return "Failed to load credential", nil, err
+		}
+	}
+
+	timeout := defaultHTTPTimeout
+	if a.TimeoutSeconds > 0 {
+		timeout = time.Duration(a.TimeoutSeconds) * time.Second
+	}
+	maxSize := int64(defaultMaxResponseSize)
+	if a.MaxResponseKB > 0 {
+		maxSize = int64(a.MaxResponseKB) * 1024
+	}
+	client := outbound.NewHTTPClient(policy, timeout)
+
+	var resp *http.Response
+	var body []byte
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, a.Method, a.URL, bytes.NewBufferString(a.Body))
+		if err != nil {
+			return "Invalid request", nil, err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		if cred != nil {
+			if err := cred.Apply(req); err != nil {
+				return "Failed to apply credential", nil, err
+			}
+		}
+
+		resp, err = client.Do(req)
+		if err != nil {
+			if errors.Is(err, outbound.ErrBlockedDestination) {
+				return "Blocked request to internal address", nil, err
+			}
+			return fmt.Sprintf("%s %s failed: %v", a.Method, a.URL, err), nil, err
+		}
+		body, err = io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+		resp.Body.Close()
+		if err != nil {
+			return "Failed to read response", nil, err
+		}
+		if int64(len(body)) > maxSize {
+			return "Response too large", nil, fmt.Errorf("response from %s exceeds %d bytes", a.URL, maxSize)
+		}
+
+		if resp.StatusCode < 500 || attempt >= a.Retries {
+			break
+		}
+		backoff := time.Duration(500<<attempt) * time.Millisecond
+		select {
+		case <-ctx.Done():
+			return "Cancelled while retrying", nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+
+	data := map[string]interface{}{
+		"status":  resp.StatusCode,
+		"headers": flattenHeaders(resp.Header),
+		"body":    decodeBody(body),
+	}
+	output := fmt.Sprintf("%s %s returned %d (%d bytes)", a.Method, a.URL, resp.StatusCode, len(body))
+
+	switch {
+	case resp.StatusCode >= 500:
+		return output, data, workflows.Transient(fmt.Errorf("server returned %d", resp.StatusCode))
+	case resp.StatusCode >= 400 && !a.AllowErrorStatus:
+		return output, data, fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+	return output, data, nil
+}
+
+// Simulate checks the request without sending it. The mock response has status 200
+// and an empty body, so later nodes see the same shape as a real response.
+func (a *HTTPRequestAction) Simulate(ctx context.Context, contextData map[string]interface{}) (string, map[string]interface{}, error) {
+	if err := a.Validate(); err != nil {
+		return "Invalid request", nil, err
+	}
+	data := map[string]interface{}{
+		"status":  http.StatusOK,
+		"headers": map[string]interface{}{},
+		"body":    nil,
+	}
+	return fmt.Sprintf("Would send %s %s", a.Method, a.URL), data, nil
+}
+
+func flattenHeaders(h http.Header) map[string]interface{} {
+	out := make(map[string]interface{}, len(h))
+	for k := range h {
+		out[k] = h.Get(k)
+	}
+	return out
+}
+
+// decodeBody returns the parsed JSON body, or the raw text if it is not JSON
+func decodeBody(body []byte) interface{} {
+	var parsed interface{}
+	if len(body) > 0 && json.Unmarshal(body, &parsed) == nil {
+		return parsed
+	}
+	return string(body)
 }
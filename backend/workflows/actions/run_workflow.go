@@ -0,0 +1,104 @@
+package actions
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/wesuuu/helpnow/backend/db"
+	"github.com/wesuuu/helpnow/backend/workflows"
+)
+
+// maxWorkflowDepth limits how deeply Run Workflow may nest, so workflows that start
+// each other cannot loop forever
+const maxWorkflowDepth = 5
+
+func init() {
+	workflows.RegisterAction("Run Workflow", &RunWorkflowAction{})
+}
+
+// RunWorkflowAction starts another workflow as a child execution of this one
+type RunWorkflowAction struct {
+	WorkflowID int                    `json:"workflow_id" validate:"required,min=1" desc:"ID of the workflow to run."`
+	Input      map[string]interface{} `json:"input,omitempty" desc:"Optional: Context for the child workflow, e.g. {\"email\": \"{{context.email}}\"}. Defaults to a copy of this execution's context."`
+	Wait       bool                   `json:"wait,omitempty" desc:"Optional: Wait for the child to finish; its status, result and context are then merged into this execution's context under the node ID."`
+}
+
+// AwaitResult parks the parent until the child finishes when wait is set
+func (a *RunWorkflowAction) AwaitResult() bool {
+	return a.Wait
+}
+
+func (a *RunWorkflowAction) Execute(ctx context.Context, contextData map[string]interface{}) (string, error) {
+	out, _, err := a.ExecuteStructured(ctx, contextData)
+	return out, err
+}
+
+// ExecuteStructured creates the child execution. It runs the target's published
+// version for the same subject, one level deeper than this execution.
+func (a *RunWorkflowAction) ExecuteStructured(ctx context.Context, contextData map[string]interface{}) (string, map[string]interface{}, error) {
+	info, ok := workflows.ExecutionInfoFrom(ctx)
+	if !ok {
+		return "No execution to run the workflow from", nil, errors.New("run workflow needs a parent execution")
+	}
+	// Parallel branches and loop iterations share the depth of their execution
+	depth := info.Depth
+	if depth+1 > maxWorkflowDepth {
+		return "Sub-workflow depth limit reached", nil, fmt.Errorf("workflows may nest at most %d levels deep", maxWorkflowDepth)
+	}
+	if _, err := a.target(ctx, info); err != nil {
+		return "Workflow not found", nil, err
+	}
+
+	childContext := a.Input
+	if len(childContext) == 0 {
+		childContext = contextData
+	}
+	contextJSON, err := json.Marshal(childContext)
+	if err != nil {
+		return "Invalid input", nil, fmt.Errorf("failed to encode input: %w", err)
+	}
+
+	var childID int
+	err = db.GetDB().QueryRowContext(ctx, `
+		INSERT INTO workflow_executions (workflow_id, version_id, subject_id, status, next_run_at, created_at, context, parent_execution_id, parent_node_id, depth, priority)
+		SELECT $1, (SELECT published_version_id FROM workflows WHERE id = $1), subject_id, 'PENDING', NOW(), NOW(), $2, id, $3, $4,
+			(SELECT COALESCE(priority, 0) FROM workflows WHERE id = $1)
+		FROM workflow_executions WHERE id = $5
+		RETURNING id
+	`, a.WorkflowID, string(contextJSON), info.NodeID, depth+1, info.ExecutionID).Scan(&childID)
+	if err != nil {
+		return "Failed to start workflow", nil, fmt.Errorf("failed to start workflow %d: %w", a.WorkflowID, err)
+	}
+
+	data := map[string]interface{}{"execution_id": childID, "workflow_id": a.WorkflowID}
+	if a.Wait {
+		return fmt.Sprintf("Started workflow %d as execution %d; waiting for it to finish", a.WorkflowID, childID), data, nil
+	}
+	return fmt.Sprintf("Started workflow %d as execution %d", a.WorkflowID, childID), data, nil
+}
+
+// Simulate checks the target workflow exists without starting it
+func (a *RunWorkflowAction) Simulate(ctx context.Context, contextData map[string]interface{}) (string, map[string]interface{}, error) {
+	info, _ := workflows.ExecutionInfoFrom(ctx)
+	name, err := a.target(ctx, info)
+	if err != nil {
+		return "Workflow not found", nil, err
+	}
+	return fmt.Sprintf("Would run workflow %d (%s)", a.WorkflowID, name), map[string]interface{}{"workflow_id": a.WorkflowID, "input": a.Input}, nil
+}
+
+// target returns the name of the workflow to run, which must belong to the same
+// organization as the caller
+func (a *RunWorkflowAction) target(ctx context.Context, info workflows.ExecutionInfo) (string, error) {
+	var name string
+	err := db.GetDB().QueryRowContext(ctx, `
+		SELECT name FROM workflows WHERE id = $1 AND COALESCE(organization_id, 0) = $2
+	`, a.WorkflowID, info.OrganizationID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("workflow %d not found", a.WorkflowID)
+	}
+	return name, err
+}
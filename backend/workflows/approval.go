@@ -0,0 +1,77 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Handles followed out of an APPROVAL node
+const (
+	HandleApproved = "approved"
+	HandleRejected = "rejected"
+	HandleTimeout  = "timeout"
+)
+
+// ApprovalNode holds the properties of an APPROVAL node, which parks the
+// execution in WAITING_FOR_HUMAN until a reviewer approves or rejects it.
+type ApprovalNode struct {
+	AssigneeUserID    *int    `json:"assignee_user_id,omitempty" validate:"omitempty,min=1" desc:"User who should review this step."`
+	AssigneeTeamID    *int    `json:"assignee_team_id,omitempty" validate:"omitempty,min=1" desc:"Team whose members can review this step."`
+	Instructions      string  `json:"instructions,omitempty" desc:"Optional: What the reviewer should check before approving."`
+	TimeoutHours      float64 `json:"timeout_hours,omitempty" validate:"omitempty,gt=0" desc:"Optional: Follow the 'timeout' handle if nobody decides within this many hours."`
+	AllowContextEdits bool    `json:"allow_context_edits,omitempty" desc:"Optional: Let the reviewer edit the execution context before resuming."`
+}
+
+// Validate requires somebody to be assigned to the review
+func (a *ApprovalNode) Validate() error {
+	if a.AssigneeUserID == nil && a.AssigneeTeamID == nil {
+		return errors.New("assignee_user_id or assignee_team_id is required")
+	}
+	return nil
+}
+
+// Timeout returns how long the approval may stay pending, or zero for no limit
+func (a *ApprovalNode) Timeout() time.Duration {
+	return time.Duration(a.TimeoutHours * float64(time.Hour))
+}
+
+// ParseApprovalNode reads and validates an APPROVAL node's properties
+func ParseApprovalNode(properties map[string]interface{}) (*ApprovalNode, error) {
+	propBytes, err := json.Marshal(properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal properties: %w", err)
+	}
+	var node ApprovalNode
+	if err := json.Unmarshal(propBytes, &node); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal properties: %w", err)
+	}
+	if err := validate.Struct(&node); err != nil {
+		return nil, formatValidationError(err, "Approval")
+	}
+	if err := node.Validate(); err != nil {
+		return nil, fmt.Errorf("validation failed for Approval: %w", err)
+	}
+	return &node, nil
+}
+
+// WorkflowApproval is a review task created when an execution reaches an APPROVAL node
+type WorkflowApproval struct {
+	ID                int                    `json:"id"`
+	ExecutionID       int                    `json:"execution_id"`
+	WorkflowID        int                    `json:"workflow_id"`
+	WorkflowName      string                 `json:"workflow_name,omitempty"`
+	NodeID            string                 `json:"node_id"`
+	AssigneeUserID    *int                   `json:"assignee_user_id"`
+	AssigneeTeamID    *int                   `json:"assignee_team_id"`
+	Instructions      string                 `json:"instructions"`
+	AllowContextEdits bool                   `json:"allow_context_edits"`
+	Status            string                 `json:"status"` // PENDING, APPROVED, REJECTED, TIMED_OUT
+	Comment           *string                `json:"comment"`
+	DecidedBy         *int                   `json:"decided_by"`
+	DecidedAt         *time.Time             `json:"decided_at"`
+	DueAt             *time.Time             `json:"due_at"`
+	Context           map[string]interface{} `json:"context,omitempty"` // Execution context under review
+	CreatedAt         time.Time              `json:"created_at"`
+}
@@ -0,0 +1,27 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseApprovalNode(t *testing.T) {
+	if _, err := ParseApprovalNode(map[string]interface{}{"instructions": "Check the refund"}); err == nil {
+		t.Error("Expected error when no assignee is set")
+	}
+
+	node, err := ParseApprovalNode(map[string]interface{}{"assignee_user_id": 7.0, "timeout_hours": 1.5})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if node.AssigneeUserID == nil || *node.AssigneeUserID != 7 {
+		t.Errorf("Expected assignee 7, got %v", node.AssigneeUserID)
+	}
+	if node.Timeout() != 90*time.Minute {
+		t.Errorf("Expected 90m timeout, got %v", node.Timeout())
+	}
+
+	if _, err := ParseApprovalNode(map[string]interface{}{"assignee_team_id": 2.0, "timeout_hours": -1.0}); err == nil {
+		t.Error("Expected error for negative timeout")
+	}
+}
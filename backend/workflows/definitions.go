@@ -44,15 +44,38 @@ type Workflow struct {
 }
 
 type WorkflowExecution struct {
-	ID          int        `json:"id"`
-	WorkflowID  int        `json:"workflow_id"`
-	SubjectID   int        `json:"subject_id"`
-	CurrentStep int        `json:"current_step"`
-	Status      string     `json:"status"`
-	NextRunAt   time.Time  `json:"next_run_at"`
-	Context     string     `json:"context"` // JSON string
-	CreatedAt   time.Time  `json:"created_at"`
-	FinishedAt  *time.Time `json:"finished_at"`
+	ID            int                    `json:"id"`
+	WorkflowID    int                    `json:"workflow_id"`
+	Version       *int                   `json:"version"`                       // Workflow version the execution runs; nil if it predates versioning
+	ForkID        *int                   `json:"fork_id,omitempty"`             // Execution that started this one as a parallel branch
+	Branch        *string                `json:"branch,omitempty"`              // Label of the branch, from the node it started at
+	ParentID      *int                   `json:"parent_execution_id,omitempty"` // Execution whose Run Workflow node started this one
+	ParentNodeID  *string                `json:"parent_node_id,omitempty"`
+	Depth         int                    `json:"depth"`                         // Levels of Run Workflow above this execution
+	ChildIDs      []int                  `json:"child_execution_ids,omitempty"` // Executions this one started with Run Workflow
+	SubjectID     *int                   `json:"subject_id"`
+	CurrentNodeID *string                `json:"current_node_id"`
+	Status        string                 `json:"status"`
+	Attempt       int                    `json:"attempt"` // Attempts made on the current node
+	Result        *string                `json:"result,omitempty"`
+	NextRunAt     *time.Time             `json:"next_run_at"`
+	WakeAt        *time.Time             `json:"wake_at,omitempty"` // When a paused execution, e.g. at a delay, is scheduled to carry on
+	Context       map[string]interface{} `json:"context"`
+	StepResults   []StepResult           `json:"step_results"`
+	CreatedAt     time.Time              `json:"created_at"`
+	FinishedAt    *time.Time             `json:"finished_at"`
+}
+
+// StepResult records the outcome of one node execution
+type StepResult struct {
+	NodeID  string `json:"node_id"`
+	Status  string `json:"status"` // "success", "failed", "retrying", "skipped", "deferred"
+	Output  string `json:"output"`
+	Attempt int    `json:"attempt,omitempty"` // 1-based attempt number for retried nodes
+	Error   string `json:"error,omitempty"`
+	Branch  string `json:"branch,omitempty"` // Parallel branch the node ran on, e.g. "email" or "email/sms" when nested
+
+	DeferredUntil *time.Time `json:"deferred_until,omitempty"` // When a deferred send is due to run again, e.g. after quiet hours
 }
 
 // --- Graph Models (Moved from models.go) ---
@@ -97,6 +120,42 @@ type Action interface {
 	Execute(ctx context.Context, contextData map[string]interface{}) (output string, err error)
 }
 
+// StructuredAction is implemented by actions that return data for later nodes as
+// well as an output string. The worker merges the data into the execution context
+// under the node ID, e.g. context["n-123"].status.
+type StructuredAction interface {
+	ExecuteStructured(ctx context.Context, contextData map[string]interface{}) (output string, data map[string]interface{}, err error)
+}
+
+// Simulator is implemented by actions with side effects, such as sending email or
+// calling an API. Workflow simulations call Simulate instead of executing the
+// action; it should check its inputs and describe what it would have done without
+// doing it.
+type Simulator interface {
+	Simulate(ctx context.Context, contextData map[string]interface{}) (output string, data map[string]interface{}, err error)
+}
+
+// Awaiter is implemented by actions that start work the execution can wait for,
+// such as a child workflow. When AwaitResult reports true after the action ran, the
+// worker parks the execution in WAITING_FOR_CHILD until that work finishes.
+type Awaiter interface {
+	AwaitResult() bool
+}
+
+// Pauser is implemented by actions that hold the execution before it moves on,
+// such as Delay. After the action ran, the worker leaves the execution PENDING
+// until the time PauseUntil returns.
+type Pauser interface {
+	PauseUntil(now time.Time) time.Time
+}
+
+// Messenger is implemented by actions that send a message to a person, such as
+// an email. The worker holds them to the organization's daily message cap and
+// counts each send towards it.
+type Messenger interface {
+	Channel() string
+}
+
 // Logic interface - properties should be struct fields on the implementing type
 type Logic interface {
 	Evaluate(ctx context.Context, contextData map[string]interface{}) (result bool, output string, err error)
@@ -106,6 +165,11 @@ type Trigger interface {
 	Type() string
 }
 
+// Validatable is implemented by components that need checks beyond struct tags
+type Validatable interface {
+	Validate() error
+}
+
 var (
 	actionRegistry  = make(map[string]Action)
 	logicRegistry   = make(map[string]Logic)
@@ -0,0 +1,154 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Delay modes
+const (
+	DelayDuration    = "duration"     // A fixed time from now
+	DelayUntil       = "until"        // A given date and time
+	DelayNextWeekday = "next_weekday" // The next weekday at a time of day, in the person's time zone
+	DelayRelative    = "relative"     // An offset from a date in the context
+)
+
+// DefaultDelayHour is the time of day next_weekday delays wake at when no hour is set
+const DefaultDelayHour = 9
+
+// dateLayouts are the formats accepted for dates, tried in order. Those without
+// a zone are read in the delay's time zone.
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// DelayNode holds the properties of a DELAY node, which pauses the execution and
+// moves on to the next node once the wait is over
+type DelayNode struct {
+	Mode     string `json:"mode,omitempty" validate:"omitempty,oneof=duration until next_weekday relative" desc:"Optional: How the wake-up time is set: duration (default), until, next_weekday or relative."`
+	Days     int    `json:"days,omitempty" desc:"For duration and relative: Days to wait, or for relative, to offset the date by; negative for before it."`
+	Hours    int    `json:"hours,omitempty" desc:"For duration and relative: Hours to add to days."`
+	Minutes  int    `json:"minutes,omitempty" desc:"For duration and relative: Minutes to add to days and hours."`
+	Until    string `json:"until,omitempty" desc:"For until: Date and time to wake at, e.g. 2026-12-01T09:00:00Z or {{context.event.renews_at}}."`
+	Field    string `json:"field,omitempty" desc:"For relative: Context path of the date to offset, e.g. event.appointment_at."`
+	Hour     *int   `json:"hour,omitempty" validate:"omitempty,min=0,max=23" desc:"For next_weekday, optional: Hour of the day to wake at (default 9)."`
+	Minute   int    `json:"minute,omitempty" validate:"min=0,max=59" desc:"For next_weekday, optional: Minute past the hour to wake at."`
+	TimeZone string `json:"time_zone,omitempty" desc:"Optional: IANA time zone used when the person has none, e.g. Europe/Paris (default UTC)."`
+}
+
+// Offset returns the node's days, hours and minutes as a duration
+func (d *DelayNode) Offset() time.Duration {
+	return time.Duration(d.Days)*24*time.Hour + time.Duration(d.Hours)*time.Hour + time.Duration(d.Minutes)*time.Minute
+}
+
+// Location returns the time zone to schedule in: the person's if they have a
+// valid one, else the node's, else UTC
+func (d *DelayNode) Location(personZone string) *time.Location {
+	return FirstZone(personZone, d.TimeZone)
+}
+
+// WakeAt returns when an execution that reaches the node at now should carry on.
+// The result may be in the past, e.g. a relative delay from a date long gone.
+func (d *DelayNode) WakeAt(now time.Time, contextData map[string]interface{}, loc *time.Location) (time.Time, error) {
+	switch d.Mode {
+	case DelayUntil:
+		return ParseDate(d.Until, loc)
+
+	case DelayNextWeekday:
+		hour := DefaultDelayHour
+		if d.Hour != nil {
+			hour = *d.Hour
+		}
+		local := now.In(loc)
+		wake := time.Date(local.Year(), local.Month(), local.Day(), hour, d.Minute, 0, 0, loc)
+		for !wake.After(now) || wake.Weekday() == time.Saturday || wake.Weekday() == time.Sunday {
+			wake = time.Date(wake.Year(), wake.Month(), wake.Day()+1, hour, d.Minute, 0, 0, loc)
+		}
+		return wake, nil
+
+	case DelayRelative:
+		path := contextPath(d.Field)
+		value, ok := lookup(contextData, path)
+		if !ok {
+			// A {{placeholder}} field has already been filled in with the date
+			if from, err := ParseDate(d.Field, loc); err == nil {
+				return from.Add(d.Offset()), nil
+			}
+			return time.Time{}, fmt.Errorf("no date at %q in the context", path)
+		}
+		s, ok := value.(string)
+		if !ok {
+			return time.Time{}, fmt.Errorf("%q is not a date", path)
+		}
+		from, err := ParseDate(s, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q: %w", path, err)
+		}
+		return from.Add(d.Offset()), nil
+	}
+	return now.Add(d.Offset()), nil
+}
+
+// ParseDate reads a date in one of the accepted layouts; dates without a zone
+// are taken to be in loc
+func ParseDate(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date, expected e.g. 2026-12-01T09:00:00Z", s)
+}
+
+// contextPath turns a field given as {{context.a.b}} or a.b into a context path
+func contextPath(field string) string {
+	path := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(field), "{{"), "}}"))
+	return strings.TrimPrefix(path, "context.")
+}
+
+// ParseDelayNode reads and validates a DELAY node's properties. An until date
+// that is still a template is checked once it has been filled in.
+func ParseDelayNode(properties map[string]interface{}) (*DelayNode, error) {
+	propBytes, err := json.Marshal(properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal properties: %w", err)
+	}
+	var node DelayNode
+	if err := json.Unmarshal(propBytes, &node); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal properties: %w", err)
+	}
+	if err := validate.Struct(&node); err != nil {
+		return nil, formatValidationError(err, "Delay")
+	}
+	if node.Mode == "" {
+		node.Mode = DelayDuration
+	}
+
+	if node.TimeZone != "" {
+		if _, err := time.LoadLocation(node.TimeZone); err != nil {
+			return nil, fmt.Errorf("validation failed for Delay: unknown time_zone %q", node.TimeZone)
+		}
+	}
+	switch node.Mode {
+	case DelayDuration:
+		if node.Days < 0 || node.Hours < 0 || node.Minutes < 0 || node.Offset() <= 0 {
+			return nil, errors.New("validation failed for Delay: days, hours and minutes must add up to a positive wait")
+		}
+	case DelayUntil:
+		if node.Until == "" {
+			return nil, errors.New("validation failed for Delay: until is required for until delays")
+		}
+		if !strings.Contains(node.Until, "{{") {
+			if _, err := ParseDate(node.Until, time.UTC); err != nil {
+				return nil, fmt.Errorf("validation failed for Delay: until %w", err)
+			}
+		}
+	case DelayRelative:
+		if node.Field == "" {
+			return nil, errors.New("validation failed for Delay: field is required for relative delays")
+		}
+	}
+	return &node, nil
+}
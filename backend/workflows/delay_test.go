@@ -0,0 +1,87 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDelayNode(t *testing.T) {
+	node, err := ParseDelayNode(map[string]interface{}{"hours": 2})
+	if err != nil || node.Mode != DelayDuration || node.Offset() != 2*time.Hour {
+		t.Errorf("expected a two hour duration delay, got %+v (%v)", node, err)
+	}
+	if _, err := ParseDelayNode(map[string]interface{}{"mode": "until", "until": "{{context.event.renews_at}}"}); err != nil {
+		t.Errorf("expected a templated until to be accepted, got %v", err)
+	}
+
+	invalid := []map[string]interface{}{
+		{},
+		{"minutes": -5},
+		{"mode": "soon"},
+		{"mode": "until"},
+		{"mode": "until", "until": "next tuesday"},
+		{"mode": "relative", "days": -1},
+		{"mode": "next_weekday", "hour": 24},
+		{"mode": "next_weekday", "time_zone": "Mars/Olympus_Mons"},
+	}
+	for _, props := range invalid {
+		if _, err := ParseDelayNode(props); err == nil {
+			t.Errorf("expected an error for %v", props)
+		}
+	}
+}
+
+func TestDelayWakeAt(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("time zone data not available")
+	}
+	// Friday 2026-10-16 18:30 in Paris
+	now := time.Date(2026, 10, 16, 16, 30, 0, 0, time.UTC)
+	ctx := map[string]interface{}{"event": map[string]interface{}{"appointment_at": "2026-10-20T14:00:00Z"}}
+	hour := 10
+
+	cases := []struct {
+		name string
+		node DelayNode
+		want time.Time
+	}{
+		{"duration", DelayNode{Mode: DelayDuration, Days: 1, Minutes: 30}, now.Add(24*time.Hour + 30*time.Minute)},
+		{"until with zone", DelayNode{Mode: DelayUntil, Until: "2026-12-01T09:00:00Z"}, time.Date(2026, 12, 1, 9, 0, 0, 0, time.UTC)},
+		{"until in local time", DelayNode{Mode: DelayUntil, Until: "2026-12-01 09:00:00"}, time.Date(2026, 12, 1, 9, 0, 0, 0, paris)},
+		{"next weekday skips the weekend", DelayNode{Mode: DelayNextWeekday}, time.Date(2026, 10, 19, 9, 0, 0, 0, paris)},
+		{"next weekday at a set hour", DelayNode{Mode: DelayNextWeekday, Hour: &hour}, time.Date(2026, 10, 19, 10, 0, 0, 0, paris)},
+		{"relative before", DelayNode{Mode: DelayRelative, Field: "event.appointment_at", Days: -1}, time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)},
+		{"relative filled in", DelayNode{Mode: DelayRelative, Field: "2026-10-20T14:00:00Z", Hours: 2}, time.Date(2026, 10, 20, 16, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		got, err := tc.node.WakeAt(now, ctx, paris)
+		if err != nil || !got.Equal(tc.want) {
+			t.Errorf("%s: expected %s, got %s (%v)", tc.name, tc.want, got, err)
+		}
+	}
+
+	thursday := time.Date(2026, 10, 15, 6, 0, 0, 0, time.UTC)
+	node := DelayNode{Mode: DelayNextWeekday}
+	if got, _ := node.WakeAt(thursday, ctx, paris); !got.Equal(time.Date(2026, 10, 15, 9, 0, 0, 0, paris)) {
+		t.Errorf("expected 9am the same day, got %s", got)
+	}
+
+	node = DelayNode{Mode: DelayRelative, Field: "event.missing"}
+	if _, err := node.WakeAt(now, ctx, paris); err == nil {
+		t.Error("expected an error for a missing date")
+	}
+}
+
+func TestDelayLocation(t *testing.T) {
+	node := DelayNode{TimeZone: "America/New_York"}
+	if loc := node.Location("Asia/Tokyo"); loc.String() != "Asia/Tokyo" {
+		t.Errorf("expected the person's zone, got %s", loc)
+	}
+	if loc := node.Location("nowhere"); loc.String() != "America/New_York" {
+		t.Errorf("expected the node's zone, got %s", loc)
+	}
+	if loc := (&DelayNode{}).Location(""); loc != time.UTC {
+		t.Errorf("expected UTC, got %s", loc)
+	}
+}
@@ -0,0 +1,142 @@
+package workflows
+
+import (
+	"reflect"
+	"sort"
+)
+
+// GraphDiff describes how one version of a workflow graph differs from another.
+// Node positions are ignored; moving a node on the canvas is not a change.
+type GraphDiff struct {
+	AddedNodes   []Node       `json:"added_nodes"`
+	RemovedNodes []Node       `json:"removed_nodes"`
+	ChangedNodes []NodeChange `json:"changed_nodes"`
+	AddedEdges   []Edge       `json:"added_edges"`
+	RemovedEdges []Edge       `json:"removed_edges"`
+}
+
+// NodeChange lists what changed on a node present in both graphs
+type NodeChange struct {
+	NodeID     string           `json:"node_id"`
+	Type       *ValueChange     `json:"type,omitempty"`
+	Label      *ValueChange     `json:"label,omitempty"`
+	Properties []PropertyChange `json:"properties,omitempty"`
+}
+
+// ValueChange is a before/after pair
+type ValueChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// PropertyChange is a node property that was added, removed or modified. From is
+// nil for added properties and To is nil for removed ones.
+type PropertyChange struct {
+	Key  string      `json:"key"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Empty reports whether the graphs are equivalent
+func (d GraphDiff) Empty() bool {
+	return len(d.AddedNodes) == 0 && len(d.RemovedNodes) == 0 && len(d.ChangedNodes) == 0 &&
+		len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0
+}
+
+// DiffGraphs compares two graphs. Nodes are matched by ID; edges by source, target
+// and handle, since the builder may regenerate edge IDs.
+func DiffGraphs(from, to Graph) GraphDiff {
+	diff := GraphDiff{
+		AddedNodes:   []Node{},
+		RemovedNodes: []Node{},
+		ChangedNodes: []NodeChange{},
+		AddedEdges:   []Edge{},
+		RemovedEdges: []Edge{},
+	}
+
+	fromNodes := make(map[string]Node, len(from.Nodes))
+	for _, n := range from.Nodes {
+		fromNodes[n.ID] = n
+	}
+	toNodes := make(map[string]Node, len(to.Nodes))
+	for _, n := range to.Nodes {
+		toNodes[n.ID] = n
+	}
+
+	for _, n := range from.Nodes {
+		if _, ok := toNodes[n.ID]; !ok {
+			diff.RemovedNodes = append(diff.RemovedNodes, n)
+		}
+	}
+	for _, n := range to.Nodes {
+		old, ok := fromNodes[n.ID]
+		if !ok {
+			diff.AddedNodes = append(diff.AddedNodes, n)
+			continue
+		}
+		if change, changed := diffNode(old, n); changed {
+			diff.ChangedNodes = append(diff.ChangedNodes, change)
+		}
+	}
+
+	fromEdges := make(map[Edge]bool, len(from.Edges))
+	for _, e := range from.Edges {
+		fromEdges[edgeKey(e)] = true
+	}
+	toEdges := make(map[Edge]bool, len(to.Edges))
+	for _, e := range to.Edges {
+		toEdges[edgeKey(e)] = true
+	}
+	for _, e := range from.Edges {
+		if !toEdges[edgeKey(e)] {
+			diff.RemovedEdges = append(diff.RemovedEdges, e)
+		}
+	}
+	for _, e := range to.Edges {
+		if !fromEdges[edgeKey(e)] {
+			diff.AddedEdges = append(diff.AddedEdges, e)
+		}
+	}
+	return diff
+}
+
+func edgeKey(e Edge) Edge {
+	return Edge{Source: e.Source, Target: e.Target, Handle: e.Handle}
+}
+
+func diffNode(from, to Node) (NodeChange, bool) {
+	change := NodeChange{NodeID: to.ID}
+	changed := false
+	if from.Type != to.Type {
+		change.Type = &ValueChange{From: from.Type, To: to.Type}
+		changed = true
+	}
+	if from.Label != to.Label {
+		change.Label = &ValueChange{From: from.Label, To: to.Label}
+		changed = true
+	}
+
+	keys := map[string]bool{}
+	for k := range from.Properties {
+		keys[k] = true
+	}
+	for k := range to.Properties {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		oldVal, hadOld := from.Properties[k]
+		newVal, hasNew := to.Properties[k]
+		if hadOld && hasNew && reflect.DeepEqual(oldVal, newVal) {
+			continue
+		}
+		change.Properties = append(change.Properties, PropertyChange{Key: k, From: oldVal, To: newVal})
+		changed = true
+	}
+	return change, changed
+}
@@ -0,0 +1,69 @@
+package workflows
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func mustGraph(t *testing.T, raw string) Graph {
+	t.Helper()
+	var g Graph
+	if err := json.Unmarshal([]byte(raw), &g); err != nil {
+		t.Fatalf("bad graph: %v", err)
+	}
+	return g
+}
+
+func TestDiffGraphs(t *testing.T) {
+	from := mustGraph(t, `{
+		"nodes": [
+			{"id": "t1", "type": "TRIGGER", "label": "Start", "properties": {"trigger_type": "EVENT"}, "position": {"x": 0, "y": 0}},
+			{"id": "a1", "type": "ACTION", "label": "Email", "properties": {"action": "Send Email", "subject": "Hi"}},
+			{"id": "a2", "type": "ACTION", "label": "Old", "properties": {"action": "Log"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "t1", "target": "a1"},
+			{"id": "e2", "source": "a1", "target": "a2"}
+		]
+	}`)
+	to := mustGraph(t, `{
+		"nodes": [
+			{"id": "t1", "type": "TRIGGER", "label": "Start", "properties": {"trigger_type": "EVENT"}, "position": {"x": 50, "y": 90}},
+			{"id": "a1", "type": "ACTION", "label": "Welcome email", "properties": {"action": "Send Email", "subject": "Welcome", "body": "Hello"}},
+			{"id": "a3", "type": "ACTION", "label": "New", "properties": {"action": "Log"}}
+		],
+		"edges": [
+			{"id": "regenerated", "source": "t1", "target": "a1"},
+			{"id": "e3", "source": "a1", "target": "a3"}
+		]
+	}`)
+
+	diff := DiffGraphs(from, to)
+	if len(diff.AddedNodes) != 1 || diff.AddedNodes[0].ID != "a3" {
+		t.Errorf("added nodes = %+v", diff.AddedNodes)
+	}
+	if len(diff.RemovedNodes) != 1 || diff.RemovedNodes[0].ID != "a2" {
+		t.Errorf("removed nodes = %+v", diff.RemovedNodes)
+	}
+	if len(diff.ChangedNodes) != 1 {
+		t.Fatalf("expected only a1 to change (positions are ignored), got %+v", diff.ChangedNodes)
+	}
+	change := diff.ChangedNodes[0]
+	if change.NodeID != "a1" || change.Label == nil || change.Label.To != "Welcome email" {
+		t.Errorf("unexpected change %+v", change)
+	}
+	if len(change.Properties) != 2 || change.Properties[0].Key != "body" || change.Properties[0].From != nil ||
+		change.Properties[1].Key != "subject" || change.Properties[1].To != "Welcome" {
+		t.Errorf("unexpected property changes %+v", change.Properties)
+	}
+	if len(diff.AddedEdges) != 1 || diff.AddedEdges[0].Target != "a3" {
+		t.Errorf("added edges = %+v", diff.AddedEdges)
+	}
+	if len(diff.RemovedEdges) != 1 || diff.RemovedEdges[0].Target != "a2" {
+		t.Errorf("removed edges = %+v", diff.RemovedEdges)
+	}
+
+	if !DiffGraphs(from, from).Empty() {
+		t.Error("expected a graph to have no diff against itself")
+	}
+}
@@ -0,0 +1,29 @@
+package workflows
+
+import "errors"
+
+// Entry rule modes
+const (
+	EntryAlways        = "always"          // Every trigger starts an execution
+	EntryOnce          = "once"            // A person enters at most once, ever
+	EntryOncePerPeriod = "once_per_period" // At most once every PeriodDays
+	EntryOneActive     = "one_active"      // Not while they have an execution still running
+)
+
+// EntryRule limits how often one person may enter a workflow. Executions without
+// a subject, such as webhook runs, are not limited.
+type EntryRule struct {
+	Mode       string `json:"mode" validate:"required,oneof=always once once_per_period one_active" desc:"When a person may enter again: always, once, once_per_period or one_active."`
+	PeriodDays int    `json:"period_days,omitempty" validate:"omitempty,min=1" desc:"For once_per_period: Days before the same person may enter again."`
+}
+
+// Validate checks the rule has what its mode needs
+func (r *EntryRule) Validate() error {
+	if err := validate.Struct(r); err != nil {
+		return formatValidationError(err, "Entry Rule")
+	}
+	if r.Mode == EntryOncePerPeriod && r.PeriodDays == 0 {
+		return errors.New("validation failed for Entry Rule: period_days is required for once_per_period")
+	}
+	return nil
+}
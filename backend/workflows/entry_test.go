@@ -0,0 +1,28 @@
+package workflows
+
+import "testing"
+
+func TestEntryRuleValidate(t *testing.T) {
+	valid := []EntryRule{
+		{Mode: EntryAlways},
+		{Mode: EntryOnce},
+		{Mode: EntryOncePerPeriod, PeriodDays: 7},
+		{Mode: EntryOneActive},
+	}
+	for _, r := range valid {
+		if err := r.Validate(); err != nil {
+			t.Errorf("expected %+v to be valid, got %v", r, err)
+		}
+	}
+	invalid := []EntryRule{
+		{},
+		{Mode: "twice"},
+		{Mode: EntryOncePerPeriod},
+		{Mode: EntryOncePerPeriod, PeriodDays: -1},
+	}
+	for _, r := range invalid {
+		if err := r.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", r)
+		}
+	}
+}
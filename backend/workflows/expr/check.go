@@ -0,0 +1,296 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Type is the static type of an expression. Context variables are only known at
+// runtime, so anything read from them is TypeAny.
+type Type int
+
+const (
+	TypeAny Type = iota
+	TypeBool
+	TypeNumber
+	TypeString
+	TypeNull
+	TypeList
+	TypeDate
+	TypeDuration
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeBool:
+		return "boolean"
+	case TypeNumber:
+		return "number"
+	case TypeString:
+		return "string"
+	case TypeNull:
+		return "null"
+	case TypeList:
+		return "list"
+	case TypeDate:
+		return "date"
+	case TypeDuration:
+		return "duration"
+	}
+	return "any"
+}
+
+// TypeError reports an operation that can never succeed, with the offending sub-expression
+type TypeError struct {
+	Expr string
+	Msg  string
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("%s in %q", e.Msg, e.Expr)
+}
+
+// function describes a built-in: its argument types (TypeAny accepts anything) and result
+type function struct {
+	args   []Type
+	result Type
+}
+
+var functions = map[string]function{
+	"now":     {nil, TypeDate},
+	"date":    {[]Type{TypeAny}, TypeDate},
+	"days":    {[]Type{TypeNumber}, TypeDuration},
+	"hours":   {[]Type{TypeNumber}, TypeDuration},
+	"minutes": {[]Type{TypeNumber}, TypeDuration},
+	"len":     {[]Type{TypeAny}, TypeNumber},
+	"lower":   {[]Type{TypeString}, TypeString},
+	"upper":   {[]Type{TypeString}, TypeString},
+	"exists":  {[]Type{TypeAny}, TypeBool},
+	"number":  {[]Type{TypeAny}, TypeNumber},
+	"string":  {[]Type{TypeAny}, TypeString},
+}
+
+type checker struct {
+	src string
+}
+
+func (c *checker) errorf(n node, format string, args ...interface{}) error {
+	start, end := n.span()
+	return &TypeError{Expr: c.src[start:end], Msg: fmt.Sprintf(format, args...)}
+}
+
+// fits reports whether a value of type got can be used where want is expected
+func fits(got, want Type) bool {
+	return got == TypeAny || want == TypeAny || got == want
+}
+
+func (c *checker) check(n node) (Type, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		switch n.value.(type) {
+		case bool:
+			return TypeBool, nil
+		case float64:
+			return TypeNumber, nil
+		case string:
+			return TypeString, nil
+		}
+		return TypeNull, nil
+
+	case *identNode:
+		return TypeAny, nil
+
+	case *memberNode:
+		t, err := c.check(n.object)
+		if err != nil {
+			return t, err
+		}
+		if t != TypeAny && t != TypeNull {
+			return t, c.errorf(n, "cannot read field %q of a %s", n.field, t)
+		}
+		return TypeAny, nil
+
+	case *indexNode:
+		t, err := c.check(n.object)
+		if err != nil {
+			return t, err
+		}
+		if _, err := c.check(n.index); err != nil {
+			return TypeAny, err
+		}
+		if t != TypeAny && t != TypeList && t != TypeNull {
+			return t, c.errorf(n, "cannot index a %s", t)
+		}
+		return TypeAny, nil
+
+	case *listNode:
+		for _, item := range n.items {
+			if _, err := c.check(item); err != nil {
+				return TypeList, err
+			}
+		}
+		return TypeList, nil
+
+	case *unaryNode:
+		t, err := c.check(n.operand)
+		if err != nil {
+			return t, err
+		}
+		if n.op == "!" {
+			if !fits(t, TypeBool) && t != TypeNull {
+				return t, c.errorf(n, "! needs a boolean, got %s", t)
+			}
+			return TypeBool, nil
+		}
+		if !fits(t, TypeNumber) && t != TypeDuration {
+			return t, c.errorf(n, "cannot negate a %s", t)
+		}
+		return t, nil
+
+	case *callNode:
+		fn, ok := functions[n.name]
+		if !ok {
+			return TypeAny, c.errorf(n, "unknown function %s()", n.name)
+		}
+		if len(n.args) != len(fn.args) {
+			return TypeAny, c.errorf(n, "%s() takes %d argument(s), got %d", n.name, len(fn.args), len(n.args))
+		}
+		for i, arg := range n.args {
+			t, err := c.check(arg)
+			if err != nil {
+				return t, err
+			}
+			if !fits(t, fn.args[i]) && t != TypeNull {
+				return t, c.errorf(n, "%s() needs a %s, got %s", n.name, fn.args[i], t)
+			}
+		}
+		return fn.result, nil
+
+	case *binaryNode:
+		return c.checkBinary(n)
+	}
+	return TypeAny, fmt.Errorf("unsupported expression %T", n)
+}
+
+func (c *checker) checkBinary(n *binaryNode) (Type, error) {
+	l, err := c.check(n.left)
+	if err != nil {
+		return l, err
+	}
+	r, err := c.check(n.right)
+	if err != nil {
+		return r, err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		for _, t := range []Type{l, r} {
+			if !fits(t, TypeBool) && t != TypeNull {
+				return t, c.errorf(n, "%s needs booleans on both sides, got %s", n.op, t)
+			}
+		}
+		return TypeBool, nil
+
+	case "==", "!=":
+		if l != TypeAny && r != TypeAny && l != TypeNull && r != TypeNull && !comparable(l, r) {
+			return TypeBool, c.errorf(n, "a %s can never equal a %s", l, r)
+		}
+		return TypeBool, nil
+
+	case "<", "<=", ">", ">=":
+		if l == TypeNull || r == TypeNull {
+			return TypeBool, c.errorf(n, "cannot order against null, use == null or ??")
+		}
+		for _, t := range []Type{l, r} {
+			if t == TypeBool || t == TypeList {
+				return TypeBool, c.errorf(n, "cannot order a %s", t)
+			}
+		}
+		if l != TypeAny && r != TypeAny && !comparable(l, r) {
+			return TypeBool, c.errorf(n, "cannot compare a %s with a %s", l, r)
+		}
+		return TypeBool, nil
+
+	case "in", "not in":
+		if r != TypeAny && r != TypeList && r != TypeString && r != TypeNull {
+			return TypeBool, c.errorf(n, "%s needs a list or string on the right, got %s", n.op, r)
+		}
+		return TypeBool, nil
+
+	case "contains":
+		if l != TypeAny && l != TypeList && l != TypeString && l != TypeNull {
+			return TypeBool, c.errorf(n, "contains needs a list or string on the left, got %s", l)
+		}
+		return TypeBool, nil
+
+	case "matches", "startsWith", "endsWith":
+		for _, t := range []Type{l, r} {
+			if !fits(t, TypeString) && t != TypeNull {
+				return TypeBool, c.errorf(n, "%s needs strings, got %s", n.op, t)
+			}
+		}
+		if lit, ok := n.right.(*literalNode); ok && n.op == "matches" {
+			pattern, _ := lit.value.(string)
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return TypeBool, c.errorf(n, "invalid regular expression: %v", err)
+			}
+			n.re = re
+		}
+		return TypeBool, nil
+
+	case "??":
+		if l == TypeNull || l == TypeAny {
+			return r, nil
+		}
+		if r == TypeNull || l == r {
+			return l, nil
+		}
+		return TypeAny, nil
+
+	case "+", "-", "*", "/", "%":
+		return c.checkArithmetic(n, l, r)
+	}
+	return TypeAny, c.errorf(n, "unknown operator %s", n.op)
+}
+
+func (c *checker) checkArithmetic(n *binaryNode, l, r Type) (Type, error) {
+	if l == TypeAny || r == TypeAny {
+		// Context values are checked when evaluated
+		return TypeAny, nil
+	}
+	if t, ok := arithmeticResult(n.op, l, r); ok {
+		return t, nil
+	}
+	return TypeAny, c.errorf(n, "cannot apply %s to a %s and a %s", n.op, l, r)
+}
+
+// arithmeticResult returns the type of l op r when both are known
+func arithmeticResult(op string, l, r Type) (Type, bool) {
+	switch {
+	case l == TypeNumber && r == TypeNumber:
+		return TypeNumber, true
+	case op == "+" && l == TypeString && r == TypeString:
+		return TypeString, true
+	case (op == "+" || op == "-") && l == TypeDate && r == TypeDuration:
+		return TypeDate, true
+	case op == "+" && l == TypeDuration && r == TypeDate:
+		return TypeDate, true
+	case op == "-" && l == TypeDate && r == TypeDate:
+		return TypeDuration, true
+	case (op == "+" || op == "-") && l == TypeDuration && r == TypeDuration:
+		return TypeDuration, true
+	case (op == "*" || op == "/") && l == TypeDuration && r == TypeNumber:
+		return TypeDuration, true
+	}
+	return TypeAny, false
+}
+
+// comparable reports whether values of the two known types can be compared.
+// Strings compare with dates by parsing them.
+func comparable(l, r Type) bool {
+	if l == r {
+		return true
+	}
+	return (l == TypeString && r == TypeDate) || (l == TypeDate && r == TypeString)
+}
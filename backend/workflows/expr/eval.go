@@ -0,0 +1,584 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts are the string formats accepted wherever a date is expected
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+type evaluator struct {
+	src   string
+	env   map[string]interface{}
+	now   time.Time
+	trace map[*binaryNode][2]interface{} // Operand values of evaluated comparisons
+}
+
+func (e *evaluator) errorf(n node, format string, args ...interface{}) error {
+	start, end := n.span()
+	return fmt.Errorf("%s in %q", fmt.Sprintf(format, args...), e.src[start:end])
+}
+
+// decide evaluates a boolean expression and returns the sub-expression that
+// determined the result, following short-circuiting through && || and !
+func (e *evaluator) decide(n node) (bool, node, error) {
+	switch n := n.(type) {
+	case *binaryNode:
+		if n.op == "&&" || n.op == "||" {
+			l, decider, err := e.decide(n.left)
+			if err != nil {
+				return false, n, err
+			}
+			if (n.op == "&&" && !l) || (n.op == "||" && l) {
+				return l, decider, nil
+			}
+			return e.decide(n.right)
+		}
+	case *unaryNode:
+		if n.op == "!" {
+			v, decider, err := e.decide(n.operand)
+			return !v, decider, err
+		}
+	}
+
+	v, err := e.eval(n)
+	if err != nil {
+		return false, n, err
+	}
+	switch b := v.(type) {
+	case bool:
+		return b, n, nil
+	case nil:
+		return false, n, nil // null is false in a boolean context
+	}
+	return false, n, e.errorf(n, "expected true or false, got %s", formatValue(v))
+}
+
+func (e *evaluator) eval(n node) (interface{}, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return n.value, nil
+
+	case *identNode:
+		if v, ok := e.env[n.name]; ok {
+			return normalize(v), nil
+		}
+		if n.name == "context" {
+			// The whole context, for keys that are not identifiers: context["n-123"]
+			return e.env, nil
+		}
+		return nil, nil
+
+	case *memberNode:
+		obj, err := e.eval(n.object)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		m, ok := obj.(map[string]interface{})
+		if !ok {
+			return nil, e.errorf(n, "cannot read field %q of %s", n.field, formatValue(obj))
+		}
+		return normalize(m[n.field]), nil
+
+	case *indexNode:
+		return e.evalIndex(n)
+
+	case *listNode:
+		items := make([]interface{}, len(n.items))
+		for i, item := range n.items {
+			v, err := e.eval(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = v
+		}
+		return items, nil
+
+	case *unaryNode:
+		if n.op == "!" {
+			v, _, err := e.decide(n)
+			return v, err
+		}
+		v, err := e.eval(n.operand)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		switch x := v.(type) {
+		case float64:
+			return -x, nil
+		case time.Duration:
+			return -x, nil
+		}
+		return nil, e.errorf(n, "cannot negate %s", formatValue(v))
+
+	case *callNode:
+		return e.evalCall(n)
+
+	case *binaryNode:
+		return e.evalBinary(n)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", n)
+}
+
+func (e *evaluator) evalIndex(n *indexNode) (interface{}, error) {
+	obj, err := e.eval(n.object)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	idx, err := e.eval(n.index)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o := obj.(type) {
+	case []interface{}:
+		i, ok := idx.(float64)
+		if !ok || i != float64(int(i)) {
+			return nil, e.errorf(n, "list index must be a whole number, got %s", formatValue(idx))
+		}
+		if int(i) < 0 || int(i) >= len(o) {
+			return nil, nil
+		}
+		return normalize(o[int(i)]), nil
+	case map[string]interface{}:
+		key, ok := idx.(string)
+		if !ok {
+			return nil, e.errorf(n, "map key must be a string, got %s", formatValue(idx))
+		}
+		return normalize(o[key]), nil
+	}
+	return nil, e.errorf(n, "cannot index %s", formatValue(obj))
+}
+
+func (e *evaluator) evalCall(n *callNode) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := e.eval(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	switch n.name {
+	case "now":
+		return e.now, nil
+
+	case "date":
+		switch v := args[0].(type) {
+		case nil:
+			return nil, nil
+		case float64:
+			return time.Unix(int64(v), 0).UTC(), nil
+		}
+		if t, ok := toTime(args[0]); ok {
+			return t, nil
+		}
+		return nil, e.errorf(n, "cannot read %s as a date", formatValue(args[0]))
+
+	case "days", "hours", "minutes":
+		if args[0] == nil {
+			return nil, nil
+		}
+		x, ok := toNumber(args[0])
+		if !ok {
+			return nil, e.errorf(n, "%s() needs a number, got %s", n.name, formatValue(args[0]))
+		}
+		unit := map[string]time.Duration{"days": 24 * time.Hour, "hours": time.Hour, "minutes": time.Minute}[n.name]
+		return time.Duration(x * float64(unit)), nil
+
+	case "len":
+		switch v := args[0].(type) {
+		case nil:
+			return 0.0, nil
+		case string:
+			return float64(len([]rune(v))), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return nil, e.errorf(n, "len() needs a string, list or map, got %s", formatValue(args[0]))
+
+	case "lower", "upper":
+		if args[0] == nil {
+			return nil, nil
+		}
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, e.errorf(n, "%s() needs a string, got %s", n.name, formatValue(args[0]))
+		}
+		if n.name == "lower" {
+			return strings.ToLower(s), nil
+		}
+		return strings.ToUpper(s), nil
+
+	case "exists":
+		return args[0] != nil, nil
+
+	case "number":
+		if args[0] == nil {
+			return nil, nil
+		}
+		if x, ok := toNumber(args[0]); ok {
+			return x, nil
+		}
+		if b, ok := args[0].(bool); ok {
+			if b {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		}
+		return nil, e.errorf(n, "cannot convert %s to a number", formatValue(args[0]))
+
+	case "string":
+		if s, ok := args[0].(string); ok {
+			return s, nil
+		}
+		if args[0] == nil {
+			return nil, nil
+		}
+		return formatValue(args[0]), nil
+	}
+	return nil, e.errorf(n, "unknown function %s()", n.name)
+}
+
+func (e *evaluator) evalBinary(n *binaryNode) (interface{}, error) {
+	if n.op == "&&" || n.op == "||" {
+		v, _, err := e.decide(n)
+		return v, err
+	}
+
+	l, err := e.eval(n.left)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "??" {
+		if l != nil {
+			return l, nil
+		}
+		return e.eval(n.right)
+	}
+	r, err := e.eval(n.right)
+	if err != nil {
+		return nil, err
+	}
+	if comparisonOps[n.op] {
+		e.trace[n] = [2]interface{}{l, r}
+	}
+
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+
+	case "<", "<=", ">", ">=":
+		if l == nil || r == nil {
+			return false, nil // Ordering against null is never true
+		}
+		cmp, err := compare(l, r)
+		if err != nil {
+			return nil, e.errorf(n, "%v", err)
+		}
+		switch n.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		}
+		return cmp >= 0, nil
+
+	case "in", "not in":
+		found, err := contains(r, l)
+		if err != nil {
+			return nil, e.errorf(n, "%v", err)
+		}
+		if n.op == "not in" {
+			return !found, nil
+		}
+		return found, nil
+
+	case "contains":
+		found, err := contains(l, r)
+		if err != nil {
+			return nil, e.errorf(n, "%v", err)
+		}
+		return found, nil
+
+	case "matches", "startsWith", "endsWith":
+		if l == nil || r == nil {
+			return false, nil
+		}
+		s, ok1 := l.(string)
+		pattern, ok2 := r.(string)
+		if !ok1 || !ok2 {
+			return nil, e.errorf(n, "%s needs strings, got %s and %s", n.op, formatValue(l), formatValue(r))
+		}
+		switch n.op {
+		case "startsWith":
+			return strings.HasPrefix(s, pattern), nil
+		case "endsWith":
+			return strings.HasSuffix(s, pattern), nil
+		}
+		re := n.re
+		if re == nil {
+			if re, err = regexp.Compile(pattern); err != nil {
+				return nil, e.errorf(n, "invalid regular expression: %v", err)
+			}
+		}
+		return re.MatchString(s), nil
+	}
+
+	if l == nil || r == nil {
+		return nil, nil // Arithmetic on null stays null
+	}
+	v, err := arithmetic(n.op, l, r)
+	if err != nil {
+		return nil, e.errorf(n, "%v", err)
+	}
+	return v, nil
+}
+
+func arithmetic(op string, l, r interface{}) (interface{}, error) {
+	// Dates written as strings take part in date math
+	if _, ok := l.(time.Time); ok {
+		if t, ok := toTime(r); ok {
+			r = t
+		}
+	}
+	if _, ok := r.(time.Time); ok {
+		if t, ok := toTime(l); ok {
+			l = t
+		}
+	}
+
+	switch a := l.(type) {
+	case float64:
+		if b, ok := toNumber(r); ok {
+			switch op {
+			case "+":
+				return a + b, nil
+			case "-":
+				return a - b, nil
+			case "*":
+				return a * b, nil
+			case "/", "%":
+				if b == 0 {
+					return nil, fmt.Errorf("division by zero")
+				}
+				if op == "/" {
+					return a / b, nil
+				}
+				return float64(int64(a) % int64(b)), nil
+			}
+		}
+	case string:
+		if b, ok := r.(string); ok && op == "+" {
+			return a + b, nil
+		}
+	case time.Time:
+		switch b := r.(type) {
+		case time.Duration:
+			if op == "+" {
+				return a.Add(b), nil
+			} else if op == "-" {
+				return a.Add(-b), nil
+			}
+		case time.Time:
+			if op == "-" {
+				return a.Sub(b), nil
+			}
+		}
+	case time.Duration:
+		switch b := r.(type) {
+		case time.Duration:
+			if op == "+" {
+				return a + b, nil
+			} else if op == "-" {
+				return a - b, nil
+			}
+		case time.Time:
+			if op == "+" {
+				return b.Add(a), nil
+			}
+		case float64:
+			if op == "*" {
+				return time.Duration(float64(a) * b), nil
+			} else if op == "/" && b != 0 {
+				return time.Duration(float64(a) / b), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("cannot apply %s to %s and %s", op, formatValue(l), formatValue(r))
+}
+
+func equal(l, r interface{}) bool {
+	if l == nil || r == nil {
+		return l == nil && r == nil
+	}
+	if cmp, err := compare(l, r); err == nil {
+		return cmp == 0
+	}
+	return reflect.DeepEqual(l, r)
+}
+
+// compare orders two non-null values of compatible types
+func compare(l, r interface{}) (int, error) {
+	switch a := l.(type) {
+	case float64:
+		if b, ok := toNumber(r); ok {
+			return compareOrdered(a, b), nil
+		}
+	case string:
+		switch b := r.(type) {
+		case string:
+			return strings.Compare(a, b), nil
+		case float64:
+			if x, ok := toNumber(a); ok {
+				return compareOrdered(x, b), nil
+			}
+		case time.Time:
+			if t, ok := toTime(a); ok {
+				return t.Compare(b), nil
+			}
+		}
+	case time.Time:
+		if b, ok := toTime(r); ok {
+			return a.Compare(b), nil
+		}
+	case time.Duration:
+		if b, ok := r.(time.Duration); ok {
+			return compareOrdered(a, b), nil
+		}
+	case bool:
+		if b, ok := r.(bool); ok && a == b {
+			return 0, nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", formatValue(l), formatValue(r))
+}
+
+func compareOrdered[T float64 | time.Duration](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// contains reports whether container (list, string or map) holds item
+func contains(container, item interface{}) (bool, error) {
+	switch c := container.(type) {
+	case nil:
+		return false, nil
+	case []interface{}:
+		for _, v := range c {
+			if equal(normalize(v), item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case string:
+		s, ok := item.(string)
+		if !ok {
+			if item == nil {
+				return false, nil
+			}
+			s = formatValue(item)
+		}
+		return strings.Contains(c, s), nil
+	case map[string]interface{}:
+		key, ok := item.(string)
+		if !ok {
+			return false, nil
+		}
+		_, found := c[key]
+		return found, nil
+	}
+	return false, fmt.Errorf("cannot search in %s", formatValue(container))
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, true
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, x); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// normalize converts Go values placed in the context into the evaluator's types
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case int:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case json.Number:
+		f, _ := x.Float64()
+		return f
+	case []string:
+		items := make([]interface{}, len(x))
+		for i, s := range x {
+			items[i] = s
+		}
+		return items
+	case *time.Time:
+		if x == nil {
+			return nil
+		}
+		return *x
+	}
+	return v
+}
+
+// formatValue renders a value for error messages and evaluation output
+func formatValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case time.Time:
+		return x.Format(time.RFC3339)
+	case time.Duration:
+		return x.String()
+	case []interface{}, map[string]interface{}:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,96 @@
+// Package expr implements the expression language used by CONDITION nodes.
+//
+// Expressions read the execution context by name, e.g.
+//
+//	person.score > 50 && event.plan == "pro"
+//	lower(email) endsWith "@example.com" || tags contains "vip"
+//	now() - date(signed_up_at) > days(30)
+//	(person.country ?? "US") in ["US", "CA"]
+//	context["n-1712345"].status == 200
+//
+// "context" names the whole context, which reaches keys such as node IDs that
+// are not valid identifiers.
+//
+// Missing variables are null. Ordering comparisons against null are false and
+// null is false in a boolean context, so absent data never errors.
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// now is replaced in tests
+var now = time.Now
+
+// Program is a parsed and type-checked expression
+type Program struct {
+	source string
+	root   node
+}
+
+// Compile parses an expression and checks that it can produce a boolean
+func Compile(source string) (*Program, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, errors.New("expression is empty")
+	}
+
+	root, err := parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := (&checker{src: source}).check(root)
+	if err != nil {
+		return nil, err
+	}
+	if t != TypeAny && t != TypeBool {
+		return nil, fmt.Errorf("expression must produce true or false, got a %s", t)
+	}
+	return &Program{source: source, root: root}, nil
+}
+
+// Source returns the expression text
+func (p *Program) Source() string {
+	return p.source
+}
+
+// Result is the outcome of evaluating a Program
+type Result struct {
+	Value   bool
+	Decider string // Sub-expression that decided the result
+	Detail  string // Operand values of the deciding comparison, e.g. `72 > 50`
+}
+
+func (r Result) String() string {
+	if r.Detail == "" {
+		return fmt.Sprintf("decided by %s", r.Decider)
+	}
+	return fmt.Sprintf("decided by %s (%s)", r.Decider, r.Detail)
+}
+
+// Evaluate runs the expression against the given variables
+func (p *Program) Evaluate(env map[string]interface{}) (Result, error) {
+	e := &evaluator{
+		src:   p.source,
+		env:   env,
+		now:   now(),
+		trace: map[*binaryNode][2]interface{}{},
+	}
+
+	value, decider, err := e.decide(p.root)
+	if err != nil {
+		return Result{}, err
+	}
+
+	start, end := decider.span()
+	result := Result{Value: value, Decider: p.source[start:end]}
+	if b, ok := decider.(*binaryNode); ok {
+		if operands, ok := e.trace[b]; ok {
+			result.Detail = fmt.Sprintf("%s %s %s", formatValue(operands[0]), b.op, formatValue(operands[1]))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEnv() map[string]interface{} {
+	return map[string]interface{}{
+		"person": map[string]interface{}{
+			"score":      72.0,
+			"email":      "Ada@Example.com",
+			"tags":       []interface{}{"vip", "beta"},
+			"signed_up":  "2026-01-01T00:00:00Z",
+			"first_name": nil,
+		},
+		"event": map[string]interface{}{"plan": "pro", "amount": "19.5"},
+		"count": 3,
+		"n-17":  map[string]interface{}{"status": 200.0},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`person.score > 50 && event.plan == "pro"`, true},
+		{`person.score > 80 || event.plan == "free"`, false},
+		{`!(person.score < 50)`, true},
+		{`"vip" in person.tags`, true},
+		{`"gold" not in person.tags`, true},
+		{`person.tags contains "beta"`, true},
+		{`event.plan in ["pro", "team"]`, true},
+		{`lower(person.email) endsWith "@example.com"`, true},
+		{`person.email matches "^[A-Z][a-z]+@"`, true},
+		{`person.email startsWith "Ada"`, true},
+		{`event.amount > 10`, true},
+		{`count == 3`, true},
+		{`person.first_name == null`, true},
+		{`person.first_name > 5`, false},
+		{`person.missing.deeper == null`, true},
+		{`(person.first_name ?? "friend") == "friend"`, true},
+		{`now() - date(person.signed_up) > days(30)`, true},
+		{`date(person.signed_up) + days(90) < now()`, false},
+		{`person.signed_up < "2026-02-01"`, true},
+		{`len(person.tags) == 2 && exists(event.plan)`, true},
+		{`unknown`, false},
+		{`context["n-17"].status == 200`, true},
+	}
+
+	for _, tt := range tests {
+		program, err := Compile(tt.expr)
+		if err != nil {
+			t.Errorf("Compile(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		res, err := program.Evaluate(testEnv())
+		if err != nil {
+			t.Errorf("Evaluate(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if res.Value != tt.want {
+			t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, res.Value, tt.want)
+		}
+	}
+}
+
+func TestEvaluateReportsDecider(t *testing.T) {
+	program, err := Compile(`person.score > 80 && event.plan == "pro"`)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	res, err := program.Evaluate(testEnv())
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if res.Value || res.Decider != "person.score > 80" || res.Detail != "72 > 80" {
+		t.Errorf("Unexpected result: %+v", res)
+	}
+
+	program, _ = Compile(`person.score > 80 || event.plan == "pro"`)
+	res, _ = program.Evaluate(testEnv())
+	if !res.Value || res.Decider != `event.plan == "pro"` || res.Detail != `"pro" == "pro"` {
+		t.Errorf("Unexpected result: %+v", res)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		message string
+	}{
+		{``, "empty"},
+		{`person.score >`, "unexpected end"},
+		{`person.score > 5 > 3`, "cannot chain"},
+		{`"a" > 5`, "cannot compare a string with a number"},
+		{`1 && true`, "needs booleans"},
+		{`person.name matches "[a-"`, "invalid regular expression"},
+		{`foo(1)`, "unknown function"},
+		{`days("x") > hours(1)`, "days() needs a number"},
+		{`person.score + 1`, ""},
+		{`5 + 5`, "must produce true or false"},
+		{`x > null`, "cannot order against null"},
+		{`"unterminated`, "unterminated string"},
+		{`5 in 6`, "needs a list or string"},
+	}
+
+	for _, tt := range tests {
+		_, err := Compile(tt.expr)
+		if tt.message == "" {
+			// Context values have unknown types, so this can only fail at runtime
+			if err != nil {
+				t.Errorf("Compile(%q) unexpectedly failed: %v", tt.expr, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("Compile(%q) error = %v, want it to mention %q", tt.expr, err, tt.message)
+		}
+	}
+}
+
+func TestEvaluateRuntimeErrors(t *testing.T) {
+	program, err := Compile(`person.score contains "x"`)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if _, err := program.Evaluate(testEnv()); err == nil {
+		t.Error("Expected error searching in a number")
+	}
+
+	program, _ = Compile(`event.plan`)
+	if _, err := program.Evaluate(testEnv()); err == nil || !strings.Contains(err.Error(), "expected true or false") {
+		t.Errorf("Expected non-boolean result error, got %v", err)
+	}
+}
@@ -0,0 +1,374 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// SyntaxError reports a problem at a position in the expression source
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos+1)
+}
+
+type node interface {
+	span() (int, int)
+}
+
+type pos struct{ start, end int }
+
+func (p pos) span() (int, int) { return p.start, p.end }
+
+type literalNode struct {
+	pos
+	value interface{}
+}
+
+type identNode struct {
+	pos
+	name string
+}
+
+type memberNode struct {
+	pos
+	object node
+	field  string
+}
+
+type indexNode struct {
+	pos
+	object node
+	index  node
+}
+
+type listNode struct {
+	pos
+	items []node
+}
+
+type unaryNode struct {
+	pos
+	op      string
+	operand node
+}
+
+type binaryNode struct {
+	pos
+	op          string
+	left, right node
+	re          *regexp.Regexp // Precompiled pattern for "matches" with a literal
+}
+
+type callNode struct {
+	pos
+	name string
+	args []node
+}
+
+// keywords that cannot be used as bare identifiers
+var keywords = map[string]bool{
+	"true": true, "false": true, "null": true, "in": true, "not": true,
+	"contains": true, "matches": true, "startsWith": true, "endsWith": true,
+}
+
+// comparisonOps are the non-associative operators between ?? and &&
+var comparisonOps = map[string]bool{
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"in": true, "not in": true, "contains": true, "matches": true, "startsWith": true, "endsWith": true,
+}
+
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func parse(src string) (node, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.i] }
+
+func (p *parser) next() token {
+	tok := p.tokens[p.i]
+	if tok.kind != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+// isOp reports whether the next token is the operator or keyword text
+func (p *parser) isOp(text string) bool {
+	tok := p.peek()
+	return (tok.kind == tokOp || tok.kind == tokIdent) && tok.text == text
+}
+
+func (p *parser) expect(text string) (token, error) {
+	tok := p.next()
+	if tok.text != text || (tok.kind != tokOp && tok.kind != tokIdent) {
+		if tok.kind == tokEOF {
+			return tok, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expected %q but the expression ended", text)}
+		}
+		return tok, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expected %q but found %q", text, tok.text)}
+	}
+	return tok, nil
+}
+
+func spanOf(left, right node) pos {
+	start, _ := left.span()
+	_, end := right.span()
+	return pos{start, end}
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: spanOf(left, right), op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: spanOf(left, right), op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseCoalesce()
+	if err != nil {
+		return nil, err
+	}
+	if !p.atComparison() {
+		return left, nil
+	}
+
+	opTok := p.next()
+	op := opTok.text
+	if op == "not" {
+		if _, err := p.expect("in"); err != nil {
+			return nil, err
+		}
+		op = "not in"
+	}
+
+	right, err := p.parseCoalesce()
+	if err != nil {
+		return nil, err
+	}
+	if p.atComparison() {
+		next := p.peek()
+		return nil, &SyntaxError{Pos: next.pos, Msg: fmt.Sprintf("cannot chain %q after %q, use && or parentheses", next.text, op)}
+	}
+	return &binaryNode{pos: spanOf(left, right), op: op, left: left, right: right}, nil
+}
+
+// atComparison reports whether the next token starts a comparison operator
+func (p *parser) atComparison() bool {
+	tok := p.peek()
+	if tok.kind != tokOp && tok.kind != tokIdent {
+		return false
+	}
+	return comparisonOps[tok.text] || tok.text == "not"
+}
+
+func (p *parser) parseCoalesce() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("??") {
+		p.next()
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: spanOf(left, right), op: "??", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: spanOf(left, right), op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*") || p.isOp("/") || p.isOp("%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: spanOf(left, right), op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("!") || p.isOp("-") {
+		tok := p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		_, end := operand.span()
+		return &unaryNode{pos: pos{tok.pos, end}, op: tok.text, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.isOp("."):
+			p.next()
+			tok := p.next()
+			if tok.kind != tokIdent {
+				return nil, &SyntaxError{Pos: tok.pos, Msg: "expected a field name after \".\""}
+			}
+			start, _ := n.span()
+			n = &memberNode{pos: pos{start, tok.end}, object: n, field: tok.text}
+
+		case p.isOp("["):
+			p.next()
+			index, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			closing, err := p.expect("]")
+			if err != nil {
+				return nil, err
+			}
+			start, _ := n.span()
+			n = &indexNode{pos: pos{start, closing.end}, object: n, index: index}
+
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber, tokString:
+		return &literalNode{pos: pos{tok.pos, tok.end}, value: tok.value}, nil
+
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{pos: pos{tok.pos, tok.end}, value: true}, nil
+		case "false":
+			return &literalNode{pos: pos{tok.pos, tok.end}, value: false}, nil
+		case "null":
+			return &literalNode{pos: pos{tok.pos, tok.end}, value: nil}, nil
+		}
+		if keywords[tok.text] {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected keyword %q", tok.text)}
+		}
+		if p.isOp("(") {
+			p.next()
+			args, closing, err := p.parseList(")")
+			if err != nil {
+				return nil, err
+			}
+			return &callNode{pos: pos{tok.pos, closing.end}, name: tok.text, args: args}, nil
+		}
+		return &identNode{pos: pos{tok.pos, tok.end}, name: tok.text}, nil
+
+	case tokOp:
+		switch tok.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		case "[":
+			items, closing, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{pos: pos{tok.pos, closing.end}, items: items}, nil
+		}
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	return nil, &SyntaxError{Pos: tok.pos, Msg: "unexpected end of expression"}
+}
+
+// parseList parses comma-separated expressions up to the closing token
+func (p *parser) parseList(closing string) ([]node, token, error) {
+	var items []node
+	if p.isOp(closing) {
+		return items, p.next(), nil
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, token{}, err
+		}
+		items = append(items, item)
+		if p.isOp(",") {
+			p.next()
+			continue
+		}
+		end, err := p.expect(closing)
+		return items, end, err
+	}
+}
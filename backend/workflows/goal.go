@@ -0,0 +1,50 @@
+package workflows
+
+import (
+	"errors"
+)
+
+// Goal types
+const (
+	GoalEvent    = "event"    // The person triggers an event, e.g. purchase
+	GoalAudience = "audience" // The person joins an audience
+)
+
+// Goal is a workflow's exit criterion. As soon as a person reaches it they are
+// removed from every in-flight execution of the workflow, and each exit is
+// recorded as a conversion.
+type Goal struct {
+	Type       string                 `json:"type" validate:"required,oneof=event audience" desc:"What counts as reaching the goal: event or audience."`
+	Event      string                 `json:"event,omitempty" desc:"For event goals: Name of the event, e.g. purchase."`
+	Filter     map[string]interface{} `json:"filter,omitempty" desc:"For event goals, optional: Event properties that must match, keyed by dotted path."`
+	AudienceID int                    `json:"audience_id,omitempty" desc:"For audience goals: Audience the person joins."`
+}
+
+// Validate checks the goal has what its type needs
+func (g *Goal) Validate() error {
+	if err := validate.Struct(g); err != nil {
+		return formatValidationError(err, "Goal")
+	}
+	switch g.Type {
+	case GoalEvent:
+		if g.Event == "" {
+			return errors.New("validation failed for Goal: event is required for event goals")
+		}
+	case GoalAudience:
+		if g.AudienceID <= 0 {
+			return errors.New("validation failed for Goal: audience_id is required for audience goals")
+		}
+	}
+	return nil
+}
+
+// MatchesEvent reports whether an event reaches the goal. Filter values are
+// compared as in WAIT_FOR_EVENT nodes.
+func (g *Goal) MatchesEvent(name string, properties map[string]interface{}) bool {
+	return g.Type == GoalEvent && g.Event == name && MatchesFilter(g.Filter, properties)
+}
+
+// MatchesAudience reports whether joining an audience reaches the goal
+func (g *Goal) MatchesAudience(audienceID int) bool {
+	return g.Type == GoalAudience && g.AudienceID == audienceID
+}
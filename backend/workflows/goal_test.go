@@ -0,0 +1,47 @@
+package workflows
+
+import "testing"
+
+func TestGoalValidate(t *testing.T) {
+	valid := []Goal{
+		{Type: GoalEvent, Event: "purchase"},
+		{Type: GoalAudience, AudienceID: 3},
+	}
+	for _, g := range valid {
+		if err := g.Validate(); err != nil {
+			t.Errorf("expected %+v to be valid, got %v", g, err)
+		}
+	}
+	invalid := []Goal{
+		{},
+		{Type: "click"},
+		{Type: GoalEvent},
+		{Type: GoalAudience},
+	}
+	for _, g := range invalid {
+		if err := g.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", g)
+		}
+	}
+}
+
+func TestGoalMatches(t *testing.T) {
+	g := Goal{Type: GoalEvent, Event: "purchase", Filter: map[string]interface{}{"plan": "pro"}}
+	if !g.MatchesEvent("purchase", map[string]interface{}{"plan": "pro", "amount": 10}) {
+		t.Error("expected matching event to reach the goal")
+	}
+	if g.MatchesEvent("purchase", map[string]interface{}{"plan": "basic"}) {
+		t.Error("expected filter mismatch not to reach the goal")
+	}
+	if g.MatchesEvent("signup", map[string]interface{}{"plan": "pro"}) {
+		t.Error("expected other event not to reach the goal")
+	}
+	if g.MatchesAudience(3) {
+		t.Error("expected event goal not to match an audience")
+	}
+
+	a := Goal{Type: GoalAudience, AudienceID: 3}
+	if !a.MatchesAudience(3) || a.MatchesAudience(4) {
+		t.Error("expected audience goal to match only its audience")
+	}
+}
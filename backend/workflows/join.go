@@ -0,0 +1,37 @@
+package workflows
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Modes of a JOIN node
+const (
+	JoinAll = "all" // Continue once every branch has arrived or ended
+	JoinAny = "any" // Continue on the first arrival and cancel the other branches
+)
+
+// JoinNode holds the properties of a JOIN node, where parallel branches started by
+// a node with several outgoing edges merge back into one path.
+type JoinNode struct {
+	Mode string `json:"mode,omitempty" validate:"omitempty,oneof=all any" desc:"Optional: 'all' waits for every branch (default); 'any' continues on the first and cancels the rest."`
+}
+
+// ParseJoinNode reads and validates a JOIN node's properties
+func ParseJoinNode(properties map[string]interface{}) (*JoinNode, error) {
+	propBytes, err := json.Marshal(properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal properties: %w", err)
+	}
+	var node JoinNode
+	if err := json.Unmarshal(propBytes, &node); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal properties: %w", err)
+	}
+	if err := validate.Struct(&node); err != nil {
+		return nil, formatValidationError(err, "Join")
+	}
+	if node.Mode == "" {
+		node.Mode = JoinAll
+	}
+	return &node, nil
+}
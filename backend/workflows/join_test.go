@@ -0,0 +1,16 @@
+package workflows
+
+import "testing"
+
+func TestParseJoinNode(t *testing.T) {
+	node, err := ParseJoinNode(map[string]interface{}{})
+	if err != nil || node.Mode != JoinAll {
+		t.Errorf("expected default mode %q, got %+v (%v)", JoinAll, node, err)
+	}
+	if node, err := ParseJoinNode(map[string]interface{}{"mode": "any"}); err != nil || node.Mode != JoinAny {
+		t.Errorf("expected mode %q, got %+v (%v)", JoinAny, node, err)
+	}
+	if _, err := ParseJoinNode(map[string]interface{}{"mode": "first"}); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
@@ -0,0 +1,369 @@
+package workflows
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity of a lint issue. Errors block saving a workflow; warnings are shown
+// in the builder but do not.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// LintIssue is one problem found in a workflow graph
+type LintIssue struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	NodeID   string   `json:"node_id,omitempty"`
+	EdgeID   string   `json:"edge_id,omitempty"`
+}
+
+// LintResult holds the issues found by LintGraph
+type LintResult struct {
+	Errors   []LintIssue `json:"errors"`
+	Warnings []LintIssue `json:"warnings"`
+}
+
+// Valid reports whether the graph has no errors
+func (r LintResult) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// Err summarizes the errors as a single error, or returns nil for a valid graph
+func (r LintResult) Err() error {
+	if r.Valid() {
+		return nil
+	}
+	messages := make([]string, len(r.Errors))
+	for i, issue := range r.Errors {
+		messages[i] = issue.Message
+	}
+	return fmt.Errorf("invalid workflow graph: %s", strings.Join(messages, "; "))
+}
+
+func (r *LintResult) add(severity Severity, code, nodeID, edgeID, format string, args ...interface{}) {
+	issue := LintIssue{Severity: severity, Code: code, Message: fmt.Sprintf(format, args...), NodeID: nodeID, EdgeID: edgeID}
+	if severity == SeverityError {
+		r.Errors = append(r.Errors, issue)
+	} else {
+		r.Warnings = append(r.Warnings, issue)
+	}
+}
+
+// nodeHandles lists the handles each node type may leave through. Node types not
+// listed only have the default handle.
+var nodeHandles = map[string][]string{
+	"CONDITION":      {"true", "false"},
+	"APPROVAL":       {HandleApproved, HandleRejected, HandleTimeout},
+	"WAIT_FOR_EVENT": {HandleMatched, HandleTimeout},
+	"LOOP":           {HandleItem, HandleDone},
+}
+
+// handlesOf returns the handles a node may leave through, and false for node
+// types that only have the default handle. A SPLIT node's handles are its
+// variant names.
+func handlesOf(node Node) ([]string, bool) {
+	if node.Type == "SPLIT" {
+		split, err := ParseSplitNode(node.Properties)
+		if err != nil {
+			return nil, false // Reported as an invalid node
+		}
+		return split.Handles(), true
+	}
+	handles, ok := nodeHandles[node.Type]
+	return handles, ok
+}
+
+// LintGraph checks a workflow graph: node properties, edges that point at missing
+// nodes, triggers, branch handles, unreachable nodes and cycles.
+func LintGraph(graph Graph) LintResult {
+	result := LintResult{Errors: []LintIssue{}, Warnings: []LintIssue{}}
+
+	nodes := make(map[string]Node, len(graph.Nodes))
+	nodeIDs := make(map[string]bool, len(graph.Nodes))
+	triggers := []string{}
+	for _, node := range graph.Nodes {
+		if node.ID == "" {
+			result.add(SeverityError, "node_missing_id", "", "", "A %s node has no ID", node.Type)
+			continue
+		}
+		if nodeIDs[node.ID] {
+			result.add(SeverityError, "duplicate_node_id", node.ID, "", "Node ID %s is used more than once", node.ID)
+			continue
+		}
+		nodes[node.ID] = node
+		nodeIDs[node.ID] = true
+		if node.Type == "TRIGGER" {
+			triggers = append(triggers, node.ID)
+		}
+	}
+
+	if len(triggers) == 0 {
+		result.add(SeverityError, "no_trigger", "", "", "Workflow has no trigger node")
+	}
+
+	for _, node := range graph.Nodes {
+		if nodes[node.ID].ID == "" {
+			continue
+		}
+		if err := validateNode(node, nodeIDs); err != nil {
+			result.add(SeverityError, "invalid_node", node.ID, "", "%s", err.Error())
+		}
+	}
+
+	// Edges
+	outgoing := map[string][]Edge{}
+	incoming := map[string]int{}
+	edgeIDs := map[string]bool{}
+	for _, edge := range graph.Edges {
+		if edge.ID != "" {
+			if edgeIDs[edge.ID] {
+				result.add(SeverityError, "duplicate_edge_id", "", edge.ID, "Edge ID %s is used more than once", edge.ID)
+			}
+			edgeIDs[edge.ID] = true
+		}
+
+		source, sourceOK := nodes[edge.Source]
+		_, targetOK := nodes[edge.Target]
+		if !sourceOK {
+			result.add(SeverityError, "edge_unknown_source", "", edge.ID, "Edge %s starts at missing node %q", edge.ID, edge.Source)
+		}
+		if !targetOK {
+			result.add(SeverityError, "edge_unknown_target", "", edge.ID, "Edge %s points to missing node %q", edge.ID, edge.Target)
+		}
+		if !sourceOK || !targetOK {
+			continue
+		}
+
+		if edge.Source == edge.Target {
+			result.add(SeverityError, "self_loop", edge.Source, edge.ID, "Edge %s connects node %s to itself", edge.ID, edge.Source)
+			continue
+		}
+		if nodes[edge.Target].Type == "TRIGGER" {
+			result.add(SeverityError, "edge_into_trigger", edge.Target, edge.ID, "Edge %s points into trigger %s; triggers can only start a workflow", edge.ID, edge.Target)
+		}
+
+		if handles, ok := handlesOf(source); ok {
+			if !contains(handles, edge.Handle) {
+				result.add(SeverityError, "invalid_handle", edge.Source, edge.ID, "Edge %s leaves %s node %s through unknown handle %q (expected one of: %s)",
+					edge.ID, source.Type, edge.Source, edge.Handle, strings.Join(handles, ", "))
+			}
+		}
+
+		outgoing[edge.Source] = append(outgoing[edge.Source], edge)
+		incoming[edge.Target]++
+	}
+
+	// Branches
+	for _, node := range graph.Nodes {
+		if nodes[node.ID].ID == "" {
+			continue
+		}
+		// Several edges on one handle run their targets as parallel branches
+		taken := map[string]bool{}
+		handles, branching := handlesOf(node)
+		for _, edge := range outgoing[node.ID] {
+			handle := edge.Handle
+			if !branching {
+				handle = "default"
+			}
+			taken[handle] = true
+		}
+
+		switch node.Type {
+		case "CONDITION":
+			for _, handle := range []string{"true", "false"} {
+				if !taken[handle] {
+					result.add(SeverityError, "missing_branch", node.ID, "", "Condition %s has no %q edge", node.ID, handle)
+				}
+			}
+		case "APPROVAL":
+			if !taken[HandleApproved] {
+				result.add(SeverityWarning, "missing_branch", node.ID, "", "Approval %s has no %q edge; approved executions end here", node.ID, HandleApproved)
+			}
+			if timeout, _ := node.Properties["timeout_hours"].(float64); timeout > 0 {
+				if !taken[HandleTimeout] {
+					result.add(SeverityWarning, "missing_branch", node.ID, "", "Approval %s has a timeout but no %q edge", node.ID, HandleTimeout)
+				}
+			}
+		case "WAIT_FOR_EVENT":
+			if !taken[HandleMatched] {
+				result.add(SeverityWarning, "missing_branch", node.ID, "", "Wait %s has no %q edge; executions end when the event arrives", node.ID, HandleMatched)
+			}
+			if timeout, _ := node.Properties["timeout_hours"].(float64); timeout > 0 {
+				if !taken[HandleTimeout] {
+					result.add(SeverityWarning, "missing_branch", node.ID, "", "Wait %s has a timeout but no %q edge", node.ID, HandleTimeout)
+				}
+			}
+		case "LOOP":
+			if !taken[HandleItem] {
+				result.add(SeverityWarning, "missing_branch", node.ID, "", "Loop %s has no %q edge, so there is nothing to run per item", node.ID, HandleItem)
+			}
+		case "SPLIT":
+			for _, handle := range handles {
+				if !taken[handle] {
+					result.add(SeverityWarning, "missing_branch", node.ID, "", "Split %s has no %q edge; executions assigned to it end here", node.ID, handle)
+				}
+			}
+		case "JOIN":
+			if incoming[node.ID] < 2 {
+				result.add(SeverityWarning, "join_single_input", node.ID, "", "Join %s has fewer than two incoming edges, so it has nothing to merge", node.ID)
+			}
+		case "TRIGGER":
+			if len(taken) == 0 {
+				result.add(SeverityWarning, "dead_end_trigger", node.ID, "", "Trigger %s is not connected to anything", node.ID)
+			}
+		}
+	}
+
+	// Reachability from the triggers
+	reached := map[string]bool{}
+	queue := append([]string{}, triggers...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if reached[id] {
+			continue
+		}
+		reached[id] = true
+		for _, edge := range outgoing[id] {
+			queue = append(queue, edge.Target)
+		}
+	}
+	if len(triggers) > 0 {
+		for _, node := range graph.Nodes {
+			if nodes[node.ID].ID != "" && !reached[node.ID] {
+				result.add(SeverityWarning, "unreachable_node", node.ID, "", "Node %s (%s) cannot be reached from any trigger", node.ID, nodeName(node))
+			}
+		}
+	}
+
+	for _, cycle := range findCycles(graph.Nodes, outgoing) {
+		spinning := spinningCycles(cycle, nodes, outgoing)
+		if len(spinning) == 0 {
+			result.add(SeverityWarning, "cycle", cycle[0], "", "Nodes %s form a loop", strings.Join(cycle, ", "))
+		}
+		for _, loop := range spinning {
+			result.add(SeverityError, "cycle", loop[0], "", "Nodes %s form a loop that never waits; add a delay, approval or event wait to it", strings.Join(loop, ", "))
+		}
+	}
+
+	return result
+}
+
+// findCycles returns the nodes of each loop in the graph, one sorted list per
+// strongly connected component
+func findCycles(graphNodes []Node, outgoing map[string][]Edge) [][]string {
+	// Tarjan's algorithm
+	index := 0
+	indices := map[string]int{}
+	lowlink := map[string]int{}
+	onStack := map[string]bool{}
+	stack := []string{}
+	cycles := [][]string{}
+
+	var strongConnect func(id string)
+	strongConnect = func(id string) {
+		indices[id] = index
+		lowlink[id] = index
+		index++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, edge := range outgoing[id] {
+			if _, seen := indices[edge.Target]; !seen {
+				strongConnect(edge.Target)
+				lowlink[id] = min(lowlink[id], lowlink[edge.Target])
+			} else if onStack[edge.Target] {
+				lowlink[id] = min(lowlink[id], indices[edge.Target])
+			}
+		}
+
+		if lowlink[id] == indices[id] {
+			component := []string{}
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == id {
+					break
+				}
+			}
+			// Self loops are reported separately
+			if len(component) > 1 {
+				sort.Strings(component)
+				cycles = append(cycles, component)
+			}
+		}
+	}
+
+	for _, node := range graphNodes {
+		if _, seen := indices[node.ID]; !seen && node.ID != "" {
+			strongConnect(node.ID)
+		}
+	}
+	return cycles
+}
+
+// spinningCycles returns the loops within a strongly connected component that
+// never wait, and so could spin the worker. A component can hold several loops,
+// so the nodes that wait are taken out and the loops left are found again.
+func spinningCycles(cycle []string, nodes map[string]Node, outgoing map[string][]Edge) [][]string {
+	remaining := map[string]bool{}
+	for _, id := range cycle {
+		if !nodeWaits(nodes[id]) {
+			remaining[id] = true
+		}
+	}
+
+	members := []Node{}
+	inner := map[string][]Edge{}
+	for _, id := range cycle {
+		if !remaining[id] {
+			continue
+		}
+		members = append(members, nodes[id])
+		for _, edge := range outgoing[id] {
+			if remaining[edge.Target] {
+				inner[id] = append(inner[id], edge)
+			}
+		}
+	}
+	return findCycles(members, inner)
+}
+
+// nodeWaits reports whether a node pauses the execution, via a delay, an
+// approval or an event wait
+func nodeWaits(node Node) bool {
+	if node.Type == "APPROVAL" || node.Type == "WAIT_FOR_EVENT" || node.Type == "DELAY" {
+		return true
+	}
+	if node.Type == "ACTION" {
+		actionType, _ := node.Properties["action"].(string)
+		if action, ok := GetAction(actionType); ok {
+			if _, pauses := action.(Pauser); pauses {
+				return true
+			}
+		}
+	}
+	for _, key := range []string{"delay_days", "delay_hours"} {
+		if v, ok := node.Properties[key].(float64); ok && v > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeName(node Node) string {
+	if node.Label != "" {
+		return node.Label
+	}
+	return node.Type
+}
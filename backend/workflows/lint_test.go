@@ -0,0 +1,279 @@
+package workflows
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type lintTestAction struct {
+	Message string `json:"message" validate:"required"`
+}
+
+func (a *lintTestAction) Execute(ctx context.Context, contextData map[string]interface{}) (string, error) {
+	return a.Message, nil
+}
+
+type lintTestPauser struct {
+	Minutes int `json:"minutes"`
+}
+
+func (a *lintTestPauser) Execute(ctx context.Context, contextData map[string]interface{}) (string, error) {
+	return "paused", nil
+}
+
+func (a *lintTestPauser) PauseUntil(now time.Time) time.Time {
+	return now.Add(time.Duration(a.Minutes) * time.Minute)
+}
+
+type lintTestLogic struct{}
+
+func (l *lintTestLogic) Evaluate(ctx context.Context, contextData map[string]interface{}) (bool, string, error) {
+	return true, "", nil
+}
+
+type lintTestTrigger struct{}
+
+func (t *lintTestTrigger) Type() string { return "LINT_TEST" }
+
+func registerLintComponents() {
+	RegisterAction("Lint Test", &lintTestAction{})
+	RegisterAction("Lint Pause", &lintTestPauser{})
+	RegisterLogic("Condition", &lintTestLogic{})
+	RegisterTrigger("LINT_TEST", &lintTestTrigger{})
+}
+
+func lintCodes(issues []LintIssue) map[string]LintIssue {
+	codes := map[string]LintIssue{}
+	for _, issue := range issues {
+		codes[issue.Code] = issue
+	}
+	return codes
+}
+
+func TestLintGraphValid(t *testing.T) {
+	registerLintComponents()
+
+	graph := mustGraph(t, `{
+		"nodes": [
+			{"id": "t1", "type": "TRIGGER", "properties": {"trigger_type": "LINT_TEST"}},
+			{"id": "c1", "type": "CONDITION", "properties": {}},
+			{"id": "a1", "type": "ACTION", "properties": {"action": "Lint Test", "message": "yes"}},
+			{"id": "a2", "type": "ACTION", "properties": {"action": "Lint Test", "message": "no"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "t1", "target": "c1"},
+			{"id": "e2", "source": "c1", "target": "a1", "handle": "true"},
+			{"id": "e3", "source": "c1", "target": "a2", "handle": "false"}
+		]
+	}`)
+	result := LintGraph(graph)
+	if !result.Valid() || len(result.Warnings) != 0 {
+		t.Fatalf("expected a clean graph, got errors %+v warnings %+v", result.Errors, result.Warnings)
+	}
+	if result.Err() != nil {
+		t.Errorf("Err() = %v for a valid graph", result.Err())
+	}
+}
+
+func TestLintGraphErrors(t *testing.T) {
+	registerLintComponents()
+
+	graph := mustGraph(t, `{
+		"nodes": [
+			{"id": "c1", "type": "CONDITION", "properties": {}},
+			{"id": "a1", "type": "ACTION", "properties": {"action": "Lint Test"}},
+			{"id": "a2", "type": "ACTION", "properties": {"action": "Lint Test", "message": "x"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "c1", "target": "a1", "handle": "true"},
+			{"id": "e2", "source": "c1", "target": "a2", "handle": "maybe"},
+			{"id": "e3", "source": "a1", "target": "ghost"},
+			{"id": "e4", "source": "a1", "target": "a2"},
+			{"id": "e5", "source": "a2", "target": "a1"}
+		]
+	}`)
+	result := LintGraph(graph)
+	codes := lintCodes(result.Errors)
+
+	for code, want := range map[string]LintIssue{
+		"no_trigger":          {},
+		"invalid_node":        {NodeID: "a1"},
+		"invalid_handle":      {NodeID: "c1", EdgeID: "e2"},
+		"edge_unknown_target": {EdgeID: "e3"},
+		"missing_branch":      {NodeID: "c1"},
+		"cycle":               {NodeID: "a1"},
+	} {
+		got, ok := codes[code]
+		if !ok {
+			t.Errorf("expected %s error, got %+v", code, result.Errors)
+			continue
+		}
+		if got.NodeID != want.NodeID || got.EdgeID != want.EdgeID {
+			t.Errorf("%s: got node %q edge %q, want node %q edge %q", code, got.NodeID, got.EdgeID, want.NodeID, want.EdgeID)
+		}
+	}
+	if result.Err() == nil {
+		t.Error("expected Err() to summarize the errors")
+	}
+}
+
+func TestLintGraphUnknownNodeType(t *testing.T) {
+	registerLintComponents()
+
+	graph := mustGraph(t, `{
+		"nodes": [
+			{"id": "t1", "type": "TRIGGER", "properties": {"trigger_type": "LINT_TEST"}},
+			{"id": "d1", "type": "DELAYY", "properties": {"days": 1}}
+		],
+		"edges": [{"id": "e1", "source": "t1", "target": "d1"}]
+	}`)
+	result := LintGraph(graph)
+	if got, ok := lintCodes(result.Errors)["invalid_node"]; !ok || got.NodeID != "d1" {
+		t.Fatalf("expected invalid_node on d1, got %+v", result.Errors)
+	}
+}
+
+func TestLintGraphWarnings(t *testing.T) {
+	registerLintComponents()
+
+	graph := mustGraph(t, `{
+		"nodes": [
+			{"id": "t1", "type": "TRIGGER", "properties": {"trigger_type": "LINT_TEST"}},
+			{"id": "a1", "type": "ACTION", "properties": {"action": "Lint Test", "message": "x"}},
+			{"id": "a2", "type": "ACTION", "properties": {"action": "Lint Test", "message": "x", "delay_days": 1}},
+			{"id": "orphan", "type": "ACTION", "properties": {"action": "Lint Test", "message": "x"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "t1", "target": "a1"},
+			{"id": "e2", "source": "a1", "target": "a2"},
+			{"id": "e3", "source": "a2", "target": "a1"}
+		]
+	}`)
+	result := LintGraph(graph)
+	if !result.Valid() {
+		t.Fatalf("expected only warnings, got errors %+v", result.Errors)
+	}
+	codes := lintCodes(result.Warnings)
+	if codes["unreachable_node"].NodeID != "orphan" {
+		t.Errorf("expected orphan to be unreachable, got %+v", result.Warnings)
+	}
+	if _, ok := codes["cycle"]; !ok {
+		t.Errorf("expected a loop with a delay to be a warning, got %+v", result.Warnings)
+	}
+}
+
+func TestLintGraphCycleThroughDelay(t *testing.T) {
+	registerLintComponents()
+
+	for name, wait := range map[string]string{
+		"delay node":   `{"id": "w1", "type": "DELAY", "properties": {"mode": "duration", "days": 1}}`,
+		"pause action": `{"id": "w1", "type": "ACTION", "properties": {"action": "Lint Pause", "minutes": 30}}`,
+	} {
+		graph := mustGraph(t, `{
+			"nodes": [
+				{"id": "t1", "type": "TRIGGER", "properties": {"trigger_type": "LINT_TEST"}},
+				{"id": "a1", "type": "ACTION", "properties": {"action": "Lint Test", "message": "x"}},
+				`+wait+`
+			],
+			"edges": [
+				{"id": "e1", "source": "t1", "target": "a1"},
+				{"id": "e2", "source": "a1", "target": "w1"},
+				{"id": "e3", "source": "w1", "target": "a1"}
+			]
+		}`)
+		result := LintGraph(graph)
+		if !result.Valid() {
+			t.Errorf("%s: expected a loop that waits to be allowed, got errors %+v", name, result.Errors)
+		}
+		if _, ok := lintCodes(result.Warnings)["cycle"]; !ok {
+			t.Errorf("%s: expected the loop to be a warning, got %+v", name, result.Warnings)
+		}
+	}
+}
+
+func TestLintGraphCycleWithLoopThatNeverWaits(t *testing.T) {
+	registerLintComponents()
+
+	// a1 loops back through the delay w1 and, separately, through a2 alone
+	graph := mustGraph(t, `{
+		"nodes": [
+			{"id": "t1", "type": "TRIGGER", "properties": {"trigger_type": "LINT_TEST"}},
+			{"id": "a1", "type": "ACTION", "properties": {"action": "Lint Test", "message": "x"}},
+			{"id": "a2", "type": "ACTION", "properties": {"action": "Lint Test", "message": "y"}},
+			{"id": "w1", "type": "DELAY", "properties": {"mode": "duration", "days": 1}}
+		],
+		"edges": [
+			{"id": "e1", "source": "t1", "target": "a1"},
+			{"id": "e2", "source": "a1", "target": "w1"},
+			{"id": "e3", "source": "w1", "target": "a1"},
+			{"id": "e4", "source": "a1", "target": "a2"},
+			{"id": "e5", "source": "a2", "target": "a1"}
+		]
+	}`)
+	result := LintGraph(graph)
+	cycle, ok := lintCodes(result.Errors)["cycle"]
+	if !ok {
+		t.Fatalf("expected the loop through a2 to be an error, got errors %+v", result.Errors)
+	}
+	if !strings.Contains(cycle.Message, "a1, a2 form") {
+		t.Errorf("expected the error to name the loop without the delay, got %q", cycle.Message)
+	}
+}
+
+func TestLintGraphParallel(t *testing.T) {
+	registerLintComponents()
+
+	graph := mustGraph(t, `{
+		"nodes": [
+			{"id": "t1", "type": "TRIGGER", "properties": {"trigger_type": "LINT_TEST"}},
+			{"id": "a1", "type": "ACTION", "properties": {"action": "Lint Test", "message": "email"}},
+			{"id": "a2", "type": "ACTION", "properties": {"action": "Lint Test", "message": "notify sales"}},
+			{"id": "j1", "type": "JOIN", "properties": {"mode": "all"}},
+			{"id": "j2", "type": "JOIN", "properties": {"mode": "some"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "t1", "target": "a1"},
+			{"id": "e2", "source": "t1", "target": "a2"},
+			{"id": "e3", "source": "a1", "target": "j1"},
+			{"id": "e4", "source": "a2", "target": "j1"},
+			{"id": "e5", "source": "j1", "target": "j2"}
+		]
+	}`)
+	result := LintGraph(graph)
+
+	if got := lintCodes(result.Errors)["invalid_node"]; got.NodeID != "j2" || len(result.Errors) != 1 {
+		t.Errorf("expected only j2's mode to be invalid, got %+v", result.Errors)
+	}
+	if got := lintCodes(result.Warnings)["join_single_input"]; got.NodeID != "j2" || len(result.Warnings) != 1 {
+		t.Errorf("expected only a single-input warning for j2, got %+v", result.Warnings)
+	}
+}
+
+func TestLintGraphSplit(t *testing.T) {
+	registerLintComponents()
+
+	graph := mustGraph(t, `{
+		"nodes": [
+			{"id": "t1", "type": "TRIGGER", "properties": {"trigger_type": "LINT_TEST"}},
+			{"id": "s1", "type": "SPLIT", "properties": {"variants": [{"name": "A", "weight": 50}, {"name": "B", "weight": 30}, {"name": "C", "weight": 20}]}},
+			{"id": "a1", "type": "ACTION", "properties": {"action": "Lint Test", "message": "long email"}},
+			{"id": "a2", "type": "ACTION", "properties": {"action": "Lint Test", "message": "short email"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "t1", "target": "s1"},
+			{"id": "e2", "source": "s1", "target": "a1", "handle": "A"},
+			{"id": "e3", "source": "s1", "target": "a2", "handle": "B"},
+			{"id": "e4", "source": "s1", "target": "a2", "handle": "D"}
+		]
+	}`)
+	result := LintGraph(graph)
+
+	if got := lintCodes(result.Errors)["invalid_handle"]; got.EdgeID != "e4" || len(result.Errors) != 1 {
+		t.Errorf("expected only e4's handle to be invalid, got %+v", result.Errors)
+	}
+	if got := lintCodes(result.Warnings)["missing_branch"]; got.NodeID != "s1" || len(result.Warnings) != 1 {
+		t.Errorf("expected a missing branch warning for variant C, got %+v", result.Warnings)
+	}
+}
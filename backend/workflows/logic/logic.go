@@ -2,9 +2,11 @@ package logic
 
 import (
 	"context"
-	"math/rand"
+	"errors"
+	"fmt"
 
 	"github.com/wesuuu/helpnow/backend/workflows"
+	"github.com/wesuuu/helpnow/backend/workflows/expr"
 )
 
 func init() {
@@ -13,26 +15,45 @@ func init() {
 
 // ConditionLogic evaluates a condition based on context data
 type ConditionLogic struct {
-	Force string `json:"force,omitempty" validate:"omitempty,oneof=true false" desc:"Optional: Set to 'true' or 'false' to force a specific result. If not provided, the condition will evaluate randomly (50/50)."`
+	Expression string `json:"expression,omitempty" desc:"Expression over the execution context, e.g. person.score > 50 && event.plan == \"pro\". Supports == != < <= > >=, && || !, in, contains, matches, startsWith, endsWith, ?? and date math with now(), date(), days(), hours() and minutes()."`
+	Force      string `json:"force,omitempty" validate:"omitempty,oneof=true false" desc:"Optional: Set to 'true' or 'false' to force a specific result, ignoring the expression."`
 }
 
-func (l *ConditionLogic) Evaluate(ctx context.Context, contextData map[string]interface{}) (bool, string, error) {
-	result := false
+// Validate compiles and type-checks the expression
+func (l *ConditionLogic) Validate() error {
+	if l.Force != "" {
+		return nil
+	}
+	if l.Expression == "" {
+		return errors.New("expression is required")
+	}
+	if _, err := expr.Compile(l.Expression); err != nil {
+		return fmt.Errorf("invalid expression: %w", err)
+	}
+	return nil
+}
 
+func (l *ConditionLogic) Evaluate(ctx context.Context, contextData map[string]interface{}) (bool, string, error) {
 	// If Force is set on the struct, use that
 	if l.Force == "true" {
-		result = true
+		return true, "Condition: True (forced)", nil
 	} else if l.Force == "false" {
-		result = false
-	} else {
-		// Otherwise, random 50/50
-		result = rand.Float32() > 0.5
+		return false, "Condition: False (forced)", nil
+	}
+
+	program, err := expr.Compile(l.Expression)
+	if err != nil {
+		return false, "Invalid expression", fmt.Errorf("invalid expression: %w", err)
+	}
+
+	res, err := program.Evaluate(contextData)
+	if err != nil {
+		return false, "Evaluation failed: " + err.Error(), err
 	}
 
 	output := "Condition: False"
-	if result {
+	if res.Value {
 		output = "Condition: True"
 	}
-
-	return result, output, nil
+	return res.Value, fmt.Sprintf("%s, %s", output, res), nil
 }
@@ -0,0 +1,77 @@
+package workflows
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// HandleItem is followed out of a LOOP node once per item, as that iteration's
+// body; HandleDone is followed once every iteration has finished.
+const (
+	HandleItem = "item"
+	HandleDone = "done"
+)
+
+// Context keys an iteration finds its item and position under
+const (
+	LoopItemKey  = "item"
+	LoopStateKey = "loop"
+)
+
+// DefaultMaxIterations caps a LOOP node that does not set max_iterations
+const DefaultMaxIterations = 100
+
+// LoopNode holds the properties of a LOOP node, which runs the path on its "item"
+// handle once per element of a list in the context, with {{item}} bound to it.
+type LoopNode struct {
+	Items         string `json:"items" validate:"required" desc:"Context path of the list to loop over, e.g. event.line_items or n-123.body.urls."`
+	MaxIterations int    `json:"max_iterations,omitempty" validate:"omitempty,min=1,max=1000" desc:"Optional: Most items to run the body for (default 100, at most 1000); the rest are skipped."`
+}
+
+// Limit returns the most iterations the loop may run
+func (l *LoopNode) Limit() int {
+	if l.MaxIterations > 0 {
+		return l.MaxIterations
+	}
+	return DefaultMaxIterations
+}
+
+// Collect returns the items to loop over, capped at Limit, and whether any were
+// left out. The list may also be a JSON array in a string, as HTTP steps return.
+func (l *LoopNode) Collect(contextData map[string]interface{}) ([]interface{}, bool, error) {
+	path := contextPath(l.Items)
+	value, ok := lookup(contextData, path)
+	if !ok {
+		return nil, false, fmt.Errorf("no list at %q in the context", path)
+	}
+	if s, isString := value.(string); isString {
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(s), &parsed); err == nil {
+			value = parsed
+		}
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, false, fmt.Errorf("%q is not a list", path)
+	}
+	if len(items) > l.Limit() {
+		return items[:l.Limit()], true, nil
+	}
+	return items, false, nil
+}
+
+// ParseLoopNode reads and validates a LOOP node's properties
+func ParseLoopNode(properties map[string]interface{}) (*LoopNode, error) {
+	propBytes, err := json.Marshal(properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal properties: %w", err)
+	}
+	var node LoopNode
+	if err := json.Unmarshal(propBytes, &node); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal properties: %w", err)
+	}
+	if err := validate.Struct(&node); err != nil {
+		return nil, formatValidationError(err, "Loop")
+	}
+	return &node, nil
+}
@@ -0,0 +1,46 @@
+package workflows
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLoopNode(t *testing.T) {
+	node, err := ParseLoopNode(map[string]interface{}{"items": "event.line_items"})
+	if err != nil || node.Limit() != DefaultMaxIterations {
+		t.Errorf("expected default limit %d, got %+v (%v)", DefaultMaxIterations, node, err)
+	}
+	if _, err := ParseLoopNode(map[string]interface{}{}); err == nil {
+		t.Error("Expected error for missing items")
+	}
+	if _, err := ParseLoopNode(map[string]interface{}{"items": "x", "max_iterations": 5000}); err == nil {
+		t.Error("Expected error for max_iterations over 1000")
+	}
+}
+
+func TestLoopCollect(t *testing.T) {
+	ctx := map[string]interface{}{
+		"event": map[string]interface{}{"line_items": []interface{}{"a", "b", "c"}},
+		"n-1":   map[string]interface{}{"body": `["x", "y"]`},
+		"count": 3.0,
+	}
+
+	loop := LoopNode{Items: "event.line_items", MaxIterations: 2}
+	items, truncated, err := loop.Collect(ctx)
+	if err != nil || !truncated || !reflect.DeepEqual(items, []interface{}{"a", "b"}) {
+		t.Errorf("expected the first two items, got %v truncated=%v (%v)", items, truncated, err)
+	}
+
+	loop = LoopNode{Items: "{{context.n-1.body}}"}
+	items, truncated, err = loop.Collect(ctx)
+	if err != nil || truncated || !reflect.DeepEqual(items, []interface{}{"x", "y"}) {
+		t.Errorf("expected the JSON array to be parsed, got %v (%v)", items, err)
+	}
+
+	for _, path := range []string{"count", "missing"} {
+		loop = LoopNode{Items: path}
+		if _, _, err := loop.Collect(ctx); err == nil {
+			t.Errorf("expected an error for %q", path)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrTransient marks failures that are expected to succeed on a later attempt
+// (SMTP 4xx, HTTP 5xx, connection resets). Wrap errors with Transient.
+var ErrTransient = errors.New("transient error")
+
+// Transient wraps err so that retry policies with retry_on "transient" match it
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrTransient, err)
+}
+
+// RetryPolicy controls how a failed ACTION node is retried. It is read from the
+// node's "retry" property. Once a node under a policy fails for good, whether
+// its attempts ran out or its error is not one retry_on covers, the execution
+// ends DEAD_LETTER so it can be inspected and replayed.
+type RetryPolicy struct {
+	MaxAttempts       int      `json:"max_attempts" validate:"required,min=1,max=25" desc:"Total attempts including the first one."`
+	BackoffSeconds    float64  `json:"backoff_seconds,omitempty" validate:"omitempty,min=0" desc:"Delay before the first retry. Defaults to 60 seconds."`
+	Multiplier        float64  `json:"multiplier,omitempty" validate:"omitempty,min=1" desc:"Factor applied to the delay after each attempt. Defaults to 2."`
+	MaxBackoffSeconds float64  `json:"max_backoff_seconds,omitempty" validate:"omitempty,min=0" desc:"Upper bound for the delay between attempts. Defaults to 6 hours."`
+	Jitter            float64  `json:"jitter,omitempty" validate:"omitempty,min=0,max=1" desc:"Randomizes each delay by up to this fraction (0-1)."`
+	RetryOn           []string `json:"retry_on,omitempty" desc:"Which errors are retried: 'transient', 'timeout', or a substring of the error message. Empty retries every error."`
+}
+
+const (
+	defaultBackoff    = 60 * time.Second
+	defaultMaxBackoff = 6 * time.Hour
+)
+
+// ParseRetryPolicy reads the optional "retry" property of a node. It returns nil
+// when the node has no retry policy.
+func ParseRetryPolicy(properties map[string]interface{}) (*RetryPolicy, error) {
+	raw, ok := properties["retry"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	propBytes, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal retry policy: %w", err)
+	}
+	var policy RetryPolicy
+	if err := json.Unmarshal(propBytes, &policy); err != nil {
+		return nil, fmt.Errorf("invalid retry policy: %w", err)
+	}
+	if err := validate.Struct(&policy); err != nil {
+		return nil, formatValidationError(err, "retry policy")
+	}
+	return &policy, nil
+}
+
+// ShouldRetry reports whether another attempt is allowed after the given number
+// of attempts failed with err.
+func (p *RetryPolicy) ShouldRetry(attempts int, err error) bool {
+	if attempts >= p.MaxAttempts {
+		return false
+	}
+	return p.Retryable(err)
+}
+
+// Retryable reports whether err matches the policy's retry_on filters
+func (p *RetryPolicy) Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if len(p.RetryOn) == 0 {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, rule := range p.RetryOn {
+		switch strings.ToLower(rule) {
+		case "transient":
+			if errors.Is(err, ErrTransient) || isTimeout(err) {
+				return true
+			}
+		case "timeout":
+			if isTimeout(err) {
+				return true
+			}
+		default:
+			if rule != "" && strings.Contains(message, strings.ToLower(rule)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Backoff returns the delay before the next attempt, given how many attempts
+// have already been made (1 after the first failure).
+func (p *RetryPolicy) Backoff(attempts int) time.Duration {
+	base := defaultBackoff
+	if p.BackoffSeconds > 0 {
+		base = time.Duration(p.BackoffSeconds * float64(time.Second))
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	maxBackoff := defaultMaxBackoff
+	if p.MaxBackoffSeconds > 0 {
+		maxBackoff = time.Duration(p.MaxBackoffSeconds * float64(time.Second))
+	}
+
+	delay := float64(base) * math.Pow(multiplier, float64(attempts-1))
+	if delay > float64(maxBackoff) {
+		delay = float64(maxBackoff)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,81 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseRetryPolicy(t *testing.T) {
+	policy, err := ParseRetryPolicy(map[string]interface{}{"action": "Send Email"})
+	if err != nil || policy != nil {
+		t.Fatalf("Expected no policy without a retry property, got %v (err %v)", policy, err)
+	}
+
+	policy, err = ParseRetryPolicy(map[string]interface{}{
+		"retry": map[string]interface{}{"max_attempts": 3.0, "backoff_seconds": 30.0},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if policy.MaxAttempts != 3 || policy.BackoffSeconds != 30 {
+		t.Errorf("Policy not parsed correctly: %+v", policy)
+	}
+
+	_, err = ParseRetryPolicy(map[string]interface{}{
+		"retry": map[string]interface{}{"max_attempts": 3.0, "jitter": 2.0},
+	})
+	if err == nil {
+		t.Error("Expected error for jitter > 1")
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 5, BackoffSeconds: 10, Multiplier: 3, MaxBackoffSeconds: 60}
+
+	expected := []time.Duration{10 * time.Second, 30 * time.Second, 60 * time.Second, 60 * time.Second}
+	for i, want := range expected {
+		if got := policy.Backoff(i + 1); got != want {
+			t.Errorf("Attempt %d: expected backoff %v, got %v", i+1, want, got)
+		}
+	}
+
+	jittered := &RetryPolicy{MaxAttempts: 2, BackoffSeconds: 100, Jitter: 0.5}
+	for i := 0; i < 20; i++ {
+		d := jittered.Backoff(1)
+		if d < 50*time.Second || d > 150*time.Second {
+			t.Fatalf("Jittered backoff %v outside of [50s, 150s]", d)
+		}
+	}
+}
+
+func TestRetryPolicy_ShouldRetry(t *testing.T) {
+	smtpErr := Transient(errors.New("smtp: 421 service not available"))
+	permanentErr := errors.New("no recipient email found in context")
+	timeoutErr := fmt.Errorf("request failed: %w", context.DeadlineExceeded)
+
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		attempts int
+		err      error
+		expected bool
+	}{
+		{"any error retried", RetryPolicy{MaxAttempts: 3}, 1, permanentErr, true},
+		{"attempts exhausted", RetryPolicy{MaxAttempts: 3}, 3, smtpErr, false},
+		{"transient only matches transient", RetryPolicy{MaxAttempts: 3, RetryOn: []string{"transient"}}, 1, smtpErr, true},
+		{"transient only skips permanent", RetryPolicy{MaxAttempts: 3, RetryOn: []string{"transient"}}, 1, permanentErr, false},
+		{"timeout", RetryPolicy{MaxAttempts: 3, RetryOn: []string{"timeout"}}, 1, timeoutErr, true},
+		{"message substring", RetryPolicy{MaxAttempts: 3, RetryOn: []string{"Service Not Available"}}, 2, smtpErr, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.ShouldRetry(tt.attempts, tt.err); got != tt.expected {
+				t.Errorf("Expected ShouldRetry=%v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package workflows
+
+import "context"
+
+// ExecutionInfo identifies the execution a node runs in. The worker attaches it to
+// the context passed to actions and logic, which otherwise only see context data.
+type ExecutionInfo struct {
+	ExecutionID    int
+	WorkflowID     int
+	OrganizationID int // Zero when the workflow has no organization
+	NodeID         string
+	SubjectID      int  // Person the execution runs for; zero when it has none
+	Depth          int  // Levels of Run Workflow above the execution
+	DryRun         bool // Set during simulations; nothing may be sent or written
+}
+
+type executionInfoKey struct{}
+
+// WithExecutionInfo returns a context carrying info
+func WithExecutionInfo(ctx context.Context, info ExecutionInfo) context.Context {
+	return context.WithValue(ctx, executionInfoKey{}, info)
+}
+
+// ExecutionInfoFrom returns the execution info attached to ctx, if any
+func ExecutionInfoFrom(ctx context.Context) (ExecutionInfo, bool) {
+	info, ok := ctx.Value(executionInfoKey{}).(ExecutionInfo)
+	return info, ok
+}
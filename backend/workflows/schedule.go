@@ -0,0 +1,84 @@
+package workflows
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Scheduled is implemented by triggers that fire on a time schedule
+type Scheduled interface {
+	NextRun(after time.Time) (time.Time, error)
+}
+
+// Schedule is a parsed cron expression bound to a time zone
+type Schedule struct {
+	spec     cron.Schedule
+	location *time.Location
+}
+
+// ParseSchedule parses a standard 5-field cron expression (or a descriptor such as
+// "@daily") and binds it to the given IANA time zone. An empty zone means UTC.
+func ParseSchedule(expr string, timezone string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("cron expression is empty")
+	}
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		return nil, fmt.Errorf("set the time zone with the timezone field instead of a TZ= prefix")
+	}
+
+	location := time.UTC
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", timezone)
+		}
+		location = loc
+	}
+
+	spec, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+
+	return &Schedule{spec: spec, location: location}, nil
+}
+
+// Next returns the first fire time strictly after the given time
+func (s *Schedule) Next(after time.Time) time.Time {
+	return s.spec.Next(after.In(s.location))
+}
+
+// Location returns the time zone the schedule is evaluated in
+func (s *Schedule) Location() *time.Location {
+	return s.location
+}
+
+// NextTriggerRun computes the next fire time for a trigger node of the given type.
+// ok is false when the trigger type is not time based.
+func NextTriggerRun(triggerType string, properties map[string]interface{}, after time.Time) (next time.Time, ok bool, err error) {
+	triggerTemplate, found := GetTrigger(triggerType)
+	if !found {
+		return time.Time{}, false, fmt.Errorf("unknown trigger type: %s", triggerType)
+	}
+	if _, scheduled := triggerTemplate.(Scheduled); !scheduled {
+		return time.Time{}, false, nil
+	}
+
+	trigger := reflect.New(reflect.TypeOf(triggerTemplate).Elem()).Interface()
+	propBytes, err := json.Marshal(properties)
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("failed to marshal properties: %w", err)
+	}
+	if err := json.Unmarshal(propBytes, trigger); err != nil {
+		return time.Time{}, true, fmt.Errorf("failed to unmarshal properties: %w", err)
+	}
+
+	next, err = trigger.(Scheduled).NextRun(after)
+	return next, true, err
+}
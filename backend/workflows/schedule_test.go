@@ -0,0 +1,77 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		timezone string
+	}{
+		{"empty", "", ""},
+		{"too few fields", "0 9 * *", ""},
+		{"out of range", "61 9 * * *", ""},
+		{"garbage", "every day", ""},
+		{"unknown zone", "0 9 * * *", "Mars/Olympus"},
+		{"inline zone", "CRON_TZ=UTC 0 9 * * *", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSchedule(tt.expr, tt.timezone); err == nil {
+				t.Errorf("Expected error for %q (%q), got nil", tt.expr, tt.timezone)
+			}
+		})
+	}
+}
+
+func TestSchedule_NextInTimezone(t *testing.T) {
+	schedule, err := ParseSchedule("0 9 * * *", "America/New_York")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// 2025-01-15 12:00 UTC is 07:00 in New York (EST, UTC-5)
+	after := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	next := schedule.Next(after)
+
+	expected := time.Date(2025, 1, 15, 14, 0, 0, 0, time.UTC)
+	if !next.Equal(expected) {
+		t.Errorf("Expected next run %s, got %s", expected, next.UTC())
+	}
+	if next.Location().String() != "America/New_York" {
+		t.Errorf("Expected next run in America/New_York, got %s", next.Location())
+	}
+}
+
+func TestSchedule_NextWeekdays(t *testing.T) {
+	schedule, err := ParseSchedule("30 8 * * 1-5", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Friday 2025-01-17 10:00 UTC
+	after := time.Date(2025, 1, 17, 10, 0, 0, 0, time.UTC)
+	runs := []time.Time{}
+	for next := after; len(runs) < 3; {
+		next = schedule.Next(next)
+		runs = append(runs, next)
+	}
+
+	expected := []time.Time{
+		time.Date(2025, 1, 20, 8, 30, 0, 0, time.UTC), // Monday
+		time.Date(2025, 1, 21, 8, 30, 0, 0, time.UTC),
+		time.Date(2025, 1, 22, 8, 30, 0, 0, time.UTC),
+	}
+	if len(runs) != len(expected) {
+		t.Fatalf("Expected %d runs, got %d", len(expected), len(runs))
+	}
+	for i := range expected {
+		if !runs[i].Equal(expected[i]) {
+			t.Errorf("Run %d: expected %s, got %s", i, expected[i], runs[i])
+		}
+	}
+}
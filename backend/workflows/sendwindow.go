@@ -0,0 +1,83 @@
+package workflows
+
+import (
+	"fmt"
+	"time"
+)
+
+// SendWindow is the time of day messages may go out, in the recipient's local
+// time; outside it are quiet hours. A window whose end is before its start runs
+// overnight, e.g. 20:00 to 02:00.
+type SendWindow struct {
+	Start    string `json:"start" validate:"required" desc:"Earliest local time to send, e.g. 08:00."`
+	End      string `json:"end" validate:"required" desc:"Local time sending stops, e.g. 20:00."`
+	TimeZone string `json:"time_zone,omitempty" desc:"Optional: IANA time zone for people whose own is unknown, e.g. America/New_York (default UTC)."`
+}
+
+// clockMinutes reads an HH:MM time of day as minutes past midnight
+func clockMinutes(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day, expected e.g. 08:00", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Validate checks the window's times and time zone
+func (w *SendWindow) Validate() error {
+	if err := validate.Struct(w); err != nil {
+		return formatValidationError(err, "SendWindow")
+	}
+	start, err := clockMinutes(w.Start)
+	if err != nil {
+		return fmt.Errorf("validation failed for SendWindow: start %w", err)
+	}
+	end, err := clockMinutes(w.End)
+	if err != nil {
+		return fmt.Errorf("validation failed for SendWindow: end %w", err)
+	}
+	if start == end {
+		return fmt.Errorf("validation failed for SendWindow: start and end must differ")
+	}
+	if w.TimeZone != "" {
+		if _, err := time.LoadLocation(w.TimeZone); err != nil {
+			return fmt.Errorf("validation failed for SendWindow: unknown time_zone %q", w.TimeZone)
+		}
+	}
+	return nil
+}
+
+// Location returns the time zone to apply the window in: the person's if they
+// have a valid one, else the window's, else UTC
+func (w *SendWindow) Location(personZone string) *time.Location {
+	return FirstZone(personZone, w.TimeZone)
+}
+
+// NextOpen returns now if the window is open at now in loc, and otherwise when
+// it next opens
+func (w *SendWindow) NextOpen(now time.Time, loc *time.Location) time.Time {
+	start, err := clockMinutes(w.Start)
+	if err != nil {
+		return now
+	}
+	end, err := clockMinutes(w.End)
+	if err != nil {
+		return now
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	open := minute >= start && minute < end
+	if end < start {
+		open = minute >= start || minute < end
+	}
+	if open {
+		return now
+	}
+
+	next := time.Date(local.Year(), local.Month(), local.Day(), start/60, start%60, 0, 0, loc)
+	if !next.After(now) {
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, start/60, start%60, 0, 0, loc)
+	}
+	return next
+}
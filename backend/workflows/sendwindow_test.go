@@ -0,0 +1,111 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSendWindowValidate(t *testing.T) {
+	valid := SendWindow{Start: "08:00", End: "20:00", TimeZone: "Europe/Paris"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected a valid window, got %v", err)
+	}
+	for _, w := range []SendWindow{
+		{End: "20:00"},
+		{Start: "8am", End: "20:00"},
+		{Start: "08:00", End: "25:00"},
+		{Start: "08:00", End: "08:00"},
+		{Start: "08:00", End: "20:00", TimeZone: "Nowhere/Special"},
+	} {
+		if err := w.Validate(); err == nil {
+			t.Errorf("expected an error for %+v", w)
+		}
+	}
+}
+
+func TestSendWindowNextOpen(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("time zone data not available")
+	}
+	day := SendWindow{Start: "08:00", End: "20:00"}
+	night := SendWindow{Start: "20:00", End: "02:00"}
+	at := func(day, hour, minute int) time.Time { return time.Date(2026, 10, day, hour, minute, 0, 0, paris) }
+
+	cases := []struct {
+		name   string
+		window SendWindow
+		now    time.Time
+		want   time.Time
+	}{
+		{"open", day, at(16, 12, 0), at(16, 12, 0)},
+		{"at the start", day, at(16, 8, 0), at(16, 8, 0)},
+		{"before the start", day, at(16, 3, 0), at(16, 8, 0)},
+		{"at the end", day, at(16, 20, 0), at(17, 8, 0)},
+		{"late evening", day, at(16, 23, 30), at(17, 8, 0)},
+		{"overnight open after midnight", night, at(16, 1, 0), at(16, 1, 0)},
+		{"overnight closed", night, at(16, 12, 0), at(16, 20, 0)},
+	}
+	for _, tc := range cases {
+		if got := tc.window.NextOpen(tc.now, paris); !got.Equal(tc.want) {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.want, got)
+		}
+	}
+
+	// 1am in Paris is 7pm the day before in New York, inside the window there
+	newYork, _ := time.LoadLocation("America/New_York")
+	if got := day.NextOpen(at(16, 1, 0), newYork); !got.Equal(at(16, 1, 0)) {
+		t.Errorf("expected the window to be open in New York, got %s", got)
+	}
+}
+
+func TestZoneForPlace(t *testing.T) {
+	cases := map[string]string{
+		"Paris, France":    "Europe/Paris",
+		"Austin, TX, USA":  "America/Chicago",
+		"Lyon, FR":         "Europe/Paris",
+		"FR":               "Europe/Paris",
+		"Berlin, DE":       "Europe/Berlin",
+		"Asia/Tokyo":       "Asia/Tokyo",
+		"Springfield, USA": "",
+		"":                 "",
+
+		// State abbreviations that are also country codes
+		"Springfield, IL, USA": "America/Chicago",
+		"Wilmington, DE":       "America/New_York",
+		"Indianapolis, IN":     "",
+		"Boulder, CO":          "America/Denver",
+		"Little Rock, AR":      "America/Chicago",
+		"FR, Somewhere":        "",
+
+		// Cities sharing a name with one elsewhere
+		"Portland":        "America/Los_Angeles",
+		"Portland, ME":    "America/New_York",
+		"Portland, OR":    "America/Los_Angeles",
+		"Washington":      "",
+		"Washington, DC":  "America/New_York",
+		"Washington, WA":  "America/Los_Angeles",
+		"Perth, WA":       "Australia/Perth",
+		"Perth, Scotland": "Europe/London",
+		"London, ON":      "America/Toronto",
+		"Paris, TX":       "America/Chicago",
+	}
+	for place, want := range cases {
+		if got := ZoneForPlace(place); got != want {
+			t.Errorf("ZoneForPlace(%q): expected %q, got %q", place, want, got)
+		}
+	}
+
+	for country, want := range map[string]string{"DE": "Europe/Berlin", "in": "Asia/Kolkata", "Germany": "Europe/Berlin", "US": ""} {
+		if got := ZoneForCountry(country); got != want {
+			t.Errorf("ZoneForCountry(%q): expected %q, got %q", country, want, got)
+		}
+	}
+
+	if got := ZoneForLongitude(13.4); got != "Etc/GMT-1" {
+		t.Errorf("expected Etc/GMT-1 for Berlin's longitude, got %s", got)
+	}
+	if got := ZoneForLongitude(-74); got != "Etc/GMT+5" {
+		t.Errorf("expected Etc/GMT+5 for New York's longitude, got %s", got)
+	}
+}
@@ -0,0 +1,80 @@
+package workflows
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+)
+
+// SplitVariant is one arm of a SPLIT node. Its name is the handle executions
+// assigned to it leave through.
+type SplitVariant struct {
+	Name   string `json:"name" validate:"required" desc:"Variant name, also the handle its edges leave through, e.g. A."`
+	Weight int    `json:"weight" validate:"min=0" desc:"Relative share of people sent down this variant, e.g. 50."`
+}
+
+// SplitNode holds the properties of a SPLIT node, which sends each person down one
+// of several weighted variants for A/B tests. The assignment is a hash of the
+// person, so they get the same variant every time they pass through.
+type SplitNode struct {
+	Variants []SplitVariant `json:"variants" validate:"required,min=2,dive" desc:"Variants with their weights; at least two."`
+	Salt     string         `json:"salt,omitempty" desc:"Optional: Change to reshuffle who gets which variant, e.g. when restarting an experiment."`
+}
+
+// Handles returns the variant names
+func (s *SplitNode) Handles() []string {
+	handles := make([]string, len(s.Variants))
+	for i, v := range s.Variants {
+		handles[i] = v.Name
+	}
+	return handles
+}
+
+// Assign picks the variant for key, which identifies the person (or execution)
+// being split. The same key always gets the same variant for a given set of
+// weights and salt; each variant gets a share of keys in proportion to its weight.
+func (s *SplitNode) Assign(key string) string {
+	total := 0
+	for _, v := range s.Variants {
+		total += v.Weight
+	}
+	h := fnv.New64a()
+	h.Write([]byte(s.Salt + ":" + key))
+	bucket := int(h.Sum64() % uint64(total))
+	for _, v := range s.Variants {
+		if bucket < v.Weight {
+			return v.Name
+		}
+		bucket -= v.Weight
+	}
+	return s.Variants[len(s.Variants)-1].Name
+}
+
+// ParseSplitNode reads and validates a SPLIT node's properties
+func ParseSplitNode(properties map[string]interface{}) (*SplitNode, error) {
+	propBytes, err := json.Marshal(properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal properties: %w", err)
+	}
+	var node SplitNode
+	if err := json.Unmarshal(propBytes, &node); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal properties: %w", err)
+	}
+	if err := validate.Struct(&node); err != nil {
+		return nil, formatValidationError(err, "Split")
+	}
+
+	seen := map[string]bool{}
+	total := 0
+	for _, v := range node.Variants {
+		if seen[v.Name] {
+			return nil, fmt.Errorf("validation failed for Split: variant %q is listed more than once", v.Name)
+		}
+		seen[v.Name] = true
+		total += v.Weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("validation failed for Split: at least one variant needs a weight above 0")
+	}
+	return &node, nil
+}
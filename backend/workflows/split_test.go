@@ -0,0 +1,64 @@
+package workflows
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseSplitNode(t *testing.T) {
+	valid := map[string]interface{}{"variants": []interface{}{
+		map[string]interface{}{"name": "A", "weight": 1},
+		map[string]interface{}{"name": "B", "weight": 0},
+	}}
+	if _, err := ParseSplitNode(valid); err != nil {
+		t.Errorf("expected valid split, got %v", err)
+	}
+
+	invalid := []map[string]interface{}{
+		{},
+		{"variants": []interface{}{map[string]interface{}{"name": "A", "weight": 1}}},
+		{"variants": []interface{}{map[string]interface{}{"name": "A", "weight": 1}, map[string]interface{}{"name": "A", "weight": 1}}},
+		{"variants": []interface{}{map[string]interface{}{"name": "A", "weight": 0}, map[string]interface{}{"name": "B", "weight": 0}}},
+		{"variants": []interface{}{map[string]interface{}{"name": "A", "weight": -1}, map[string]interface{}{"name": "B", "weight": 2}}},
+		{"variants": []interface{}{map[string]interface{}{"weight": 1}, map[string]interface{}{"name": "B", "weight": 2}}},
+	}
+	for _, props := range invalid {
+		if _, err := ParseSplitNode(props); err == nil {
+			t.Errorf("expected %v to be invalid", props)
+		}
+	}
+}
+
+func TestSplitAssign(t *testing.T) {
+	split := SplitNode{Variants: []SplitVariant{{Name: "A", Weight: 70}, {Name: "B", Weight: 30}, {Name: "off", Weight: 0}}}
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("person:%d", i)
+		variant := split.Assign(key)
+		if split.Assign(key) != variant {
+			t.Fatalf("assignment of %s is not deterministic", key)
+		}
+		counts[variant]++
+	}
+	if counts["off"] != 0 {
+		t.Errorf("zero-weight variant was assigned %d times", counts["off"])
+	}
+	if counts["A"] < 6500 || counts["A"] > 7500 {
+		t.Errorf("expected about 70%% in A, got %v", counts)
+	}
+
+	// A new salt reshuffles people between variants
+	salted := split
+	salted.Salt = "round 2"
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("person:%d", i)
+		if split.Assign(key) != salted.Assign(key) {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Error("expected a new salt to change some assignments")
+	}
+}
@@ -0,0 +1,382 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Template placeholders look like {{context.email}}, {{steps.node-3.output}} or
+// {{person.first_name | default:"there"}}. Each one is a dotted path into the
+// template variables, optionally followed by filters separated by "|".
+var placeholderPattern = regexp.MustCompile(`\{\{(.*?)\}\}`)
+
+// TemplateNamespaces are the top-level names a placeholder path may start with
+var TemplateNamespaces = map[string]bool{
+	"context": true, // Execution context
+	"steps":   true, // Step results by node ID: steps.<node_id>.output / .status
+	"person":  true, // The execution's subject, if any
+	"item":    true, // Inside a LOOP body: the current item
+	"loop":    true, // Inside a LOOP body: loop.index, loop.count and loop.node_id
+}
+
+// templateFilters transform a resolved value into a string. default is handled separately.
+var templateFilters = map[string]func(string) string{
+	"html":  html.EscapeString,
+	"url":   url.QueryEscape,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"json": func(s string) string {
+		var b strings.Builder
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		enc.Encode(s)
+		return strings.TrimSuffix(b.String(), "\n")
+	},
+}
+
+// MissingVariableError is returned when a placeholder has no value and no default
+type MissingVariableError struct {
+	Path string
+}
+
+func (e *MissingVariableError) Error() string {
+	return fmt.Sprintf("template variable {{%s}} is not defined", e.Path)
+}
+
+type placeholder struct {
+	path    string
+	filters []templateFilter
+}
+
+type templateFilter struct {
+	name   string
+	arg    string
+	hasArg bool
+}
+
+// parsePlaceholder parses the inside of a {{...}} expression
+func parsePlaceholder(expr string) (*placeholder, error) {
+	parts, err := splitFilters(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &placeholder{path: strings.TrimSpace(parts[0])}
+	if p.path == "" {
+		return nil, errors.New("empty placeholder {{}}")
+	}
+	for _, segment := range strings.Split(p.path, ".") {
+		if segment == "" {
+			return nil, fmt.Errorf("invalid variable path %q", p.path)
+		}
+	}
+
+	for _, part := range parts[1:] {
+		f := templateFilter{name: strings.TrimSpace(part)}
+		if name, arg, ok := strings.Cut(part, ":"); ok {
+			f.name = strings.TrimSpace(name)
+			f.arg, err = unquoteFilterArg(strings.TrimSpace(arg))
+			if err != nil {
+				return nil, fmt.Errorf("filter %s in {{%s}}: %w", f.name, expr, err)
+			}
+			f.hasArg = true
+		}
+		if f.name == "default" {
+			if !f.hasArg {
+				return nil, fmt.Errorf("filter default in {{%s}} needs a value, e.g. default:\"there\"", expr)
+			}
+		} else if _, ok := templateFilters[f.name]; !ok {
+			return nil, fmt.Errorf("unknown filter %q in {{%s}}", f.name, expr)
+		}
+		p.filters = append(p.filters, f)
+	}
+	return p, nil
+}
+
+// splitFilters splits on "|" outside of quoted filter arguments
+func splitFilters(expr string) ([]string, error) {
+	var parts []string
+	var quote rune
+	start := 0
+	for i, r := range expr {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '|':
+			parts = append(parts, expr[start:i])
+			start = i + 1
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in {{%s}}", expr)
+	}
+	return append(parts, expr[start:]), nil
+}
+
+func unquoteFilterArg(arg string) (string, error) {
+	if len(arg) >= 2 && (arg[0] == '"' || arg[0] == '\'') && arg[len(arg)-1] == arg[0] {
+		return arg[1 : len(arg)-1], nil
+	}
+	if strings.ContainsAny(arg, `"' `) {
+		return "", fmt.Errorf("invalid argument %q", arg)
+	}
+	return arg, nil
+}
+
+// lookup resolves a dotted path, returning false if any segment is missing
+func lookup(vars map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = vars
+	for _, segment := range strings.Split(path, ".") {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			current = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, current != nil
+}
+
+// resolve returns the placeholder's value before string filters are applied
+func (p *placeholder) resolve(vars map[string]interface{}) (interface{}, error) {
+	value, ok := lookup(vars, p.path)
+	if !ok || value == "" {
+		for _, f := range p.filters {
+			if f.name == "default" {
+				return f.arg, nil
+			}
+		}
+	}
+	if !ok {
+		return nil, &MissingVariableError{Path: p.path}
+	}
+	return value, nil
+}
+
+func (p *placeholder) render(vars map[string]interface{}) (string, error) {
+	value, err := p.resolve(vars)
+	if err != nil {
+		return "", err
+	}
+	s := stringify(value)
+	for _, f := range p.filters {
+		if f.name != "default" {
+			s = templateFilters[f.name](s)
+		}
+	}
+	return s, nil
+}
+
+// stringify formats a context value for substitution into a string
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Interpolate replaces every placeholder in s. A string that consists of a single
+// placeholder without string filters keeps the value's type, so "{{context.count}}"
+// can fill a numeric property.
+func Interpolate(s string, vars map[string]interface{}) (interface{}, error) {
+	matches := placeholderPattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		p, err := parsePlaceholder(s[matches[0][2]:matches[0][3]])
+		if err != nil {
+			return nil, err
+		}
+		if len(p.filters) == 0 || (len(p.filters) == 1 && p.filters[0].name == "default") {
+			return p.resolve(vars)
+		}
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		p, err := parsePlaceholder(s[m[2]:m[3]])
+		if err != nil {
+			return nil, err
+		}
+		rendered, err := p.render(vars)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(rendered)
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// InterpolateProperties returns a copy of node properties with every placeholder
+// resolved, descending into nested maps and lists.
+func InterpolateProperties(properties map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(properties))
+	for key, value := range properties {
+		resolved, err := interpolateValue(value, vars)
+		if err != nil {
+			return nil, fmt.Errorf("property %s: %w", key, err)
+		}
+		out[key] = resolved
+	}
+	return out, nil
+}
+
+func interpolateValue(value interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return Interpolate(v, vars)
+	case map[string]interface{}:
+		return InterpolateProperties(v, vars)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := interpolateValue(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	}
+	return value, nil
+}
+
+// TemplateVars builds the variables placeholders resolve against
+func TemplateVars(contextData map[string]interface{}, steps []StepResult, person map[string]interface{}) map[string]interface{} {
+	stepVars := map[string]interface{}{}
+	for _, s := range steps {
+		// Later attempts of the same node overwrite earlier ones
+		stepVars[s.NodeID] = map[string]interface{}{"output": s.Output, "status": s.Status}
+	}
+	vars := map[string]interface{}{
+		"context": contextData,
+		"steps":   stepVars,
+	}
+	if person != nil {
+		vars["person"] = person
+	}
+	// Loop iterations carry their item in the context
+	for _, key := range []string{LoopItemKey, LoopStateKey} {
+		if v, ok := contextData[key]; ok {
+			vars[key] = v
+		}
+	}
+	return vars
+}
+
+// ValidateTemplates checks the placeholder syntax in node properties and that
+// every path starts with a known namespace. steps.<node_id> must name a node in nodeIDs.
+func ValidateTemplates(properties map[string]interface{}, nodeIDs map[string]bool) error {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := validateTemplateValue(properties[key], nodeIDs); err != nil {
+			return fmt.Errorf("property %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func validateTemplateValue(value interface{}, nodeIDs map[string]bool) error {
+	switch v := value.(type) {
+	case string:
+		if strings.Count(v, "{{") != strings.Count(v, "}}") {
+			return fmt.Errorf("unbalanced braces in %q", v)
+		}
+		for _, m := range placeholderPattern.FindAllStringSubmatch(v, -1) {
+			p, err := parsePlaceholder(m[1])
+			if err != nil {
+				return err
+			}
+			segments := strings.Split(p.path, ".")
+			if !TemplateNamespaces[segments[0]] {
+				return fmt.Errorf("unknown variable {{%s}}: must start with one of %s", p.path, namespaceList())
+			}
+			if segments[0] == "steps" {
+				if len(segments) < 2 || !nodeIDs[segments[1]] {
+					return fmt.Errorf("{{%s}} refers to a node that is not in this workflow", p.path)
+				}
+			}
+		}
+	case map[string]interface{}:
+		return ValidateTemplates(v, nodeIDs)
+	case []interface{}:
+		for _, item := range v {
+			if err := validateTemplateValue(item, nodeIDs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func namespaceList() string {
+	names := make([]string, 0, len(TemplateNamespaces))
+	for name := range TemplateNamespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// HasTemplate reports whether a property value contains a placeholder
+func HasTemplate(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return placeholderPattern.MatchString(v)
+	case map[string]interface{}:
+		for _, item := range v {
+			if HasTemplate(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if HasTemplate(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
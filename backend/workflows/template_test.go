@@ -0,0 +1,105 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testVars() map[string]interface{} {
+	return TemplateVars(
+		map[string]interface{}{"email": "ada@example.com", "count": 3.0, "name": "<Ada>", "empty": ""},
+		[]StepResult{{NodeID: "node-3", Status: "success", Output: "sent"}},
+		map[string]interface{}{"first_name": "Ada", "last_name": nil},
+	)
+}
+
+func TestInterpolate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{"Hi {{person.first_name}}!", "Hi Ada!"},
+		{"{{ context.email }}", "ada@example.com"},
+		{"{{context.count}}", 3.0},
+		{"Count: {{context.count}}", "Count: 3"},
+		{"{{steps.node-3.output}}", "sent"},
+		{"{{person.last_name | default:\"friend\"}}", "friend"},
+		{"{{context.empty | default:'n/a'}}", "n/a"},
+		{"<b>{{context.name | html}}</b>", "<b>&lt;Ada&gt;</b>"},
+		{"q={{context.email | url}}", "q=ada%40example.com"},
+		{`{"name": {{context.name | json}}}`, `{"name": "<Ada>"}`},
+		{"{{context.missing | default:\"x\" | upper}}", "X"},
+		{"no placeholders", "no placeholders"},
+	}
+
+	vars := testVars()
+	for _, tt := range tests {
+		got, err := Interpolate(tt.input, vars)
+		if err != nil {
+			t.Errorf("Interpolate(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Interpolate(%q) = %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestInterpolateMissingVariable(t *testing.T) {
+	_, err := InterpolateProperties(map[string]interface{}{"subject": "Hi {{person.last_name}}"}, testVars())
+	var missing *MissingVariableError
+	if !errors.As(err, &missing) || missing.Path != "person.last_name" {
+		t.Fatalf("Expected missing variable error for person.last_name, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "property subject") {
+		t.Errorf("Expected error to name the property, got %v", err)
+	}
+}
+
+func TestValidateTemplates(t *testing.T) {
+	nodes := map[string]bool{"node-1": true, "node-3": true}
+
+	valid := map[string]interface{}{
+		"body":    "Hello {{person.first_name | default:\"there\"}}, {{steps.node-3.output}}",
+		"headers": map[string]interface{}{"X-Email": "{{context.email | url}}"},
+	}
+	if err := ValidateTemplates(valid, nodes); err != nil {
+		t.Errorf("Expected valid templates, got %v", err)
+	}
+
+	invalid := []string{
+		"{{secrets.key}}",
+		"{{steps.node-9.output}}",
+		"{{context.email | shout}}",
+		"{{context.email | default}}",
+		"{{context.email",
+		"{{}}",
+	}
+	for _, tmpl := range invalid {
+		if err := ValidateTemplates(map[string]interface{}{"body": tmpl}, nodes); err == nil {
+			t.Errorf("Expected error for %q", tmpl)
+		}
+	}
+}
+
+func TestValidateActionNodeSkipsTemplatedFields(t *testing.T) {
+	RegisterAction("Template Test", &templateTestAction{})
+
+	if err := ValidateActionNode("Template Test", map[string]interface{}{"url": "{{context.url}}", "count": "{{context.count}}"}); err != nil {
+		t.Errorf("Expected templated fields to be skipped, got %v", err)
+	}
+	if err := ValidateActionNode("Template Test", map[string]interface{}{"url": "not a url", "count": 1.0}); err == nil {
+		t.Error("Expected static fields to still be validated")
+	}
+}
+
+type templateTestAction struct {
+	URL   string `json:"url" validate:"required,url"`
+	Count int    `json:"count" validate:"required,min=1"`
+}
+
+func (a *templateTestAction) Execute(ctx context.Context, contextData map[string]interface{}) (string, error) {
+	return "", nil
+}
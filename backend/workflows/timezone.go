@@ -0,0 +1,159 @@
+package workflows
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// placeZones maps places to their IANA time zone: countries with a single zone,
+// by name, and the larger cities of countries with several. Places are lower case.
+var placeZones = map[string]string{
+	// Countries with one time zone
+	"united kingdom": "Europe/London", "uk": "Europe/London", "great britain": "Europe/London", "england": "Europe/London", "scotland": "Europe/London",
+	"wales": "Europe/London", "ireland": "Europe/Dublin", "france": "Europe/Paris", "germany": "Europe/Berlin", "spain": "Europe/Madrid",
+	"portugal": "Europe/Lisbon", "italy": "Europe/Rome", "netherlands": "Europe/Amsterdam", "belgium": "Europe/Brussels", "switzerland": "Europe/Zurich",
+	"austria": "Europe/Vienna", "sweden": "Europe/Stockholm", "norway": "Europe/Oslo", "denmark": "Europe/Copenhagen", "finland": "Europe/Helsinki",
+	"poland": "Europe/Warsaw", "czechia": "Europe/Prague", "czech republic": "Europe/Prague", "greece": "Europe/Athens", "romania": "Europe/Bucharest",
+	"ukraine": "Europe/Kyiv", "turkey": "Europe/Istanbul", "israel": "Asia/Jerusalem", "united arab emirates": "Asia/Dubai", "uae": "Asia/Dubai",
+	"saudi arabia": "Asia/Riyadh", "egypt": "Africa/Cairo", "nigeria": "Africa/Lagos", "kenya": "Africa/Nairobi", "south africa": "Africa/Johannesburg",
+	"india": "Asia/Kolkata", "pakistan": "Asia/Karachi", "bangladesh": "Asia/Dhaka", "china": "Asia/Shanghai", "hong kong": "Asia/Hong_Kong",
+	"taiwan": "Asia/Taipei", "japan": "Asia/Tokyo", "south korea": "Asia/Seoul", "korea": "Asia/Seoul", "singapore": "Asia/Singapore",
+	"malaysia": "Asia/Kuala_Lumpur", "thailand": "Asia/Bangkok", "vietnam": "Asia/Ho_Chi_Minh", "philippines": "Asia/Manila",
+	"new zealand": "Pacific/Auckland", "argentina": "America/Argentina/Buenos_Aires", "colombia": "America/Bogota", "peru": "America/Lima",
+	"chile": "America/Santiago",
+
+	// Cities often given without their country
+	"london": "Europe/London", "paris": "Europe/Paris", "berlin": "Europe/Berlin", "madrid": "Europe/Madrid", "rome": "Europe/Rome", "amsterdam": "Europe/Amsterdam",
+	"dublin": "Europe/Dublin", "tokyo": "Asia/Tokyo", "seoul": "Asia/Seoul", "beijing": "Asia/Shanghai", "shanghai": "Asia/Shanghai",
+	"mumbai": "Asia/Kolkata", "delhi": "Asia/Kolkata", "new delhi": "Asia/Kolkata", "bangalore": "Asia/Kolkata", "dubai": "Asia/Dubai",
+
+	// Cities in countries with several time zones
+	"new york": "America/New_York", "boston": "America/New_York", "miami": "America/New_York", "atlanta": "America/New_York", "philadelphia": "America/New_York",
+	"chicago": "America/Chicago", "houston": "America/Chicago", "dallas": "America/Chicago", "austin": "America/Chicago",
+	"denver": "America/Denver", "phoenix": "America/Phoenix",
+	"los angeles": "America/Los_Angeles", "san francisco": "America/Los_Angeles", "seattle": "America/Los_Angeles", "san diego": "America/Los_Angeles", "portland": "America/Los_Angeles",
+	"toronto": "America/Toronto", "montreal": "America/Toronto", "ottawa": "America/Toronto", "vancouver": "America/Vancouver", "calgary": "America/Edmonton",
+	"mexico city": "America/Mexico_City", "guadalajara": "America/Mexico_City",
+	"sao paulo": "America/Sao_Paulo", "são paulo": "America/Sao_Paulo", "rio de janeiro": "America/Sao_Paulo",
+	"moscow": "Europe/Moscow", "saint petersburg": "Europe/Moscow",
+	"sydney": "Australia/Sydney", "melbourne": "Australia/Melbourne", "brisbane": "Australia/Brisbane", "perth": "Australia/Perth", "adelaide": "Australia/Adelaide",
+	"jakarta": "Asia/Jakarta", "bali": "Asia/Makassar",
+}
+
+// countryCodes maps the ISO 3166 codes of the countries in placeZones to their
+// zone, lower case
+var countryCodes = map[string]string{
+	"gb": "Europe/London", "ie": "Europe/Dublin", "fr": "Europe/Paris", "de": "Europe/Berlin", "es": "Europe/Madrid", "pt": "Europe/Lisbon",
+	"it": "Europe/Rome", "nl": "Europe/Amsterdam", "be": "Europe/Brussels", "ch": "Europe/Zurich", "at": "Europe/Vienna", "se": "Europe/Stockholm",
+	"no": "Europe/Oslo", "dk": "Europe/Copenhagen", "fi": "Europe/Helsinki", "pl": "Europe/Warsaw", "cz": "Europe/Prague", "gr": "Europe/Athens",
+	"ro": "Europe/Bucharest", "ua": "Europe/Kyiv", "tr": "Europe/Istanbul", "il": "Asia/Jerusalem", "ae": "Asia/Dubai", "sa": "Asia/Riyadh",
+	"eg": "Africa/Cairo", "ng": "Africa/Lagos", "ke": "Africa/Nairobi", "za": "Africa/Johannesburg", "in": "Asia/Kolkata", "pk": "Asia/Karachi",
+	"bd": "Asia/Dhaka", "cn": "Asia/Shanghai", "hk": "Asia/Hong_Kong", "tw": "Asia/Taipei", "jp": "Asia/Tokyo", "kr": "Asia/Seoul",
+	"sg": "Asia/Singapore", "my": "Asia/Kuala_Lumpur", "th": "Asia/Bangkok", "vn": "Asia/Ho_Chi_Minh", "ph": "Asia/Manila", "nz": "Pacific/Auckland",
+	"ar": "America/Argentina/Buenos_Aires", "co": "America/Bogota", "pe": "America/Lima", "cl": "America/Santiago",
+}
+
+// regionZones maps the abbreviations of US states, Canadian provinces and
+// Australian states that lie (almost) wholly in one time zone to it
+var regionZones = map[string]string{
+	"ct": "America/New_York", "dc": "America/New_York", "de": "America/New_York", "fl": "America/New_York", "ga": "America/New_York",
+	"ma": "America/New_York", "md": "America/New_York", "me": "America/New_York", "nc": "America/New_York", "nh": "America/New_York",
+	"nj": "America/New_York", "ny": "America/New_York", "oh": "America/New_York", "pa": "America/New_York", "ri": "America/New_York",
+	"sc": "America/New_York", "va": "America/New_York", "vt": "America/New_York", "wv": "America/New_York", "mi": "America/Detroit",
+	"al": "America/Chicago", "ar": "America/Chicago", "ia": "America/Chicago", "il": "America/Chicago", "la": "America/Chicago",
+	"mn": "America/Chicago", "mo": "America/Chicago", "ms": "America/Chicago", "ok": "America/Chicago", "tx": "America/Chicago", "wi": "America/Chicago",
+	"co": "America/Denver", "mt": "America/Denver", "nm": "America/Denver", "ut": "America/Denver", "wy": "America/Denver", "az": "America/Phoenix",
+	"ca": "America/Los_Angeles", "nv": "America/Los_Angeles", "or": "America/Los_Angeles", "wa": "America/Los_Angeles",
+	"ak": "America/Anchorage", "hi": "Pacific/Honolulu",
+	"on": "America/Toronto", "qc": "America/Toronto", "bc": "America/Vancouver", "ab": "America/Edmonton", "mb": "America/Winnipeg",
+	"sk": "America/Regina", "ns": "America/Halifax", "pe": "America/Halifax", "nb": "America/Moncton", "nl": "America/St_Johns",
+	"nsw": "Australia/Sydney", "act": "Australia/Sydney", "vic": "Australia/Melbourne", "qld": "Australia/Brisbane", "sa": "Australia/Adelaide", "tas": "Australia/Hobart",
+}
+
+// regionCodes are region abbreviations with another meaning: country codes that
+// are also US state, Canadian province or Australian state abbreviations, and WA,
+// both Washington and Western Australia. They only place someone when no city
+// does, so "Berlin, DE" is Germany and "Wilmington, DE" is Delaware, and a
+// free-text place never resolves them as countries.
+var regionCodes = map[string]bool{
+	"ar": true, "co": true, "de": true, "il": true, "in": true, "wa": true, // US states
+	"nl": true, "pe": true, // Canadian provinces
+	"sa": true, // South Australia
+}
+
+// ZoneForPlace returns the IANA time zone of a place such as "Paris, France",
+// "Tokyo", "Lyon, FR", "Portland, ME" or "Europe/Paris", or "" if it is not
+// known. City names are shared, so a region or country after the first part of
+// a comma-separated place wins over it; a country code is only looked for in
+// the last part.
+func ZoneForPlace(place string) string {
+	place = strings.TrimSpace(place)
+	if place == "" {
+		return ""
+	}
+	if strings.Contains(place, "/") {
+		if _, err := time.LoadLocation(place); err == nil {
+			return place
+		}
+	}
+	parts := strings.Split(place, ",")
+	for i := range parts {
+		parts[i] = strings.ToLower(strings.TrimSpace(parts[i]))
+	}
+	for i := len(parts) - 1; i > 0; i-- {
+		if zone := regionZones[parts[i]]; zone != "" && !regionCodes[parts[i]] {
+			return zone
+		}
+		if zone, ok := placeZones[parts[i]]; ok {
+			return zone
+		}
+	}
+	if zone, ok := placeZones[parts[0]]; ok {
+		return zone
+	}
+	for i := len(parts) - 1; i > 0; i-- {
+		if zone, ok := regionZones[parts[i]]; ok {
+			return zone
+		}
+	}
+	if last := parts[len(parts)-1]; !regionCodes[last] {
+		return countryCodes[last]
+	}
+	return ""
+}
+
+// ZoneForCountry returns the IANA time zone of a country given by name or ISO
+// code, such as a GeoIP country, or "" if it has several or is not known
+func ZoneForCountry(country string) string {
+	country = strings.ToLower(strings.TrimSpace(country))
+	if zone, ok := countryCodes[country]; ok {
+		return zone
+	}
+	return placeZones[country]
+}
+
+// ZoneForLongitude returns the fixed-offset zone of a longitude, e.g. Etc/GMT-1
+// (UTC+1) for 10°E. It ignores daylight saving time, so it is a last resort.
+func ZoneForLongitude(longitude float64) string {
+	offset := int(math.Round(longitude / 15))
+	if offset == 0 {
+		return "UTC"
+	}
+	// Etc zones have the sign the other way round
+	return fmt.Sprintf("Etc/GMT%+d", -offset)
+}
+
+// FirstZone returns the first of names that is a known IANA time zone, or UTC
+func FirstZone(names ...string) *time.Location {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
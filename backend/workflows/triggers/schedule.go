@@ -1,6 +1,8 @@
 package triggers
 
 import (
+	"time"
+
 	"github.com/wesuuu/helpnow/backend/workflows"
 )
 
@@ -10,9 +12,25 @@ func init() {
 
 // ScheduleTrigger handles scheduled/cron-based workflow triggers
 type ScheduleTrigger struct {
-	Cron string `json:"cron" validate:"required" desc:"Cron expression defining when the workflow should run (e.g., '0 9 * * *' for daily at 9am). Uses standard cron format: minute hour day month weekday."`
+	Cron     string `json:"cron" validate:"required" desc:"Cron expression defining when the workflow should run (e.g., '0 9 * * *' for daily at 9am). Uses standard cron format: minute hour day month weekday."`
+	Timezone string `json:"timezone,omitempty" desc:"Optional: IANA time zone the cron expression is evaluated in (e.g., 'America/New_York'). Defaults to UTC."`
 }
 
 func (t *ScheduleTrigger) Type() string {
 	return "SCHEDULE"
 }
+
+// Validate rejects cron expressions and time zones that cannot be parsed
+func (t *ScheduleTrigger) Validate() error {
+	_, err := workflows.ParseSchedule(t.Cron, t.Timezone)
+	return err
+}
+
+// NextRun returns the first fire time after the given time
+func (t *ScheduleTrigger) NextRun(after time.Time) (time.Time, error) {
+	schedule, err := workflows.ParseSchedule(t.Cron, t.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(after), nil
+}
@@ -8,10 +8,12 @@ func init() {
 	workflows.RegisterTrigger("WEBHOOK", &WebhookTrigger{})
 }
 
-// WebhookTrigger handles webhook-based workflow triggers
+// WebhookTrigger starts the workflow when a signed request arrives at the node's
+// public URL, /public/hooks/:trigger_token. The token is issued by the server when
+// the workflow is saved; callers sign each request with the secret.
 type WebhookTrigger struct {
-	URL    string `json:"url" validate:"required,url" desc:"Webhook URL that will receive HTTP POST requests when the workflow is triggered. Must be a valid HTTP/HTTPS URL."`
-	Secret string `json:"secret,omitempty" validate:"omitempty,min=8" desc:"Optional: Secret key for webhook signature verification. If provided, must be at least 8 characters long. Used to validate incoming webhook requests."`
+	URL    string `json:"url,omitempty" desc:"Read-only: Public URL issued by the server for this trigger. Any value sent here is ignored."`
+	Secret string `json:"secret" validate:"required,min=8" desc:"Secret used to verify incoming requests. Senders must include X-Helpnow-Timestamp (unix seconds) and X-Helpnow-Signature: sha256=<hex HMAC-SHA256 of \"<timestamp>.<body>\">."`
 }
 
 func (t *WebhookTrigger) Type() string {
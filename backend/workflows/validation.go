@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -25,6 +26,9 @@ func ValidateActionNode(actionType string, properties map[string]interface{}) er
 	// Create a new instance
 	action := reflect.New(reflect.TypeOf(actionTemplate).Elem()).Interface()
 
+	// Templated properties are only known at runtime, so check the rest
+	properties, templated := splitTemplatedProperties(properties)
+
 	// Unmarshal properties into the action
 	propBytes, err := json.Marshal(properties)
 	if err != nil {
@@ -36,10 +40,16 @@ func ValidateActionNode(actionType string, properties map[string]interface{}) er
 	}
 
 	// Validate using struct tags
-	if err := validate.Struct(action); err != nil {
+	if err := dropTemplatedFieldErrors(validate.Struct(action), action, templated); err != nil {
 		return formatValidationError(err, actionType)
 	}
 
+	if v, ok := action.(Validatable); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("validation failed for %s: %w", actionType, err)
+		}
+	}
+
 	return nil
 }
 
@@ -54,6 +64,9 @@ func ValidateLogicNode(logicType string, properties map[string]interface{}) erro
 	// Create a new instance
 	logic := reflect.New(reflect.TypeOf(logicTemplate).Elem()).Interface()
 
+	// Templated properties are only known at runtime, so check the rest
+	properties, templated := splitTemplatedProperties(properties)
+
 	// Unmarshal properties into the logic
 	propBytes, err := json.Marshal(properties)
 	if err != nil {
@@ -65,10 +78,16 @@ func ValidateLogicNode(logicType string, properties map[string]interface{}) erro
 	}
 
 	// Validate using struct tags
-	if err := validate.Struct(logic); err != nil {
+	if err := dropTemplatedFieldErrors(validate.Struct(logic), logic, templated); err != nil {
 		return formatValidationError(err, logicType)
 	}
 
+	if v, ok := logic.(Validatable); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("validation failed for %s: %w", logicType, err)
+		}
+	}
+
 	return nil
 }
 
@@ -98,42 +117,147 @@ func ValidateTriggerNode(triggerType string, properties map[string]interface{})
 		return formatValidationError(err, triggerType)
 	}
 
+	if v, ok := trigger.(Validatable); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("validation failed for %s: %w", triggerType, err)
+		}
+	}
+
 	return nil
 }
 
-// ValidateWorkflowGraph validates all nodes in a workflow graph
+// ValidateWorkflowGraph validates all nodes in a workflow graph. LintGraph also
+// checks how the nodes are connected.
 func ValidateWorkflowGraph(graph Graph) error {
+	nodeIDs := make(map[string]bool, len(graph.Nodes))
 	for _, node := range graph.Nodes {
-		switch node.Type {
-		case "ACTION":
-			actionType, ok := node.Properties["action"].(string)
-			if !ok {
-				return fmt.Errorf("node %s: missing 'action' property", node.ID)
-			}
-			if err := ValidateActionNode(actionType, node.Properties); err != nil {
-				return fmt.Errorf("node %s (%s): %w", node.ID, actionType, err)
-			}
+		nodeIDs[node.ID] = true
+	}
 
-		case "CONDITION":
-			logicType := "Condition" // Default logic type
-			if err := ValidateLogicNode(logicType, node.Properties); err != nil {
-				return fmt.Errorf("node %s (Condition): %w", node.ID, err)
-			}
+	for _, node := range graph.Nodes {
+		if err := validateNode(node, nodeIDs); err != nil {
+			return err
+		}
+	}
 
-		case "TRIGGER":
-			triggerType, ok := node.Properties["trigger_type"].(string)
-			if !ok {
-				triggerType = "EVENT" // Default
-			}
-			if err := ValidateTriggerNode(triggerType, node.Properties); err != nil {
-				return fmt.Errorf("node %s (%s): %w", node.ID, triggerType, err)
-			}
+	return nil
+}
+
+// validateNode checks a node's properties against its action, logic or trigger
+func validateNode(node Node, nodeIDs map[string]bool) error {
+	if err := ValidateTemplates(node.Properties, nodeIDs); err != nil {
+		return fmt.Errorf("node %s: %w", node.ID, err)
+	}
+
+	switch node.Type {
+	case "ACTION":
+		actionType, ok := node.Properties["action"].(string)
+		if !ok {
+			return fmt.Errorf("node %s: missing 'action' property", node.ID)
+		}
+		if err := ValidateActionNode(actionType, node.Properties); err != nil {
+			return fmt.Errorf("node %s (%s): %w", node.ID, actionType, err)
+		}
+		if _, err := ParseRetryPolicy(node.Properties); err != nil {
+			return fmt.Errorf("node %s (%s): %w", node.ID, actionType, err)
+		}
+
+	case "CONDITION":
+		logicType := "Condition" // Default logic type
+		if err := ValidateLogicNode(logicType, node.Properties); err != nil {
+			return fmt.Errorf("node %s (Condition): %w", node.ID, err)
+		}
+
+	case "APPROVAL":
+		if _, err := ParseApprovalNode(node.Properties); err != nil {
+			return fmt.Errorf("node %s (Approval): %w", node.ID, err)
+		}
+
+	case "JOIN":
+		if _, err := ParseJoinNode(node.Properties); err != nil {
+			return fmt.Errorf("node %s (Join): %w", node.ID, err)
+		}
+
+	case "WAIT_FOR_EVENT":
+		if _, err := ParseWaitForEventNode(node.Properties); err != nil {
+			return fmt.Errorf("node %s (Wait For Event): %w", node.ID, err)
+		}
+
+	case "LOOP":
+		if _, err := ParseLoopNode(node.Properties); err != nil {
+			return fmt.Errorf("node %s (Loop): %w", node.ID, err)
 		}
+
+	case "DELAY":
+		if _, err := ParseDelayNode(node.Properties); err != nil {
+			return fmt.Errorf("node %s (Delay): %w", node.ID, err)
+		}
+
+	case "SPLIT":
+		if _, err := ParseSplitNode(node.Properties); err != nil {
+			return fmt.Errorf("node %s (Split): %w", node.ID, err)
+		}
+
+	case "TRIGGER":
+		triggerType, ok := node.Properties["trigger_type"].(string)
+		if !ok {
+			triggerType = "EVENT" // Default
+		}
+		if err := ValidateTriggerNode(triggerType, node.Properties); err != nil {
+			return fmt.Errorf("node %s (%s): %w", node.ID, triggerType, err)
+		}
+
+	default:
+		return fmt.Errorf("node %s: unknown node type %q", node.ID, node.Type)
 	}
 
 	return nil
 }
 
+// splitTemplatedProperties drops properties containing {{placeholders}}, returning
+// the remaining properties and the names of the dropped ones
+func splitTemplatedProperties(properties map[string]interface{}) (map[string]interface{}, map[string]bool) {
+	static := make(map[string]interface{}, len(properties))
+	templated := map[string]bool{}
+	for key, value := range properties {
+		if HasTemplate(value) {
+			templated[key] = true
+			continue
+		}
+		static[key] = value
+	}
+	return static, templated
+}
+
+// dropTemplatedFieldErrors removes validation errors for fields whose value is a
+// template, since those are resolved and checked at runtime
+func dropTemplatedFieldErrors(err error, obj interface{}, templated map[string]bool) error {
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok || len(templated) == 0 {
+		return err
+	}
+
+	t := reflect.TypeOf(obj)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var remaining validator.ValidationErrors
+	for _, e := range validationErrors {
+		if field, ok := t.FieldByName(e.StructField()); ok {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if templated[name] {
+				continue
+			}
+		}
+		remaining = append(remaining, e)
+	}
+	if len(remaining) == 0 {
+		return nil
+	}
+	return remaining
+}
+
 // formatValidationError converts validator errors to user-friendly messages
 func formatValidationError(err error, nodeType string) error {
 	if validationErrors, ok := err.(validator.ValidationErrors); ok {
@@ -0,0 +1,52 @@
+package workflows
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// HandleMatched is followed out of a WAIT_FOR_EVENT node when the awaited event
+// arrives; HandleTimeout is followed when the deadline passes first.
+const HandleMatched = "matched"
+
+// WaitForEventNode holds the properties of a WAIT_FOR_EVENT node, which parks the
+// execution in WAITING_FOR_EVENT until its subject triggers a matching event.
+type WaitForEventNode struct {
+	Event        string                 `json:"event" validate:"required" desc:"Name of the event to wait for, e.g. purchase."`
+	Filter       map[string]interface{} `json:"filter,omitempty" desc:"Optional: Event properties that must match, keyed by dotted path, e.g. {\"plan\": \"pro\"}."`
+	TimeoutHours float64                `json:"timeout_hours,omitempty" validate:"omitempty,gt=0" desc:"Optional: Follow the 'timeout' handle if no matching event arrives within this many hours."`
+}
+
+// Timeout returns how long to wait for the event, or zero for no limit
+func (w *WaitForEventNode) Timeout() time.Duration {
+	return time.Duration(w.TimeoutHours * float64(time.Hour))
+}
+
+// MatchesFilter reports whether every dotted path in filter resolves in properties
+// to the same value. Values are compared by their string form, so 5 matches "5".
+func MatchesFilter(filter, properties map[string]interface{}) bool {
+	for path, want := range filter {
+		got, ok := lookup(properties, path)
+		if !ok || stringify(got) != stringify(want) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseWaitForEventNode reads and validates a WAIT_FOR_EVENT node's properties
+func ParseWaitForEventNode(properties map[string]interface{}) (*WaitForEventNode, error) {
+	propBytes, err := json.Marshal(properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal properties: %w", err)
+	}
+	var node WaitForEventNode
+	if err := json.Unmarshal(propBytes, &node); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal properties: %w", err)
+	}
+	if err := validate.Struct(&node); err != nil {
+		return nil, formatValidationError(err, "Wait For Event")
+	}
+	return &node, nil
+}
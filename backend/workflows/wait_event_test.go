@@ -0,0 +1,47 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWaitForEventNode(t *testing.T) {
+	if _, err := ParseWaitForEventNode(map[string]interface{}{"timeout_hours": 72.0}); err == nil {
+		t.Error("Expected error when no event is set")
+	}
+	if _, err := ParseWaitForEventNode(map[string]interface{}{"event": "purchase", "timeout_hours": -1.0}); err == nil {
+		t.Error("Expected error for negative timeout")
+	}
+
+	node, err := ParseWaitForEventNode(map[string]interface{}{"event": "purchase", "timeout_hours": 72.0})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if node.Timeout() != 72*time.Hour {
+		t.Errorf("Expected 72h timeout, got %v", node.Timeout())
+	}
+}
+
+func TestMatchesFilter(t *testing.T) {
+	filter := map[string]interface{}{"plan": "pro", "order.items": 2.0}
+
+	tests := []struct {
+		name  string
+		props map[string]interface{}
+		want  bool
+	}{
+		{"all match", map[string]interface{}{"plan": "pro", "order": map[string]interface{}{"items": 2.0}}, true},
+		{"string form of number", map[string]interface{}{"plan": "pro", "order": map[string]interface{}{"items": "2"}}, true},
+		{"wrong value", map[string]interface{}{"plan": "free", "order": map[string]interface{}{"items": 2.0}}, false},
+		{"missing path", map[string]interface{}{"plan": "pro"}, false},
+	}
+	for _, tt := range tests {
+		if got := MatchesFilter(filter, tt.props); got != tt.want {
+			t.Errorf("%s: MatchesFilter = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if !MatchesFilter(nil, nil) {
+		t.Error("Expected an empty filter to match any event")
+	}
+}